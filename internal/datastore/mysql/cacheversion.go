@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+)
+
+const metadataReachabilityCacheVersionColumn = "reachability_cache_version"
+
+// CurrentReachabilityCacheVersion is the version of the format of reachability caches persisted
+// alongside the datastore, and must be incremented whenever a change to the computation of
+// reachability makes previously persisted caches stale.
+//
+// Version 2 records the tupleset relation of arrow entrypoints.
+const CurrentReachabilityCacheVersion uint32 = 2
+
+// ReachabilityCacheVersion returns the version of the reachability caches persisted alongside the
+// datastore. If it is lower than CurrentReachabilityCacheVersion, the persisted caches are stale
+// and must be rebuilt, after which SetReachabilityCacheVersion should be called.
+func (mds *Datastore) ReachabilityCacheVersion(ctx context.Context) (uint32, error) {
+	metadata, err := mds.Metadata(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return metadata.ReachabilityCacheVersion, nil
+}
+
+// SetReachabilityCacheVersion records the version of the reachability caches persisted alongside
+// the datastore, once they have been rebuilt.
+func (mds *Datastore) SetReachabilityCacheVersion(ctx context.Context, version uint32) error {
+	query, args, err := sb.Update(mds.driver.Metadata()).
+		Set(metadataReachabilityCacheVersionColumn, version).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	if _, err := mds.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("unable to write reachability cache version: %w", err)
+	}
+
+	return nil
+}
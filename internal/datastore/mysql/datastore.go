@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -46,17 +48,31 @@ const (
 	batchDeleteSize        = 1000
 	noLastInsertID         = 0
 	seedingTimeout         = 10 * time.Second
+	pingQuery              = "SELECT 1"
 
 	// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_lock_wait_timeout
 	errMysqlLockWaitTimeout = 1205
 
 	// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_lock_deadlock
 	errMysqlDeadlock = 1213
+
+	// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_server_shutdown
+	errMysqlServerShutdown = 1053
+
+	// https://dev.mysql.com/doc/mysql-errors/8.0/en/client-error-reference.html#error_cr_server_gone_error
+	errMysqlServerGone = 2006
+
+	// https://dev.mysql.com/doc/mysql-errors/8.0/en/client-error-reference.html#error_cr_server_lost
+	errMysqlServerLost = 2013
 )
 
 var (
 	tracer = otel.Tracer("spicedb/internal/datastore/mysql")
 
+	// newUniqueID generates the unique ID with which the datastore is seeded. It is a variable
+	// so that tests can seed a known ID.
+	newUniqueID = uuid.NewString
+
 	// Unless specified otherwise, Go's MySQL driver will assume
 	// the server sends datetime in UTC,
 	// see https://github.com/go-sql-driver/mysql#loc. This parameter
@@ -88,7 +104,15 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
-	connector, err := mysql.MySQLDriver{}.OpenConnector(uri)
+	dbConfig, err := mysql.ParseDSN(uri)
+	if err != nil {
+		return nil, fmt.Errorf("NewMySQLDatastore: could not parse connection URI: %w", err)
+	}
+
+	// DATETIME columns are scanned into time.Time, which requires the driver to parse them.
+	dbConfig.ParseTime = true
+
+	connector, err := mysql.NewConnector(dbConfig)
 	if err != nil {
 		return nil, fmt.Errorf("NewMySQLDatastore: failed to create connector: %w", err)
 	}
@@ -116,6 +140,10 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+
+		if err := registerStatisticsMetrics(); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
 	} else {
 		db = sql.OpenDB(connector)
 	}
@@ -125,7 +153,9 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	db.SetMaxOpenConns(config.maxOpenConns)
 	db.SetMaxIdleConns(config.maxOpenConns)
 
-	driver := migrations.NewMySQLDriverFromDB(db, config.tablePrefix)
+	driver := migrations.NewMySQLDriverFromDB(db, config.tablePrefix).
+		WithRelationTupleShards(config.relationTupleShards...).
+		WithSlowQueryThreshold(config.slowQueryThreshold)
 	queryBuilder := NewQueryBuilder(driver)
 
 	createTxn, _, err := sb.Insert(driver.RelationTupleTransaction()).Values().ToSql()
@@ -135,7 +165,7 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 
 	// used for seeding the initial relation_tuple_transaction. using INSERT IGNORE on a known
 	// ID value makes this idempotent (i.e. safe to execute concurrently).
-	createBaseTxn := fmt.Sprintf("INSERT IGNORE INTO %s (id, timestamp) VALUES (1, FROM_UNIXTIME(1))", driver.RelationTupleTransaction())
+	createBaseTxn := fmt.Sprintf("INSERT IGNORE INTO %s (id, timestamp) VALUES (1, FROM_UNIXTIME(1))", migrations.QuoteIdentifier(driver.RelationTupleTransaction()))
 
 	gcCtx, cancelGc := context.WithCancel(context.Background())
 
@@ -152,7 +182,7 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	revisionQuery := fmt.Sprintf(
 		querySelectRevision,
 		colID,
-		driver.RelationTupleTransaction(),
+		migrations.QuoteIdentifier(driver.RelationTupleTransaction()),
 		colTimestamp,
 		quantizationPeriodNanos,
 	)
@@ -160,7 +190,7 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	validTransactionQuery := fmt.Sprintf(
 		queryValidTransaction,
 		colID,
-		driver.RelationTupleTransaction(),
+		migrations.QuoteIdentifier(driver.RelationTupleTransaction()),
 		colTimestamp,
 		gcWindowInverted.Seconds(),
 	)
@@ -184,13 +214,22 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		QueryBuilder:           queryBuilder,
 		readTxOptions:          &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true},
 		maxRetries:             config.maxRetries,
-		analyzeBeforeStats:     config.analyzeBeforeStats,
+		analyzeBeforeStats:     config.analyzeBeforeStats && config.periodicAnalyzeInterval <= 0,
+		analyzeSem:             make(chan struct{}, 1),
+		perNamespaceStats:      config.perNamespaceStats,
+		exactCountThreshold:    config.exactCountThreshold,
+		liveOnlyEstimate:       config.liveOnlyEstimate,
+		slowQueryThreshold:     config.slowQueryThreshold,
+		analyzeTimeout:         config.analyzeTimeout,
+		readReplicaDB:          config.readReplicaDB,
+		enablePrometheusStats:  config.enablePrometheusStats,
 		CachedOptimizedRevisions: revisions.NewCachedOptimizedRevisions(
 			maxRevisionStaleness,
 		),
 	}
 
 	store.SetOptimizedRevisionFunc(store.optimizedRevisionFunc)
+	store.statsCache = newStatisticsCache(config.statisticsCacheTTL, store.computeStatistics)
 
 	ctx, cancel := context.WithTimeout(context.Background(), seedingTimeout)
 	defer cancel()
@@ -199,6 +238,8 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		return nil, err
 	}
 
+	store.warnOnStorageEngine(ctx)
+
 	// Start a goroutine for garbage collection.
 	if store.gcInterval > 0*time.Minute {
 		store.gcGroup, store.gcCtx = errgroup.WithContext(store.gcCtx)
@@ -207,6 +248,19 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		log.Warn().Msg("garbage collection disabled in mysql driver")
 	}
 
+	// Start a goroutine for the periodic analyze.
+	if config.periodicAnalyzeInterval > 0 {
+		var analyzeCtx context.Context
+		analyzeCtx, store.cancelAnalyze = context.WithCancel(context.Background())
+		store.analyzeDone = make(chan struct{})
+		go func() {
+			defer close(store.analyzeDone)
+			runPeriodicAnalyze(analyzeCtx, config.periodicAnalyzeInterval, func(ctx context.Context) error {
+				return store.analyzeForStatistics(ctx, true)
+			})
+		}()
+	}
+
 	return store, nil
 }
 
@@ -370,10 +424,43 @@ func newMySQLExecutor(tx querier) common.ExecuteQueryFunc {
 // Datastore is a MySQL-based implementation of the datastore.Datastore interface
 type Datastore struct {
 	db                 *sql.DB
+	readReplicaDB      *sql.DB
 	driver             *migrations.MySQLDriver
 	readTxOptions      *sql.TxOptions
 	url                string
 	analyzeBeforeStats bool
+	perNamespaceStats  bool
+	analyzeTimeout     time.Duration
+
+	// analyzeSem holds a token while ANALYZE TABLE runs, such that only one runs at a time.
+	analyzeSem chan struct{}
+
+	// cancelAnalyze stops the periodic analyze worker, if started, which closes analyzeDone once
+	// stopped.
+	cancelAnalyze context.CancelFunc
+	analyzeDone   chan struct{}
+
+	statsCache      *statisticsCache
+	statsCalls      statisticsCalls
+	statsStatements preparedStatements
+	uniqueID        atomic.Value
+	statsNamespaces atomic.Value
+
+	// exactCountThreshold is the estimated relationship count below which relationships are
+	// counted exactly for statistics, or zero to always use the estimate.
+	exactCountThreshold uint64
+
+	// liveOnlyEstimate is true if deleted relationships awaiting garbage collection are
+	// subtracted from the estimated relationship count.
+	liveOnlyEstimate bool
+
+	// slowQueryThreshold is the duration above which a statistics query is logged as slow, or
+	// zero to never log queries as slow.
+	slowQueryThreshold time.Duration
+
+	// enablePrometheusStats is true if the metrics collected when computing statistics are
+	// registered, and therefore observed.
+	enablePrometheusStats bool
 
 	revisionQuantization time.Duration
 	gcWindowInverted     time.Duration
@@ -401,11 +488,18 @@ type Datastore struct {
 func (mds *Datastore) Close() error {
 	// TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 	mds.cancelGc()
+	if mds.cancelAnalyze != nil {
+		mds.cancelAnalyze()
+		<-mds.analyzeDone
+	}
 	if mds.gcGroup != nil {
 		if err := mds.gcGroup.Wait(); err != nil {
 			log.Error().Err(err).Msg("error waiting for garbage collector to shutdown")
 		}
 	}
+	if err := mds.statsStatements.close(); err != nil {
+		log.Error().Err(err).Msg("error closing prepared statistics statements")
+	}
 	return mds.db.Close()
 }
 
@@ -595,6 +689,120 @@ func (mds *Datastore) IsReady(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// Ping checks that the database can be reached by running a trivial query, and is intended as a
+// lightweight liveness check: unlike IsReady and Statistics, it does not read any of the
+// datastore's tables. The query is bound by the context, so a hung connection fails as soon as
+// the context's deadline is reached.
+func (mds *Datastore) Ping(ctx context.Context) error {
+	var result int
+	if err := mds.db.QueryRowContext(ctx, pingQuery).Scan(&result); err != nil {
+		return fmt.Errorf("unable to ping datastore: %w", err)
+	}
+
+	return nil
+}
+
+// StorageEngine returns the storage engine of the relationship tuple table, such as
+// migrations.InnoDBStorageEngine. The estimated relationship count of the statistics is only
+// accurate for tables stored with InnoDB.
+func (mds *Datastore) StorageEngine(ctx context.Context) (string, error) {
+	return mds.driver.StorageEngine(ctx)
+}
+
+// warnOnStorageEngine logs a warning if the relationship tuple table is not stored with InnoDB,
+// such as if it was accidentally created as MyISAM.
+func (mds *Datastore) warnOnStorageEngine(ctx context.Context) {
+	engine, err := mds.StorageEngine(ctx)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("unable to determine the storage engine of the relationship table")
+		return
+	}
+
+	if !strings.EqualFold(engine, migrations.InnoDBStorageEngine) {
+		log.Ctx(ctx).Warn().
+			Str("engine", engine).
+			Str("table", mds.driver.RelationTuple()).
+			Msg("relationship table is not stored with InnoDB; estimated relationship counts may be grossly inaccurate")
+	}
+}
+
+// IsEmpty returns whether the datastore holds no data: no namespace is defined and no live
+// relationship exists in any of the relationship tuple tables. A datastore which was migrated
+// and seeded, but never written to, is empty.
+func (mds *Datastore) IsEmpty(ctx context.Context) (bool, error) {
+	tables := append([]string{mds.driver.Namespace()}, mds.driver.RelationTupleTables()...)
+	for _, table := range tables {
+		hasLiveRows, err := mds.hasLiveRows(ctx, table)
+		if err != nil {
+			return false, err
+		}
+
+		if hasLiveRows {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// hasLiveRows returns whether the given table has a row which has not been deleted. Only a single
+// row is read, so the table is never scanned.
+func (mds *Datastore) hasLiveRows(ctx context.Context, table string) (bool, error) {
+	query, args, err := sb.
+		Select("1").
+		From(table).
+		Where(sq.Eq{colDeletedTxn: liveDeletedTxnID}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	var found int
+	if err := mds.db.QueryRowContext(ctx, query, args...).Scan(&found); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to check for live rows in table `%s`: %w", table, err)
+	}
+
+	return true, nil
+}
+
+// ListNamespaceNames returns the names of the live namespaces, sorted by name. Unlike
+// ListNamespaces, only the name column is read, so no namespace definition is deserialized.
+func (mds *Datastore) ListNamespaceNames(ctx context.Context) ([]string, error) {
+	query, args, err := sb.
+		Select(colNamespace).
+		From(mds.driver.Namespace()).
+		Where(sq.Eq{colDeletedTxn: liveDeletedTxnID}).
+		OrderBy(colNamespace).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rows, err := mds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToListNamespaces, err)
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf(errUnableToListNamespaces, err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(errUnableToListNamespaces, err)
+	}
+
+	return names, nil
+}
+
 // isSeeded determines if the backing database has been seeded
 func (mds *Datastore) isSeeded(ctx context.Context) (bool, error) {
 	headRevision, err := mds.HeadRevision(ctx)
@@ -652,7 +860,7 @@ func (mds *Datastore) seedDatabase(ctx context.Context) error {
 		Insert(mds.driver.Metadata()).
 		Options("IGNORE").
 		Columns(metadataIDColumn, metadataUniqueIDColumn).
-		Values(0, uuid.NewString()).
+		Values(0, newUniqueID()).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("seedDatabase: failed to prepare SQL: %w", err)
@@ -7,11 +7,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"testing"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/go-sql-driver/mysql"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
@@ -96,6 +98,73 @@ func TestMySQLDatastore(t *testing.T) {
 	t.Run("GarbageCollectionByTime", createDatastoreTest(b, GarbageCollectionByTimeTest, defaultOptions...))
 	t.Run("ChunkedGarbageCollection", createDatastoreTest(b, ChunkedGarbageCollectionTest, defaultOptions...))
 	t.Run("TransactionTimestamps", createDatastoreTest(b, TransactionTimestampsTest, defaultOptions...))
+	t.Run("PerNamespaceStatistics", createDatastoreTest(
+		b,
+		PerNamespaceStatisticsTest,
+		append(defaultOptions, WithPerNamespaceStatistics(true))...,
+	))
+	t.Run("SeededUniqueID", func(t *testing.T) {
+		SeededUniqueIDTest(t, b)
+	})
+	t.Run("MissingMetadata", createDatastoreTest(b, MissingMetadataTest, defaultOptions...))
+	t.Run("StatisticsUniqueIDAndCount", createDatastoreTest(b, StatisticsUniqueIDAndCountTest, append(defaultOptions, StatisticsCacheTTL(0))...))
+	t.Run("StatisticsNamespaceReuse", createDatastoreTest(
+		b,
+		StatisticsNamespaceReuseTest,
+		append(defaultOptions, StatisticsCacheTTL(0))...,
+	))
+	t.Run("ExactRelationshipCount", createDatastoreTest(b, ExactRelationshipCountTest, defaultOptions...))
+	t.Run("ExactCountThreshold", createDatastoreTest(
+		b,
+		ExactCountThresholdTest,
+		append(defaultOptions, WithExactCountThreshold(10_000), StatisticsCacheTTL(0))...,
+	))
+	t.Run("ExactCountThresholdDisabled", createDatastoreTest(
+		b,
+		ExactCountThresholdTest,
+		append(defaultOptions, WithExactCountThreshold(0), StatisticsCacheTTL(0))...,
+	))
+	t.Run("StatisticsWithOptions", createDatastoreTest(b, StatisticsWithOptionsTest, defaultOptions...))
+	t.Run("CountsOnly", createDatastoreTest(b, CountsOnlyTest, append(defaultOptions, DebugAnalyzeBeforeStatistics(), StatisticsCacheTTL(0))...))
+	t.Run("EstimateUpdatedAt", createDatastoreTest(b, EstimateUpdatedAtTest, append(defaultOptions, DebugAnalyzeBeforeStatistics(), StatisticsCacheTTL(0))...))
+	t.Run("PeriodicAnalyze", createDatastoreTest(
+		b,
+		PeriodicAnalyzeTest,
+		append(defaultOptions, WithPeriodicAnalyze(10*time.Millisecond), DebugAnalyzeBeforeStatistics(), StatisticsCacheTTL(0))...,
+	))
+	t.Run("PreparedStatisticsStatements", createDatastoreTest(b, PreparedStatisticsStatementsTest, append(defaultOptions, StatisticsCacheTTL(0))...))
+	t.Run("StatisticsAtRevision", createDatastoreTest(b, StatisticsAtRevisionTest, append(defaultOptions, StatisticsCacheTTL(0))...))
+	t.Run("LiveOnlyEstimate", createDatastoreTest(
+		b,
+		LiveOnlyEstimateTest,
+		append(defaultOptions, WithLiveOnlyEstimate(true), DebugAnalyzeBeforeStatistics(), StatisticsCacheTTL(0))...,
+	))
+	t.Run("FreshDatastoreStatistics", createDatastoreTest(
+		b,
+		FreshDatastoreStatisticsTest,
+		RevisionQuantization(0), GCInterval(0), StatisticsCacheTTL(0),
+	))
+	t.Run("EstimatedCountIgnoresOtherSchemas", createDatastoreTest(b, EstimatedCountIgnoresOtherSchemasTest, defaultOptions...))
+	t.Run("ShardedStatistics", createDatastoreTest(
+		b,
+		ShardedStatisticsTest,
+		append(defaultOptions, WithRelationTupleShards("relation_tuple_shard"), WithPerNamespaceStatistics(true), StatisticsCacheTTL(0))...,
+	))
+	t.Run("ReachabilityCacheVersion", createDatastoreTest(b, ReachabilityCacheVersionTest, defaultOptions...))
+	t.Run("Metadata", createDatastoreTest(b, MetadataTest, defaultOptions...))
+	t.Run("Ping", createDatastoreTest(b, PingTest, defaultOptions...))
+	t.Run("StorageEngine", createDatastoreTest(b, StorageEngineTest, defaultOptions...))
+	t.Run("IsEmpty", createDatastoreTest(b, IsEmptyTest, defaultOptions...))
+	t.Run("ListNamespaceNames", createDatastoreTest(b, ListNamespaceNamesTest, defaultOptions...))
+	t.Run("ReadReplica", func(t *testing.T) {
+		ReadReplicaTest(t, b)
+	})
+	t.Run("PoolStats", createDatastoreTest(b, PoolStatsTest, append(defaultOptions, MaxOpenConns(3))...))
+	t.Run("AnalyzeTimeout", createDatastoreTest(
+		b,
+		AnalyzeTimeoutTest,
+		append(defaultOptions, WithAnalyzeTimeout(time.Nanosecond))...,
+	))
 	t.Run("QuantizedRevisions", func(t *testing.T) {
 		QuantizedRevisionTest(t, b)
 	})
@@ -128,9 +197,13 @@ func PrometheusCollectorTest(t *testing.T, ds datastore.Datastore) {
 	_, err := ds.IsReady(context.Background())
 	req.NoError(err)
 
+	// load the namespaces to compute the statistics
+	_, err = ds.Statistics(context.Background())
+	req.NoError(err)
+
 	metrics, err := prometheus.DefaultGatherer.Gather()
 	req.NoError(err, metrics)
-	var collectorStatsFound, connectorStatsFound bool
+	var collectorStatsFound, connectorStatsFound, statisticsStatsFound bool
 	for _, metric := range metrics {
 		if metric.GetName() == "go_sql_stats_connections_open" {
 			collectorStatsFound = true
@@ -138,9 +211,13 @@ func PrometheusCollectorTest(t *testing.T, ds datastore.Datastore) {
 		if metric.GetName() == "spicedb_datastore_mysql_connect_count_total" {
 			connectorStatsFound = true
 		}
+		if metric.GetName() == "spicedb_datastore_mysql_statistics_loaded_namespaces" {
+			statisticsStatsFound = true
+		}
 	}
 	req.True(collectorStatsFound, "mysql datastore did not issue prometheus metrics")
 	req.True(connectorStatsFound, "mysql datastore connector did not issue prometheus metrics")
+	req.True(statisticsStatsFound, "mysql datastore statistics did not issue prometheus metrics")
 }
 
 func GarbageCollectionTest(t *testing.T, ds datastore.Datastore) {
@@ -624,6 +701,596 @@ func TransactionTimestampsTest(t *testing.T, ds datastore.Datastore) {
 	req.Equal(revisionFromTransaction(txID), revision)
 }
 
+func PerNamespaceStatisticsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+
+	expected := map[string]uint64{}
+	for _, tupleStr := range testfixtures.StandardTuples {
+		expected[tuple.MustParse(tupleStr).ObjectAndRelation.Namespace]++
+	}
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(expected, stats.EstimatedRelationshipCountByNamespace)
+}
+
+func StatisticsNamespaceReuseTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithSchema(ds, req)
+	mds := ds.(*Datastore)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	decoded := mds.statsNamespaces.Load().(decodedNamespaces)
+	req.Len(stats.ObjectTypeStatistics, len(decoded.nsDefs))
+
+	// Without any namespace change, the decoded namespaces are reused.
+	_, err = ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(decoded, mds.statsNamespaces.Load().(decodedNamespaces))
+
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(namespace.Namespace("another_namespace"))
+	})
+	req.NoError(err)
+
+	stats, err = ds.Statistics(ctx)
+	req.NoError(err)
+	req.Len(stats.ObjectTypeStatistics, len(decoded.nsDefs)+1)
+
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.DeleteNamespace("another_namespace")
+	})
+	req.NoError(err)
+
+	stats, err = ds.Statistics(ctx)
+	req.NoError(err)
+	req.Len(stats.ObjectTypeStatistics, len(decoded.nsDefs))
+}
+
+func EstimatedCountIgnoresOtherSchemasTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	expected, err := ds.Statistics(ctx)
+	req.NoError(err)
+
+	// Create a table with the same name as the relationships table in another schema on the
+	// same server, with many more rows.
+	otherSchema := fmt.Sprintf("other_schema_%d", time.Now().UnixNano())
+	_, err = mds.db.ExecContext(ctx, "CREATE DATABASE "+otherSchema)
+	req.NoError(err)
+	t.Cleanup(func() {
+		_, err := mds.db.ExecContext(ctx, "DROP DATABASE "+otherSchema)
+		req.NoError(err)
+	})
+
+	otherTable := otherSchema + "." + mds.driver.RelationTuple()
+	_, err = mds.db.ExecContext(ctx, "CREATE TABLE "+otherTable+" (id INT PRIMARY KEY)")
+	req.NoError(err)
+	_, err = mds.db.ExecContext(ctx, "INSERT INTO "+otherTable+" (id) WITH RECURSIVE seq (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 1000) SELECT n FROM seq")
+	req.NoError(err)
+	_, err = mds.db.ExecContext(ctx, "ANALYZE TABLE "+otherTable)
+	req.NoError(err)
+
+	estimate, err := mds.estimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+	req.Equal(expected.EstimatedRelationshipCount, estimate.count)
+}
+
+func ShardedStatisticsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	// Statistics fail until every shard exists.
+	_, err := mds.estimatedRelationshipCount(ctx, mds.db)
+	req.Error(err)
+
+	// Copy the relationships into the shard, doubling the count.
+	tables := mds.driver.RelationTupleTables()
+	req.Len(tables, 2)
+	primary, shard := migrations.QuoteIdentifier(tables[0]), migrations.QuoteIdentifier(tables[1])
+	_, err = mds.db.ExecContext(ctx, "CREATE TABLE "+shard+" LIKE "+primary)
+	req.NoError(err)
+	_, err = mds.db.ExecContext(ctx, "INSERT INTO "+shard+" SELECT * FROM "+primary)
+	req.NoError(err)
+
+	count, err := mds.ExactRelationshipCount(ctx)
+	req.NoError(err)
+	req.Equal(uint64(2*len(testfixtures.StandardTuples)), count)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Greater(stats.EstimatedRelationshipCount, uint64(0))
+
+	var total uint64
+	for _, namespaceCount := range stats.EstimatedRelationshipCountByNamespace {
+		total += namespaceCount
+	}
+	req.Equal(count, total)
+}
+
+func ReachabilityCacheVersionTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+	mds := ds.(*Datastore)
+
+	version, err := mds.ReachabilityCacheVersion(ctx)
+	req.NoError(err)
+	req.Equal(uint32(1), version)
+	req.LessOrEqual(version, CurrentReachabilityCacheVersion)
+
+	req.NoError(mds.SetReachabilityCacheVersion(ctx, CurrentReachabilityCacheVersion+1))
+	version, err = mds.ReachabilityCacheVersion(ctx)
+	req.NoError(err)
+	req.Equal(CurrentReachabilityCacheVersion+1, version)
+
+	// Writing the same version again succeeds.
+	req.NoError(mds.SetReachabilityCacheVersion(ctx, CurrentReachabilityCacheVersion+1))
+}
+
+func MetadataTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+	mds := ds.(*Datastore)
+
+	metadata, err := mds.Metadata(ctx)
+	req.NoError(err)
+	req.Equal(uint32(1), metadata.ReachabilityCacheVersion)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(stats.UniqueID, metadata.UniqueID)
+
+	// The metadata table cannot hold a second row.
+	_, err = mds.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, unique_id) VALUES (1, 'other')", mds.driver.Metadata()))
+	req.Error(err)
+
+	_, err = mds.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", mds.driver.Metadata()))
+	req.NoError(err)
+
+	_, err = mds.Metadata(ctx)
+	req.EqualError(err, errMetadataUninitialized)
+}
+
+func PingTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	mds := ds.(*Datastore)
+
+	req.NoError(mds.Ping(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err := mds.Ping(ctx)
+	req.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func StorageEngineTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+	mds := ds.(*Datastore)
+
+	engine, err := mds.StorageEngine(ctx)
+	req.NoError(err)
+	req.Equal(migrations.InnoDBStorageEngine, engine)
+
+	_, err = mds.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ENGINE = MyISAM", migrations.QuoteIdentifier(mds.driver.RelationTuple())))
+	req.NoError(err)
+
+	engine, err = mds.StorageEngine(ctx)
+	req.NoError(err)
+	req.Equal("MyISAM", engine)
+}
+
+func IsEmptyTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	empty, err := ds.(*Datastore).IsEmpty(ctx)
+	req.NoError(err)
+	req.True(empty)
+
+	// Defining a namespace is enough for the datastore not to be empty.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(testfixtures.UserNS)
+	})
+	req.NoError(err)
+
+	empty, err = ds.(*Datastore).IsEmpty(ctx)
+	req.NoError(err)
+	req.False(empty)
+
+	// Deleting the namespace again leaves the datastore empty.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.DeleteNamespace(testfixtures.UserNS.Name)
+	})
+	req.NoError(err)
+
+	empty, err = ds.(*Datastore).IsEmpty(ctx)
+	req.NoError(err)
+	req.True(empty)
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	empty, err = ds.(*Datastore).IsEmpty(ctx)
+	req.NoError(err)
+	req.False(empty)
+}
+
+func ListNamespaceNamesTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	names, err := ds.(*Datastore).ListNamespaceNames(ctx)
+	req.NoError(err)
+	req.Empty(names)
+
+	ds, revision := testfixtures.StandardDatastoreWithData(ds, req)
+
+	nsDefs, err := ds.SnapshotReader(revision).ListNamespaces(ctx)
+	req.NoError(err)
+
+	expected := make([]string, 0, len(nsDefs))
+	for _, nsDef := range nsDefs {
+		expected = append(expected, nsDef.Name)
+	}
+	sort.Strings(expected)
+
+	names, err = ds.(*Datastore).ListNamespaceNames(ctx)
+	req.NoError(err)
+	req.Equal(expected, names)
+
+	// Deleted namespaces are not listed.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.DeleteNamespace(testfixtures.UserNS.Name)
+	})
+	req.NoError(err)
+
+	names, err = ds.(*Datastore).ListNamespaceNames(ctx)
+	req.NoError(err)
+	req.NotContains(names, testfixtures.UserNS.Name)
+	req.Len(names, len(expected)-1)
+}
+
+func ReadReplicaTest(t *testing.T, b testdatastore.RunningEngineForTest) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	var replica *sql.DB
+	ds := b.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+		var err error
+		replica, err = sql.Open("mysql", uri)
+		req.NoError(err)
+
+		ds, err := NewMySQLDatastore(uri, append(defaultOptions, WithReadReplica(replica), StatisticsCacheTTL(0))...)
+		req.NoError(err)
+		return ds
+	})
+	defer failOnError(t, ds.Close)
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+
+	// The statistics queries run on the replica.
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.NotEmpty(stats.ObjectTypeStatistics)
+	req.NotZero(replica.Stats().OpenConnections)
+
+	// Once the replica is unreachable, the statistics fall back to the primary.
+	req.NoError(replica.Close())
+
+	fallbackStats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(stats.ObjectTypeStatistics, fallbackStats.ObjectTypeStatistics)
+}
+
+func ExactRelationshipCountTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	count, err := mds.ExactRelationshipCount(ctx)
+	req.NoError(err)
+	req.Equal(uint64(len(testfixtures.StandardTuples)), count)
+
+	// Deleted relationships are not counted.
+	deleted := tuple.MustParse(testfixtures.StandardTuples[0])
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+			Relationship: tuple.ToRelationship(deleted),
+		}})
+	})
+	req.NoError(err)
+
+	count, err = mds.ExactRelationshipCount(ctx)
+	req.NoError(err)
+	req.Equal(uint64(len(testfixtures.StandardTuples)-1), count)
+}
+
+func ExactCountThresholdTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	// Deleted relationships remain as rows until garbage collected, so they are included in the
+	// estimate but not in the exact count.
+	deleted := tuple.MustParse(testfixtures.StandardTuples[0])
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+			Relationship: tuple.ToRelationship(deleted),
+		}})
+	})
+	req.NoError(err)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+
+	estimate, err := mds.estimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+
+	if mds.exactCountThreshold == 0 {
+		req.Equal(estimate.count, stats.EstimatedRelationshipCount)
+		return
+	}
+
+	req.Less(estimate.count, mds.exactCountThreshold, "the relationships table must be small")
+	req.Equal(uint64(len(testfixtures.StandardTuples)-1), stats.EstimatedRelationshipCount)
+}
+
+func StatisticsWithOptionsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+	req.False(mds.analyzeBeforeStats)
+
+	stats, err := mds.StatisticsWithOptions(ctx, StatsOptions{ForceAnalyze: true})
+	req.NoError(err)
+	req.NotZero(stats.EstimatedRelationshipCount)
+
+	// The analyzed statistics replace the cached statistics.
+	cached, err := mds.StatisticsWithOptions(ctx, StatsOptions{})
+	req.NoError(err)
+	req.Equal(stats.EstimatedRelationshipCount, cached.EstimatedRelationshipCount)
+	req.Equal(stats.UniqueID, cached.UniqueID)
+}
+
+func CountsOnlyTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	uniqueID, estimated, err := mds.CountsOnly(ctx)
+	req.NoError(err)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(stats.UniqueID, uniqueID)
+	req.Equal(stats.EstimatedRelationshipCount, estimated)
+}
+
+func EstimateUpdatedAtTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+
+	// The tables have just been written and analyzed, so the estimate must be recent.
+	req.NotNil(stats.EstimatedRelationshipCountUpdatedAt)
+	req.WithinDuration(time.Now(), *stats.EstimatedRelationshipCountUpdatedAt, time.Hour)
+}
+
+func PeriodicAnalyzeTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	// Statistics never analyze synchronously, as the tables are analyzed in the background.
+	req.False(mds.analyzeBeforeStats)
+
+	req.Eventually(func() bool {
+		stats, err := ds.Statistics(ctx)
+		req.NoError(err)
+		return stats.EstimatedRelationshipCount > 0
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func PreparedStatisticsStatementsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	first, err := ds.Statistics(ctx)
+	req.NoError(err)
+
+	mds.statsStatements.mu.Lock()
+	prepared := len(mds.statsStatements.stmts)
+	mds.statsStatements.mu.Unlock()
+	req.Positive(prepared)
+
+	// Computing the statistics again reuses the statements prepared by the first computation.
+	second, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(first.UniqueID, second.UniqueID)
+	req.Equal(first.EstimatedRelationshipCount, second.EstimatedRelationshipCount)
+
+	mds.statsStatements.mu.Lock()
+	defer mds.statsStatements.mu.Unlock()
+	req.Len(mds.statsStatements.stmts, prepared)
+}
+
+func StatisticsAtRevisionTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, revision := testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	stats, statsRevision, err := mds.StatisticsAtRevision(ctx)
+	req.NoError(err)
+	req.True(revision.Equal(statsRevision), "expected %s, got %s", revision, statsRevision)
+	req.NotEmpty(stats.ObjectTypeStatistics)
+
+	head, err := ds.HeadRevision(ctx)
+	req.NoError(err)
+	req.True(head.Equal(statsRevision), "expected %s, got %s", head, statsRevision)
+}
+
+func LiveOnlyEstimateTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	deleted := tuple.MustParse(testfixtures.StandardTuples[0])
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+			Relationship: tuple.ToRelationship(deleted),
+		}})
+	})
+	req.NoError(err)
+
+	deletedCount, err := mds.deletedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+	req.Equal(uint64(1), deletedCount)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+
+	estimate, err := mds.estimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+
+	if estimate.count < deletedCount {
+		req.Zero(stats.EstimatedRelationshipCount)
+		return
+	}
+	req.Equal(estimate.count-deletedCount, stats.EstimatedRelationshipCount)
+}
+
+func FreshDatastoreStatisticsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+
+	// The relationship tables have just been created, and neither written nor analyzed.
+	stats, err := ds.Statistics(context.Background())
+	req.NoError(err)
+	req.NotEmpty(stats.UniqueID)
+	req.Zero(stats.EstimatedRelationshipCount)
+	req.Empty(stats.ObjectTypeStatistics)
+}
+
+func SeededUniqueIDTest(t *testing.T, b testdatastore.RunningEngineForTest) {
+	req := require.New(t)
+
+	const expectedUniqueID = "00000000-0000-0000-0000-000000000001"
+	defaultNewUniqueID := newUniqueID
+	newUniqueID = func() string { return expectedUniqueID }
+	defer func() { newUniqueID = defaultNewUniqueID }()
+
+	createDatastoreTest(b, func(t *testing.T, ds datastore.Datastore) {
+		stats, err := ds.Statistics(context.Background())
+		req.NoError(err)
+		req.Equal(expectedUniqueID, stats.UniqueID)
+	}, defaultOptions...)(t)
+}
+
+func MissingMetadataTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	// Simulate a datastore whose metadata row was never inserted.
+	mds := ds.(*Datastore)
+	_, err := mds.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", mds.driver.Metadata()))
+	req.NoError(err)
+
+	_, err = ds.Statistics(ctx)
+	req.ErrorContains(err, errMetadataUninitialized)
+}
+
+func StatisticsUniqueIDAndCountTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+	mds := ds.(*Datastore)
+
+	expectedID, err := mds.getUniqueID(ctx)
+	req.NoError(err)
+	expectedEstimate, err := mds.estimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+
+	// The first call loads the unique ID along with the estimate, and caches it.
+	req.Nil(mds.uniqueID.Load())
+	uniqueID, estimate, err := mds.uniqueIDAndEstimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+	req.Equal(expectedID, uniqueID)
+	req.Equal(expectedEstimate, estimate)
+	req.Equal(expectedID, mds.uniqueID.Load())
+
+	// Later calls only load the estimate.
+	uniqueID, estimate, err = mds.uniqueIDAndEstimatedRelationshipCount(ctx, mds.db)
+	req.NoError(err)
+	req.Equal(expectedID, uniqueID)
+	req.Equal(expectedEstimate, estimate)
+
+	stats, err := ds.Statistics(ctx)
+	req.NoError(err)
+	req.Equal(expectedID, stats.UniqueID)
+}
+
+func PoolStatsTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+
+	_, err := ds.Statistics(context.Background())
+	req.NoError(err)
+
+	poolStats := ds.(*Datastore).PoolStats()
+	req.Equal(3, poolStats.MaxOpenConnections)
+	req.GreaterOrEqual(poolStats.OpenConnections, 1)
+	req.Equal(poolStats.OpenConnections, poolStats.InUse+poolStats.Idle)
+}
+
+func AnalyzeTimeoutTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+
+	ds, _ = testfixtures.StandardDatastoreWithData(ds, req)
+
+	// The analyze cannot complete within the timeout, but statistics must still be returned.
+	stats, err := ds.Statistics(context.Background())
+	req.NoError(err)
+	req.Len(stats.UniqueID, 36)
+	req.Len(stats.ObjectTypeStatistics, 3)
+}
+
 func TestMySQLMigrations(t *testing.T) {
 	req := require.New(t)
 
@@ -645,6 +1312,52 @@ func TestMySQLMigrations(t *testing.T) {
 	req.Equal(headVersion, version)
 }
 
+func TestMySQLMigrationsVerifyConnection(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "")
+
+	// The tables do not exist before migrating.
+	req.Error(migrationDriver.VerifyConnection(ctx))
+
+	err := migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+	req.NoError(migrationDriver.VerifyConnection(ctx))
+
+	// The test database is not connected to over TLS.
+	err = migrationDriver.WithRequiredTLSMode(migrations.TLSModeRequired).VerifyConnection(ctx)
+	req.EqualError(err, "connection is not encrypted with TLS")
+
+	err = migrationDriver.WithRequiredTLSMode(migrations.TLSModeVerifyIdentity).VerifyConnection(ctx)
+	req.Error(err)
+}
+
+func TestMySQLMigrationsStatementError(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "")
+
+	// Creating the relation tuple table, the third statement of the initial migration, fails.
+	_, err := db.ExecContext(ctx, "CREATE TABLE relation_tuple (id BIGINT NOT NULL PRIMARY KEY);")
+	req.NoError(err)
+
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.Error(err)
+
+	var migrationErr *migrations.MigrationError
+	req.ErrorAs(err, &migrationErr)
+	req.Equal("initial", migrationErr.Name)
+	req.Equal(2, migrationErr.StatementIndex)
+	req.Contains(migrationErr.SQL, "CREATE TABLE `relation_tuple`")
+
+	var mysqlErr *mysql.MySQLError
+	req.ErrorAs(err, &mysqlErr)
+}
+
 func TestMySQLMigrationsWithPrefix(t *testing.T) {
 	req := require.New(t)
 
@@ -677,6 +1390,160 @@ func TestMySQLMigrationsWithPrefix(t *testing.T) {
 	req.NoError(rows.Err())
 }
 
+func TestMySQLSubjectIndexMigrationAlreadyApplied(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "")
+
+	err := migrations.Manager.Run(ctx, migrationDriver, "add_unique_datastore_id", migrate.LiveRun)
+	req.NoError(err)
+
+	// Create the index out of band, as if it had been added manually by an operator.
+	_, err = db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE INDEX ix_relation_tuple_by_subject_object ON %s (userset_namespace, userset_object_id, userset_relation)",
+		migrationDriver.RelationTuple(),
+	))
+	req.NoError(err)
+
+	err = migrations.Manager.Run(ctx, migrationDriver, "add_subject_index", migrate.LiveRun)
+	req.NoError(err)
+
+	version, err := migrationDriver.Version(ctx)
+	req.NoError(err)
+	req.Equal("add_subject_index", version)
+}
+
+func TestMySQLMigrationsRollback(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "")
+
+	err := migrations.Manager.Run(ctx, migrationDriver, "initial", migrate.LiveRun)
+	req.NoError(err)
+	initialTables := showTables(t, db)
+
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+	req.Contains(showTables(t, db), migrationDriver.Metadata())
+
+	err = migrations.Manager.Rollback(ctx, migrationDriver, "initial", migrate.LiveRun)
+	req.NoError(err)
+
+	version, err := migrationDriver.Version(ctx)
+	req.NoError(err)
+	req.Equal("initial", version)
+	req.Equal(initialTables, showTables(t, db))
+
+	// Migrating up again after the rollback must succeed.
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+}
+
+func TestMySQLMigrationsAppliedMigrations(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "")
+
+	// Migrations applied before the applied time is recorded are not returned.
+	err := migrations.Manager.Run(ctx, migrationDriver, "add_reachability_cache_version", migrate.LiveRun)
+	req.NoError(err)
+
+	applied, err := migrationDriver.AppliedMigrations(ctx)
+	req.NoError(err)
+	req.Empty(applied)
+
+	before := time.Now().UTC().Add(-time.Second)
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+	after := time.Now().UTC().Add(time.Second)
+
+	applied, err = migrationDriver.AppliedMigrations(ctx)
+	req.NoError(err)
+	req.Len(applied, 2)
+	req.Equal("add_migration_applied_at", applied[0].Name)
+	req.Equal("add_metadata_singleton_check", applied[1].Name)
+	for _, record := range applied {
+		req.Equal(time.UTC, record.AppliedAt.Location())
+		req.True(record.AppliedAt.After(before), "applied at %s, before %s", record.AppliedAt, before)
+		req.True(record.AppliedAt.Before(after), "applied at %s, after %s", record.AppliedAt, after)
+	}
+
+	// Rolling back removes the records, and migrating again records the migration again.
+	err = migrations.Manager.Rollback(ctx, migrationDriver, "add_reachability_cache_version", migrate.LiveRun)
+	req.NoError(err)
+
+	applied, err = migrationDriver.AppliedMigrations(ctx)
+	req.NoError(err)
+	req.Empty(applied)
+
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+
+	applied, err = migrationDriver.AppliedMigrations(ctx)
+	req.NoError(err)
+	req.Len(applied, 2)
+	req.Equal("add_migration_applied_at", applied[0].Name)
+	req.Equal("add_metadata_singleton_check", applied[1].Name)
+}
+
+func TestMySQLMigrationsCollectStatements(t *testing.T) {
+	req := require.New(t)
+	ctx := context.Background()
+
+	db := datastoreDB(t, false)
+	migrationDriver := migrations.NewMySQLDriverFromDB(db, "spicedb_")
+
+	statements, err := migrationDriver.CollectStatements(ctx, migrate.Head)
+	req.NoError(err)
+	req.NotEmpty(statements)
+	req.Contains(statements[0], "CREATE TABLE `spicedb_mysql_migration_version`")
+	req.Contains(statements[len(statements)-2], "_meta_version_add_metadata_singleton_check")
+
+	// The migrations recorded as applied are inserted like in a live run.
+	req.Contains(statements[len(statements)-1], "INSERT INTO `spicedb_mysql_migration_version`")
+	req.Contains(statements[len(statements)-1], "'add_metadata_singleton_check'")
+	req.Contains(statements, "INSERT INTO `spicedb_mysql_migration_version` (`id`, `_meta_version_add_migration_applied_at`, `applied_at`) SELECT COALESCE(MAX(`id`), 0) + 1, 'add_migration_applied_at', UTC_TIMESTAMP(6) FROM `spicedb_mysql_migration_version`")
+
+	// Nothing must have been executed, and the output must be stable.
+	req.Empty(showTables(t, db))
+	version, err := migrationDriver.Version(ctx)
+	req.NoError(err)
+	req.Equal("", version)
+
+	collectedAgain, err := migrationDriver.CollectStatements(ctx, migrate.Head)
+	req.NoError(err)
+	req.Equal(statements, collectedAgain)
+
+	// Once migrated, there is nothing left to run.
+	err = migrations.Manager.Run(ctx, migrationDriver, migrate.Head, migrate.LiveRun)
+	req.NoError(err)
+
+	statements, err = migrationDriver.CollectStatements(ctx, migrate.Head)
+	req.NoError(err)
+	req.Empty(statements)
+}
+
+func showTables(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query("SHOW TABLES;")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var tbl string
+		require.NoError(t, rows.Scan(&tbl))
+		tables = append(tables, tbl)
+	}
+	require.NoError(t, rows.Err())
+	return tables
+}
+
 func datastoreDB(t *testing.T, migrate bool) *sql.DB {
 	var databaseURI string
 	testdatastore.RunMySQLForTestingWithOptions(t, testdatastore.MySQLTesterOptions{MigrateForNewDatastore: migrate}, "").NewDatastore(t, func(engine, uri string) datastore.Datastore {
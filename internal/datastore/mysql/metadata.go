@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
+)
+
+// MetadataRecord is the metadata stored alongside the datastore.
+type MetadataRecord struct {
+	// UniqueID is the unique ID of the datastore, generated when it was seeded.
+	UniqueID string
+
+	// ReachabilityCacheVersion is the version of the reachability caches persisted alongside the
+	// datastore; see ReachabilityCacheVersion.
+	ReachabilityCacheVersion uint32
+}
+
+// Metadata returns the metadata stored alongside the datastore. Returns an error if the datastore
+// has not been seeded, or if the metadata table holds more than a single row, as the metadata
+// would then be ambiguous.
+func (mds *Datastore) Metadata(ctx context.Context) (MetadataRecord, error) {
+	// Two rows are enough to detect ambiguous metadata.
+	query, args, err := sb.
+		Select(metadataUniqueIDColumn, metadataReachabilityCacheVersionColumn).
+		From(mds.driver.Metadata()).
+		Limit(2).
+		ToSql()
+	if err != nil {
+		return MetadataRecord{}, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rows, err := mds.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return MetadataRecord{}, fmt.Errorf("unable to query metadata: %w", err)
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	var records []MetadataRecord
+	for rows.Next() {
+		var record MetadataRecord
+		if err := rows.Scan(&record.UniqueID, &record.ReachabilityCacheVersion); err != nil {
+			return MetadataRecord{}, fmt.Errorf("unable to query metadata: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return MetadataRecord{}, fmt.Errorf("unable to query metadata: %w", err)
+	}
+
+	if err := checkMetadataRowCount(len(records)); err != nil {
+		return MetadataRecord{}, err
+	}
+
+	return records[0], nil
+}
+
+// checkMetadataRowCount returns an error unless the given number of rows loaded from the metadata
+// table, which must be limited to two, is exactly one.
+func checkMetadataRowCount(count int) error {
+	switch count {
+	case 0:
+		return errors.New(errMetadataUninitialized)
+	case 1:
+		return nil
+	default:
+		return errors.New(errMetadataAmbiguous)
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	sqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/pkg/migrate"
 )
 
 const (
@@ -17,6 +20,10 @@ const (
 	mysqlMissingTableErrorNumber = 1146
 
 	migrationVersionColumnPrefix = "_meta_version_"
+
+	// InnoDBStorageEngine is the storage engine with which the tables are created, and for which
+	// the estimated number of rows of a table is maintained.
+	InnoDBStorageEngine = "InnoDB"
 )
 
 var sb = sq.StatementBuilder.PlaceholderFormat(sq.Question)
@@ -25,6 +32,30 @@ var sb = sq.StatementBuilder.PlaceholderFormat(sq.Question)
 type MySQLDriver struct {
 	db *sql.DB
 	*tables
+
+	// collected is non-nil when the driver collects the statements of a dry run instead of
+	// executing them.
+	collected *collectedStatements
+
+	// tlsConfigName is the value of the `tls` parameter of the DSN from which the driver was
+	// created, or nil if the driver was created from a connection pool.
+	tlsConfigName *string
+
+	// requiredTLSMode is the TLS mode checked by VerifyConnection.
+	requiredTLSMode TLSMode
+
+	// slowQueryThreshold is the duration above which a migration statement is logged as slow, or
+	// zero to never log statements as slow.
+	slowQueryThreshold time.Duration
+}
+
+type collectedStatements struct {
+	version    string
+	statements []string
+
+	// recordsAppliedMigrations is whether the migration version table has the column recording
+	// when each migration was applied, as of the collected version.
+	recordsAppliedMigrations bool
 }
 
 // NewMySQLDriverFromDSN creates a new migration driver with a connection pool to the database DSN specified.
@@ -32,11 +63,18 @@ type MySQLDriver struct {
 // URI: [scheme://][user[:[password]]@]host[:port][/schema][?attribute1=value1&attribute2=value2...
 // See https://dev.mysql.com/doc/refman/8.0/en/connecting-using-uri-or-key-value-pairs.html
 func NewMySQLDriverFromDSN(url string, tablePrefix string) (*MySQLDriver, error) {
+	if err := ValidateTablePrefix(tablePrefix); err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
 	dbConfig, err := sqlDriver.ParseDSN(url)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
+	// DATETIME columns are scanned into time.Time, which requires the driver to parse them.
+	dbConfig.ParseTime = true
+
 	db, err := sql.Open("mysql", dbConfig.FormatDSN())
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
@@ -45,12 +83,46 @@ func NewMySQLDriverFromDSN(url string, tablePrefix string) (*MySQLDriver, error)
 	if err != nil {
 		return nil, fmt.Errorf("unable to set logging to mysql driver: %w", err)
 	}
-	return NewMySQLDriverFromDB(db, tablePrefix), nil
+	driver := NewMySQLDriverFromDB(db, tablePrefix)
+	driver.tlsConfigName = &dbConfig.TLSConfig
+	return driver, nil
 }
 
 // NewMySQLDriverFromDB creates a new migration driver with a connection pool specified upfront.
+// The table prefix is expected to have been checked with ValidateTablePrefix. The connections of
+// the pool must parse times (`parseTime=true`).
 func NewMySQLDriverFromDB(db *sql.DB, tablePrefix string) *MySQLDriver {
-	return &MySQLDriver{db, newTables(tablePrefix)}
+	return &MySQLDriver{db: db, tables: newTables(tablePrefix), slowQueryThreshold: DefaultSlowQueryThreshold}
+}
+
+// WithSlowQueryThreshold returns a copy of the driver which logs the migration statements running
+// for longer than the given threshold; see LogSlowQuery. A threshold of zero disables logging.
+func (driver *MySQLDriver) WithSlowQueryThreshold(threshold time.Duration) *MySQLDriver {
+	copied := *driver
+	copied.slowQueryThreshold = threshold
+	return &copied
+}
+
+// WithRelationTupleShards returns a copy of the driver for which the relationship tuples are
+// additionally held in the given shard tables. The shard names are prefixed with the table
+// prefix of the driver, like all other tables. The shards are not created by the migrations.
+func (driver *MySQLDriver) WithRelationTupleShards(shards ...string) *MySQLDriver {
+	sharded := *driver.tables
+	sharded.tableTupleShards = make([]string, 0, len(shards))
+	for _, shard := range shards {
+		sharded.tableTupleShards = append(sharded.tableTupleShards, driver.prefix+shard)
+	}
+
+	copied := *driver
+	copied.tables = &sharded
+	return &copied
+}
+
+// QuoteIdentifier quotes the given identifier, such as a table or column name, with backticks
+// for interpolation into a MySQL statement. Any backtick within the identifier is escaped by
+// doubling it, so the quoted identifier can never terminate early.
+func QuoteIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
 }
 
 // revisionToColumnName generates the column name that will denote a given migration revision
@@ -68,6 +140,10 @@ func columnNameToRevision(columnName string) (string, bool) {
 // Version returns the version of the schema to which the connected database
 // has been migrated.
 func (driver *MySQLDriver) Version(ctx context.Context) (string, error) {
+	if driver.collected != nil {
+		return driver.collected.version, nil
+	}
+
 	query, args, err := sb.Select("*").From(driver.migrationVersion()).ToSql()
 	if err != nil {
 		return "", fmt.Errorf("unable to load driver migration revision: %w", err)
@@ -99,18 +175,111 @@ func (driver *MySQLDriver) Version(ctx context.Context) (string, error) {
 }
 
 // WriteVersion overwrites the _meta_version_ column name which encodes the version
-// of the database schema.
+// of the database schema, and records when the version was applied.
 func (driver *MySQLDriver) WriteVersion(ctx context.Context, version, replaced string) error {
 	stmt := fmt.Sprintf("ALTER TABLE %s CHANGE %s %s VARCHAR(255) NOT NULL",
-		driver.migrationVersion(),
-		revisionToColumnName(replaced),
-		revisionToColumnName(version),
+		QuoteIdentifier(driver.migrationVersion()),
+		QuoteIdentifier(revisionToColumnName(replaced)),
+		QuoteIdentifier(revisionToColumnName(version)),
 	)
+	if driver.collected != nil {
+		driver.collected.statements = append(driver.collected.statements, stmt)
+		driver.collected.version = version
+		driver.collectAppliedMigration(version)
+		return nil
+	}
+
 	if _, err := driver.db.ExecContext(ctx, stmt); err != nil {
 		return fmt.Errorf("unable to version: %w", err)
 	}
 
-	return nil
+	return driver.recordAppliedMigration(ctx, version, replaced)
+}
+
+// CollectStatements returns, in order, the statements that would be executed to migrate the
+// database through the given revision, without executing them. The database is only read, to
+// determine its current version and the state of guarded migrations.
+//
+// The statements include those recording when each migration is applied, once the migration
+// version table records them. The placeholders of these statements are replaced by their values,
+// and the time at which a migration is applied is only known when it runs.
+func (driver *MySQLDriver) CollectStatements(ctx context.Context, throughRevision string) ([]string, error) {
+	version, err := driver.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := driver.recordsAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	collecting := &MySQLDriver{
+		db:     driver.db,
+		tables: driver.tables,
+		collected: &collectedStatements{
+			version:                  version,
+			recordsAppliedMigrations: recorded,
+		},
+	}
+
+	// The migrations are run live against the collecting driver, as that is what invokes the
+	// statement generators; the collecting driver itself never executes them.
+	if err := Manager.Run(ctx, collecting, throughRevision, migrate.LiveRun); err != nil {
+		return nil, err
+	}
+
+	return collecting.collected.statements, nil
+}
+
+// indexExists returns whether the index with the given name exists on the given table in the
+// connected database.
+func (driver *MySQLDriver) indexExists(ctx context.Context, table, index string) (bool, error) {
+	query, args, err := sb.Select("COUNT(*)").
+		From("INFORMATION_SCHEMA.STATISTICS").
+		Where("table_schema = DATABASE()").
+		Where(sq.Eq{"table_name": table, "index_name": index}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	var count int
+	if err := driver.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return false, fmt.Errorf("unable to load index statistics: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// StorageEngine returns the storage engine of the relationship tuple table in the connected
+// database, as reported by INFORMATION_SCHEMA.TABLES. Tables which are not stored with
+// InnoDBStorageEngine may report a grossly inaccurate estimated number of rows.
+func (driver *MySQLDriver) StorageEngine(ctx context.Context) (string, error) {
+	table := driver.RelationTuple()
+	query, args, err := sb.Select("engine").
+		From("INFORMATION_SCHEMA.TABLES").
+		Where("table_schema = DATABASE()").
+		Where(sq.Eq{"table_name": table}).
+		ToSql()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	// The engine is NULL for views.
+	var engine sql.NullString
+	if err := driver.db.QueryRowContext(ctx, query, args...).Scan(&engine); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("table `%s` not found", table)
+		}
+		return "", fmt.Errorf("unable to load storage engine of table `%s`: %w", table, err)
+	}
+
+	if !engine.Valid {
+		return "", fmt.Errorf("table `%s` has no storage engine", table)
+	}
+
+	return engine.String, nil
 }
 
 func (driver *MySQLDriver) Close() error {
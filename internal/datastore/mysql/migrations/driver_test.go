@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	testCases := []struct {
+		identifier string
+		expected   string
+	}{
+		{"relation_tuple", "`relation_tuple`"},
+		{"spicedb_relation_tuple", "`spicedb_relation_tuple`"},
+		{"", "``"},
+		{"odd`; DROP TABLE users; --", "`odd``; DROP TABLE users; --`"},
+		{"``", "``````"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.identifier, func(t *testing.T) {
+			require.Equal(t, tc.expected, QuoteIdentifier(tc.identifier))
+		})
+	}
+}
+
+func TestStatementsQuoteTableNames(t *testing.T) {
+	driver := NewMySQLDriverFromDB(nil, "odd`prefix_")
+
+	require.Contains(t, createRelationTuple(driver), "CREATE TABLE `odd``prefix_relation_tuple` (")
+	require.Equal(t, "DROP INDEX ix_relation_tuple_by_subject_object ON `odd``prefix_relation_tuple`;", dropSubjectIndex(driver))
+	require.Equal(t, "DROP TABLE `odd``prefix_mysql_metadata`;", dropMetadataTable(driver))
+	require.Equal(t, "ALTER TABLE `odd``prefix_mysql_metadata` DROP INDEX `odd``prefix_uq_metadata_singleton`, DROP COLUMN `singleton`;", dropMetadataSingletonKey(driver))
+}
+
+func TestCollectsAppliedMigrationRecords(t *testing.T) {
+	ctx := context.Background()
+
+	driver := NewMySQLDriverFromDB(nil, "spicedb_")
+	driver.collected = &collectedStatements{version: "add_subject_index"}
+
+	// Migrations are only recorded once the column recording them has been added.
+	require.NoError(t, driver.WriteVersion(ctx, "add_reachability_cache_version", "add_subject_index"))
+	require.NoError(t, driver.WriteVersion(ctx, "add_migration_applied_at", "add_reachability_cache_version"))
+	require.NoError(t, driver.WriteVersion(ctx, "add_metadata_singleton_check", "add_migration_applied_at"))
+
+	require.Equal(t, "add_metadata_singleton_check", driver.collected.version)
+	require.Equal(t, []string{
+		"ALTER TABLE `spicedb_mysql_migration_version` CHANGE `_meta_version_add_subject_index` `_meta_version_add_reachability_cache_version` VARCHAR(255) NOT NULL",
+		"ALTER TABLE `spicedb_mysql_migration_version` CHANGE `_meta_version_add_reachability_cache_version` `_meta_version_add_migration_applied_at` VARCHAR(255) NOT NULL",
+		"INSERT INTO `spicedb_mysql_migration_version` (`id`, `_meta_version_add_migration_applied_at`, `applied_at`) SELECT COALESCE(MAX(`id`), 0) + 1, 'add_migration_applied_at', UTC_TIMESTAMP(6) FROM `spicedb_mysql_migration_version`",
+		"ALTER TABLE `spicedb_mysql_migration_version` CHANGE `_meta_version_add_migration_applied_at` `_meta_version_add_metadata_singleton_check` VARCHAR(255) NOT NULL",
+		"INSERT INTO `spicedb_mysql_migration_version` (`id`, `_meta_version_add_metadata_singleton_check`, `applied_at`) SELECT COALESCE(MAX(`id`), 0) + 1, 'add_metadata_singleton_check', UTC_TIMESTAMP(6) FROM `spicedb_mysql_migration_version`",
+	}, driver.collected.statements)
+}
+
+func TestQuoteString(t *testing.T) {
+	require.Equal(t, "'add_subject_index'", quoteString("add_subject_index"))
+	require.Equal(t, `'it''s \\ odd'`, quoteString(`it's \ odd`))
+}
+
+func TestVerifiesServerIdentity(t *testing.T) {
+	testCases := []struct {
+		name          string
+		tlsConfigName *string
+		expectedError string
+	}{
+		{"connection pool", nil, "unable to verify server identity verification for a driver created from a connection pool"},
+		{"no tls", stringPtr(""), "connection is not configured to use TLS"},
+		{"tls disabled", stringPtr("false"), "connection is not configured to use TLS"},
+		{"skip verify", stringPtr("skip-verify"), "connection is configured with `tls=skip-verify`, which does not verify the server identity"},
+		{"preferred", stringPtr("preferred"), "connection is configured with `tls=preferred`, which does not verify the server identity"},
+		{"tls enabled", stringPtr("true"), ""},
+		{"custom config", stringPtr("custom"), ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifiesServerIdentity(tc.tlsConfigName)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestDriverFromDSNKnowsTLSConfig(t *testing.T) {
+	driver, err := NewMySQLDriverFromDSN("root:secret@tcp(localhost:3306)/spicedb?tls=skip-verify", "")
+	require.NoError(t, err)
+	require.Equal(t, "skip-verify", *driver.WithRequiredTLSMode(TLSModeVerifyIdentity).tlsConfigName)
+	require.Equal(t, TLSModeNone, driver.requiredTLSMode)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -4,12 +4,42 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 )
 
+// MigrationError is returned when a statement of a migration fails to run.
+type MigrationError struct {
+	// Name is the version of the migration which failed.
+	Name string
+
+	// StatementIndex is the index of the failed statement, in the order in which the statements
+	// of the migration, or of its rollback, are run.
+	StatementIndex int
+
+	// SQL is the failed statement.
+	SQL string
+
+	// Err is the error returned by MySQL.
+	Err error
+}
+
+func (err *MigrationError) Error() string {
+	if err.Name == "" {
+		return fmt.Sprintf("failed to run statement %d: %v", err.StatementIndex, err.Err)
+	}
+	return fmt.Sprintf("failed to run statement %d of migration %s: %v", err.StatementIndex, err.Name, err.Err)
+}
+
+// Unwrap returns the error returned by MySQL.
+func (err *MigrationError) Unwrap() error {
+	return err.Err
+}
+
 type driverExecutor func(mysqlDriver *MySQLDriver) string
 
 type executor struct {
-	statements []driverExecutor
+	statements     []driverExecutor
+	downStatements []driverExecutor
 }
 
 func newExecutor(statements ...driverExecutor) executor {
@@ -18,21 +48,63 @@ func newExecutor(statements ...driverExecutor) executor {
 	}
 }
 
+// withDown returns a copy of the executor which reverts the migration by running the given
+// statements, in reverse order.
+func (e executor) withDown(downStatements ...driverExecutor) executor {
+	e.downStatements = downStatements
+	return e
+}
+
 func (e executor) migrate(driver *MySQLDriver) error {
 	if len(e.statements) == 0 {
 		return errors.New("executor.migrate: No statements to migrate")
 	}
 
+	if err := runStatements(driver, e.statements); err != nil {
+		return fmt.Errorf("executor.migrate: %w", err)
+	}
+
+	return nil
+}
+
+func (e executor) rollback(driver *MySQLDriver) error {
+	if len(e.downStatements) == 0 {
+		return errors.New("executor.rollback: No statements to roll back")
+	}
+
+	reversed := make([]driverExecutor, 0, len(e.downStatements))
+	for i := len(e.downStatements) - 1; i >= 0; i-- {
+		reversed = append(reversed, e.downStatements[i])
+	}
+
+	if err := runStatements(driver, reversed); err != nil {
+		return fmt.Errorf("executor.rollback: %w", err)
+	}
+
+	return nil
+}
+
+func runStatements(driver *MySQLDriver, statements []driverExecutor) error {
+	if driver.collected != nil {
+		for _, stmt := range statements {
+			driver.collected.statements = append(driver.collected.statements, stmt(driver))
+		}
+		return nil
+	}
+
 	tx, err := driver.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer LogOnError(context.Background(), tx.Rollback)
 
-	for _, stmt := range e.statements {
-		_, err := tx.Exec(stmt(driver))
+	for index, stmt := range statements {
+		sql := stmt(driver)
+		start := time.Now()
+		_, err := tx.Exec(sql)
+		LogSlowQuery(context.Background(), driver.slowQueryThreshold, time.Since(start), sql, "")
 		if err != nil {
-			return fmt.Errorf("executor.migrate: failed to run statement: %w", err)
+			return &MigrationError{StatementIndex: index, SQL: sql, Err: err}
 		}
 	}
 
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationErrorHoldsMigrationName(t *testing.T) {
+	req := require.New(t)
+
+	statementErr := errors.New("table already exists")
+	up := withMigrationName("add_subject_index", func(driver *MySQLDriver) error {
+		return fmt.Errorf("executor.migrate: %w", &MigrationError{StatementIndex: 1, SQL: "CREATE INDEX", Err: statementErr})
+	}).(func(*MySQLDriver) error)
+
+	err := up(nil)
+	req.EqualError(err, "failed to run statement 1 of migration add_subject_index: table already exists")
+	req.ErrorIs(err, statementErr)
+
+	var migrationErr *MigrationError
+	req.ErrorAs(err, &migrationErr)
+	req.Equal("add_subject_index", migrationErr.Name)
+	req.Equal(1, migrationErr.StatementIndex)
+	req.Equal("CREATE INDEX", migrationErr.SQL)
+
+	req.Nil(withMigrationName("add_subject_index", nil))
+}
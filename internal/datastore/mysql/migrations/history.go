@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	colID        = "id"
+	colAppliedAt = "applied_at"
+)
+
+// MigrationRecord records a migration applied to the database.
+type MigrationRecord struct {
+	// Name is the name of the migration.
+	Name string
+
+	// AppliedAt is the time, in UTC, at which the migration was applied.
+	AppliedAt time.Time
+}
+
+// AppliedMigrations returns the migrations applied to the database, in the order in which they
+// were applied. Migrations applied before the add_migration_applied_at migration are not
+// recorded, and are therefore not returned.
+func (driver *MySQLDriver) AppliedMigrations(ctx context.Context) ([]MigrationRecord, error) {
+	version, err := driver.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := driver.recordsAppliedMigrations(ctx)
+	if err != nil || !recorded {
+		return nil, err
+	}
+
+	query, args, err := sb.
+		Select(QuoteIdentifier(revisionToColumnName(version)), colAppliedAt).
+		From(driver.migrationVersion()).
+		OrderBy(colID).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rows, err := driver.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load applied migrations: %w", err)
+	}
+	defer LogOnError(ctx, rows.Close)
+
+	var records []MigrationRecord
+	for rows.Next() {
+		var name string
+		var appliedAt time.Time
+		if err := rows.Scan(&name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("unable to load applied migrations: %w", err)
+		}
+
+		records = append(records, MigrationRecord{Name: name, AppliedAt: asUTC(appliedAt)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to load applied migrations: %w", err)
+	}
+
+	return records, nil
+}
+
+// asUTC returns the wall clock time of the given time in UTC. The applied_at column holds UTC
+// times, which the driver parses in the location of the connection (`loc`).
+func asUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// recordAppliedMigration records that the database was migrated to the given version from the
+// replaced version, once the add_migration_applied_at migration has been run. The version is
+// recorded as applied at the current UTC time if it has not been applied before. Otherwise,
+// the database was rolled back to the version, and the replaced version is no longer applied.
+func (driver *MySQLDriver) recordAppliedMigration(ctx context.Context, version, replaced string) error {
+	recorded, err := driver.recordsAppliedMigrations(ctx)
+	if err != nil || !recorded {
+		return err
+	}
+
+	versionColumn := revisionToColumnName(version)
+	query, args, err := sb.
+		Select("COUNT(*)").
+		From(driver.migrationVersion()).
+		Where(sq.Eq{QuoteIdentifier(versionColumn): version}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	var count int
+	if err := driver.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return fmt.Errorf("unable to load applied migration `%s`: %w", version, err)
+	}
+
+	if count > 0 {
+		stmt, args, err := sb.
+			Delete(driver.migrationVersion()).
+			Where(sq.Eq{QuoteIdentifier(versionColumn): replaced}).
+			ToSql()
+		if err != nil {
+			return fmt.Errorf("unable to generate query sql: %w", err)
+		}
+
+		if _, err := driver.db.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("unable to remove rolled back migration `%s`: %w", replaced, err)
+		}
+		return nil
+	}
+
+	if _, err := driver.db.ExecContext(ctx, driver.insertAppliedMigration(version), version); err != nil {
+		return fmt.Errorf("unable to record applied migration `%s`: %w", version, err)
+	}
+
+	return nil
+}
+
+// collectAppliedMigration collects the statement which records that the database was migrated to
+// the given version, as recordAppliedMigration would run it. The column recording applied
+// migrations is added by the add_migration_applied_at migration. Statements are only collected to
+// migrate forward, so the version is never found already applied and is always inserted.
+func (driver *MySQLDriver) collectAppliedMigration(version string) {
+	if version == appliedAtMigrationName {
+		driver.collected.recordsAppliedMigrations = true
+	}
+
+	if !driver.collected.recordsAppliedMigrations {
+		return
+	}
+
+	stmt := strings.Replace(driver.insertAppliedMigration(version), "?", quoteString(version), 1)
+	driver.collected.statements = append(driver.collected.statements, stmt)
+}
+
+// insertAppliedMigration returns the statement recording that the version given as its only
+// parameter was applied at the current UTC time. The IDs are allocated in order of application,
+// as the table has no auto-increment key.
+func (driver *MySQLDriver) insertAppliedMigration(version string) string {
+	return fmt.Sprintf("INSERT INTO %[1]s (%[2]s, %[3]s, %[4]s) SELECT COALESCE(MAX(%[2]s), 0) + 1, ?, UTC_TIMESTAMP(6) FROM %[1]s",
+		QuoteIdentifier(driver.migrationVersion()),
+		QuoteIdentifier(colID),
+		QuoteIdentifier(revisionToColumnName(version)),
+		QuoteIdentifier(colAppliedAt),
+	)
+}
+
+// quoteString quotes the given value as a MySQL string literal, escaping quotes and backslashes.
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(value) + "'"
+}
+
+// recordsAppliedMigrations returns whether the migration version table has the column recording
+// when each migration was applied.
+func (driver *MySQLDriver) recordsAppliedMigrations(ctx context.Context) (bool, error) {
+	query, args, err := sb.Select("COUNT(*)").
+		From("INFORMATION_SCHEMA.COLUMNS").
+		Where("table_schema = DATABASE()").
+		Where(sq.Eq{"table_name": driver.migrationVersion(), "column_name": colAppliedAt}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	var count int
+	if err := driver.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return false, fmt.Errorf("unable to load migration version columns: %w", err)
+	}
+
+	return count > 0, nil
+}
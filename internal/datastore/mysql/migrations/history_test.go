@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsUTC(t *testing.T) {
+	local := time.FixedZone("local", 2*60*60)
+	parsed := time.Date(2022, time.March, 4, 5, 6, 7, 8000, local)
+
+	require.Equal(t, time.Date(2022, time.March, 4, 5, 6, 7, 8000, time.UTC), asUTC(parsed))
+	require.Equal(t, time.UTC, asUTC(parsed).Location())
+}
@@ -1,6 +1,7 @@
 package migrations
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 
@@ -16,13 +17,13 @@ var (
 	Manager = migrate.NewManager()
 )
 
-func mustRegisterMigration(version, replaces string, up interface{}) {
-	if err := registerMigration(version, replaces, up); err != nil {
+func mustRegisterMigration(version, replaces string, up, down interface{}) {
+	if err := registerMigration(version, replaces, up, down); err != nil {
 		panic("failed to register migration  " + err.Error())
 	}
 }
 
-func registerMigration(version, replaces string, up interface{}) error {
+func registerMigration(version, replaces string, up, down interface{}) error {
 	// validate migration names to ensure they are compatible with mysql column names
 	for _, v := range []string{version, replaces} {
 		if match := migrationNameRe.MatchString(version); !match {
@@ -33,5 +34,27 @@ func registerMigration(version, replaces string, up interface{}) error {
 	}
 
 	// register the migration
-	return Manager.Register(version, replaces, up)
+	return Manager.RegisterWithDown(version, replaces, withMigrationName(version, up), withMigrationName(version, down))
+}
+
+// withMigrationName wraps the given migration function such that, if it fails to run a statement,
+// it returns the MigrationError for that statement holding the version of the migration. Functions
+// of other types are returned unchanged, to be rejected by the manager.
+func withMigrationName(version string, migration interface{}) interface{} {
+	migrationFunc, ok := migration.(func(*MySQLDriver) error)
+	if !ok {
+		return migration
+	}
+
+	return func(driver *MySQLDriver) error {
+		err := migrationFunc(driver)
+
+		var migrationErr *MigrationError
+		if errors.As(err, &migrationErr) {
+			named := *migrationErr
+			named.Name = version
+			return &named
+		}
+		return err
+	}
 }
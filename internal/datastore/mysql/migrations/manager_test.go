@@ -0,0 +1,11 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisteredMigrationsAreValid(t *testing.T) {
+	require.NoError(t, Manager.Validate())
+}
@@ -11,6 +11,6 @@ import (
 
 func TestMySQLMigrationsWithUnsupportedPrefix(t *testing.T) {
 	req := require.New(t)
-	err := registerMigration("888", "", struct{}{})
+	err := registerMigration("888", "", struct{}{}, nil)
 	req.Error(err)
 }
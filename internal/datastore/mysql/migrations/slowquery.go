@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultSlowQueryThreshold is the default duration above which a query is logged as slow.
+	DefaultSlowQueryThreshold = time.Second
+
+	// maxLoggedQueryLength is the maximum length of the SQL logged for a slow query, beyond which
+	// it is truncated.
+	maxLoggedQueryLength = 512
+)
+
+// LogSlowQuery logs the query at warn level if its duration exceeds the given threshold. A
+// threshold of zero disables logging. The unique ID of the datastore is logged if non-empty, as
+// it is unknown until the datastore has been migrated and seeded.
+func LogSlowQuery(ctx context.Context, threshold time.Duration, duration time.Duration, query string, uniqueID string) {
+	if threshold <= 0 || duration <= threshold {
+		return
+	}
+
+	event := log.Ctx(ctx).Warn().
+		Dur("duration", duration).
+		Dur("threshold", threshold).
+		Str("query", sanitizeQuery(query))
+	if uniqueID != "" {
+		event = event.Str("uniqueID", uniqueID)
+	}
+	event.Msg("slow mysql query")
+}
+
+// sanitizeQuery returns the query on a single line, truncated to a loggable length. The queries
+// logged are built with placeholders for their arguments, which are never logged.
+func sanitizeQuery(query string) string {
+	sanitized := strings.Join(strings.Fields(query), " ")
+	if len(sanitized) > maxLoggedQueryLength {
+		return sanitized[:maxLoggedQueryLength] + "..."
+	}
+
+	return sanitized
+}
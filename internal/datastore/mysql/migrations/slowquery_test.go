@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSlowQuery(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		duration  time.Duration
+		uniqueID  string
+		logged    bool
+	}{
+		{"fast", time.Second, time.Millisecond, "someid", false},
+		{"at threshold", time.Second, time.Second, "someid", false},
+		{"slow", time.Second, 2 * time.Second, "someid", true},
+		{"slow without unique ID", time.Second, 2 * time.Second, "", true},
+		{"disabled", 0, time.Hour, "someid", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			var buf bytes.Buffer
+			logger := zerolog.New(&buf)
+			ctx := logger.WithContext(context.Background())
+
+			LogSlowQuery(ctx, tc.threshold, tc.duration, "SELECT COUNT(*)\n\tFROM relation_tuple", tc.uniqueID)
+			if !tc.logged {
+				require.Empty(buf.String())
+				return
+			}
+
+			logged := buf.String()
+			require.Contains(logged, `"level":"warn"`)
+			require.Contains(logged, `"query":"SELECT COUNT(*) FROM relation_tuple"`)
+			require.Equal(tc.uniqueID != "", strings.Contains(logged, `"uniqueID":"someid"`))
+		})
+	}
+}
+
+func TestSanitizeQuery(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("SELECT 1 FROM metadata", sanitizeQuery("  SELECT 1\n  FROM   metadata\n"))
+
+	sanitized := sanitizeQuery("SELECT " + strings.Repeat("a", 2*maxLoggedQueryLength))
+	require.Len(sanitized, maxLoggedQueryLength+len("..."))
+	require.True(strings.HasSuffix(sanitized, "..."))
+}
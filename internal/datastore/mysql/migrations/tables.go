@@ -1,6 +1,9 @@
 package migrations
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+)
 
 const (
 	tableNamespaceDefault   = "namespace_config"
@@ -10,16 +13,47 @@ const (
 	tableMetadataDefault    = "mysql_metadata"
 )
 
+const (
+	tablePrefixPattern = `^[a-zA-Z0-9_]*$`
+
+	// maxIdentifierLength is the maximum length of a MySQL table name.
+	maxIdentifierLength = 64
+
+	// maxTablePrefixLength is the maximum length of a table prefix, such that the longest table
+	// name remains a valid MySQL table name.
+	maxTablePrefixLength = maxIdentifierLength - len(tableTransactionDefault)
+)
+
+var tablePrefixRe = regexp.MustCompile(tablePrefixPattern)
+
+// ValidateTablePrefix returns an error if the given table prefix cannot safely prefix the names of
+// the tables: it may only hold ASCII letters, digits and underscores, and must leave the longest
+// table name within the maximum length of a MySQL table name.
+func ValidateTablePrefix(prefix string) error {
+	if !tablePrefixRe.MatchString(prefix) {
+		return fmt.Errorf("invalid table prefix '%s': expected to match pattern '%s'", prefix, tablePrefixPattern)
+	}
+
+	if len(prefix) > maxTablePrefixLength {
+		return fmt.Errorf("invalid table prefix '%s': expected at most %d characters", prefix, maxTablePrefixLength)
+	}
+
+	return nil
+}
+
 type tables struct {
+	prefix                string
 	tableMigrationVersion string
 	tableTransaction      string
 	tableTuple            string
+	tableTupleShards      []string
 	tableNamespace        string
 	tableMetadata         string
 }
 
 func newTables(prefix string) *tables {
 	return &tables{
+		prefix:                prefix,
 		tableMigrationVersion: fmt.Sprintf("%s%s", prefix, tableMigrationVersion),
 		tableTransaction:      fmt.Sprintf("%s%s", prefix, tableTransactionDefault),
 		tableTuple:            fmt.Sprintf("%s%s", prefix, tableTupleDefault),
@@ -42,11 +76,40 @@ func (tn *tables) RelationTuple() string {
 	return tn.tableTuple
 }
 
+// RelationTupleTables returns the prefixed names of all the physical tables holding
+// relationship tuples: the relationship tuple table, followed by any configured shards.
+func (tn *tables) RelationTupleTables() []string {
+	tables := make([]string, 0, len(tn.tableTupleShards)+1)
+	tables = append(tables, tn.tableTuple)
+	return append(tables, tn.tableTupleShards...)
+}
+
 // Namespace returns the prefixed namespace table name.
 func (tn *tables) Namespace() string {
 	return tn.tableNamespace
 }
 
+// Metadata returns the prefixed metadata table name.
 func (tn *tables) Metadata() string {
 	return tn.tableMetadata
 }
+
+// TableNames holds the prefixed names of all the tables used by the MySQL datastore.
+type TableNames struct {
+	RelationTuple            string
+	RelationTupleTransaction string
+	Namespace                string
+	Metadata                 string
+	MigrationVersion         string
+}
+
+// TableNames returns the prefixed names of all the tables used by the MySQL datastore.
+func (tn *tables) TableNames() TableNames {
+	return TableNames{
+		RelationTuple:            tn.tableTuple,
+		RelationTupleTransaction: tn.tableTransaction,
+		Namespace:                tn.tableNamespace,
+		Metadata:                 tn.tableMetadata,
+		MigrationVersion:         tn.tableMigrationVersion,
+	}
+}
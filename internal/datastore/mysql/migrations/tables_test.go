@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableNames(t *testing.T) {
+	testCases := []struct {
+		prefix   string
+		expected TableNames
+	}{
+		{"", TableNames{
+			RelationTuple:            "relation_tuple",
+			RelationTupleTransaction: "relation_tuple_transaction",
+			Namespace:                "namespace_config",
+			Metadata:                 "mysql_metadata",
+			MigrationVersion:         "mysql_migration_version",
+		}},
+		{"spicedb_", TableNames{
+			RelationTuple:            "spicedb_relation_tuple",
+			RelationTupleTransaction: "spicedb_relation_tuple_transaction",
+			Namespace:                "spicedb_namespace_config",
+			Metadata:                 "spicedb_mysql_metadata",
+			MigrationVersion:         "spicedb_mysql_migration_version",
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.prefix, func(t *testing.T) {
+			driver := NewMySQLDriverFromDB(nil, tc.prefix)
+			require.Equal(t, tc.expected, driver.TableNames())
+		})
+	}
+}
+
+func TestRelationTupleTables(t *testing.T) {
+	require := require.New(t)
+
+	driver := NewMySQLDriverFromDB(nil, "spicedb_")
+	require.Equal([]string{"spicedb_relation_tuple"}, driver.RelationTupleTables())
+
+	sharded := driver.WithRelationTupleShards("relation_tuple_1", "relation_tuple_2")
+	require.Equal([]string{
+		"spicedb_relation_tuple",
+		"spicedb_relation_tuple_1",
+		"spicedb_relation_tuple_2",
+	}, sharded.RelationTupleTables())
+	require.Equal(driver.TableNames(), sharded.TableNames())
+
+	// The original driver is left unsharded.
+	require.Equal([]string{"spicedb_relation_tuple"}, driver.RelationTupleTables())
+}
+
+func TestValidateTablePrefix(t *testing.T) {
+	testCases := []struct {
+		prefix        string
+		expectedError string
+	}{
+		{"", ""},
+		{"spicedb_", ""},
+		{"Tenant42_", ""},
+		{"spice db_", "invalid table prefix 'spice db_': expected to match pattern '^[a-zA-Z0-9_]*$'"},
+		{"spicedb`_", "invalid table prefix 'spicedb`_': expected to match pattern '^[a-zA-Z0-9_]*$'"},
+		{"spicedb-", "invalid table prefix 'spicedb-': expected to match pattern '^[a-zA-Z0-9_]*$'"},
+		{strings.Repeat("a", 39), "invalid table prefix '" + strings.Repeat("a", 39) + "': expected at most 38 characters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.prefix, func(t *testing.T) {
+			err := ValidateTablePrefix(tc.prefix)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestDriverFromDSNRejectsInvalidTablePrefix(t *testing.T) {
+	_, err := NewMySQLDriverFromDSN("root:secret@tcp(localhost:3306)/spicedb", "spice db_")
+	require.EqualError(t, err, "unable to instantiate MySQLDriver: invalid table prefix 'spice db_': expected to match pattern '^[a-zA-Z0-9_]*$'")
+}
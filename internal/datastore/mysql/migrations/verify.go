@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TLSMode is the TLS protection required of the connections of a MySQLDriver.
+type TLSMode int
+
+const (
+	// TLSModeNone requires no TLS protection.
+	TLSModeNone TLSMode = iota
+
+	// TLSModeRequired requires the connection to be encrypted with TLS.
+	TLSModeRequired
+
+	// TLSModeVerifyIdentity requires the connection to be encrypted with TLS, and the identity of
+	// the server to be verified against its certificate.
+	TLSModeVerifyIdentity
+)
+
+const querySessionSSLCipher = "SHOW SESSION STATUS LIKE 'Ssl_cipher'"
+
+// WithRequiredTLSMode returns a copy of the driver for which VerifyConnection requires the given
+// TLS mode. TLSModeVerifyIdentity can only be verified for a driver created with
+// NewMySQLDriverFromDSN, as the TLS configuration of a connection pool cannot be inspected.
+func (driver *MySQLDriver) WithRequiredTLSMode(mode TLSMode) *MySQLDriver {
+	copied := *driver
+	copied.requiredTLSMode = mode
+	return &copied
+}
+
+// VerifyConnection checks, over a single connection of the pool, that the connection meets the
+// TLS mode required by WithRequiredTLSMode and that the migration version and metadata tables can
+// be read. This can be called at startup to assert that the secured connection to the database
+// works end to end before serving traffic.
+func (driver *MySQLDriver) VerifyConnection(ctx context.Context) error {
+	if driver.requiredTLSMode == TLSModeVerifyIdentity {
+		if err := verifiesServerIdentity(driver.tlsConfigName); err != nil {
+			return err
+		}
+	}
+
+	conn, err := driver.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect to verify connection: %w", err)
+	}
+	defer LogOnError(ctx, conn.Close)
+
+	if driver.requiredTLSMode != TLSModeNone {
+		var variableName, cipher string
+		if err := conn.QueryRowContext(ctx, querySessionSSLCipher).Scan(&variableName, &cipher); err != nil {
+			return fmt.Errorf("unable to read TLS state of connection: %w", err)
+		}
+
+		if cipher == "" {
+			return errors.New("connection is not encrypted with TLS")
+		}
+	}
+
+	for _, table := range []string{driver.migrationVersion(), driver.Metadata()} {
+		query, args, err := sb.Select("1").From(table).Limit(1).ToSql()
+		if err != nil {
+			return fmt.Errorf("unable to generate query sql: %w", err)
+		}
+
+		var found int
+		if err := conn.QueryRowContext(ctx, query, args...).Scan(&found); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("unable to read table `%s`: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// verifiesServerIdentity returns an error unless the given `tls` DSN parameter, if known, verifies
+// the identity of the server. A custom TLS configuration registered with the MySQL driver is
+// assumed to verify the identity of the server, as registered configurations cannot be inspected.
+func verifiesServerIdentity(tlsConfigName *string) error {
+	if tlsConfigName == nil {
+		return errors.New("unable to verify server identity verification for a driver created from a connection pool")
+	}
+
+	switch *tlsConfigName {
+	case "", "false":
+		return errors.New("connection is not configured to use TLS")
+	case "skip-verify", "preferred":
+		return fmt.Errorf("connection is configured with `tls=%s`, which does not verify the server identity", *tlsConfigName)
+	default:
+		return nil
+	}
+}
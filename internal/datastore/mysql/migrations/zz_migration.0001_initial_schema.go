@@ -9,7 +9,7 @@ func createMigrationVersion(driver *MySQLDriver) string {
 	return fmt.Sprintf(`CREATE TABLE %s (
 		id int(11) NOT NULL PRIMARY KEY,
 		_meta_version_ VARCHAR(255) NOT NULL) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-		driver.migrationVersion(),
+		QuoteIdentifier(driver.migrationVersion()),
 	)
 }
 
@@ -22,7 +22,7 @@ func createNamespaceConfig(driver *MySQLDriver) string {
 		deleted_transaction BIGINT NOT NULL DEFAULT '9223372036854775807',
 		CONSTRAINT pk_namespace_config PRIMARY KEY (namespace, created_transaction),
 		CONSTRAINT uq_namespace_living UNIQUE (namespace, deleted_transaction)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-		driver.Namespace(),
+		QuoteIdentifier(driver.Namespace()),
 	)
 }
 
@@ -45,7 +45,7 @@ func createRelationTuple(driver *MySQLDriver) string {
         INDEX ix_relation_tuple_by_subject (userset_object_id, userset_namespace, userset_relation, namespace, relation),
         INDEX ix_relation_tuple_by_subject_relation (userset_namespace, userset_relation, namespace, relation),
         INDEX ix_relation_tuple_by_deleted_transaction (deleted_transaction)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-		driver.RelationTuple(),
+		QuoteIdentifier(driver.RelationTuple()),
 	)
 }
 
@@ -55,7 +55,7 @@ func createRelationTupleTransaction(driver *MySQLDriver) string {
 		timestamp DATETIME(6) DEFAULT NOW(6) NOT NULL,
 		PRIMARY KEY (id),
         INDEX ix_relation_tuple_transaction_by_timestamp (timestamp)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-		driver.RelationTupleTransaction(),
+		QuoteIdentifier(driver.RelationTupleTransaction()),
 	)
 }
 
@@ -67,5 +67,6 @@ func init() {
 			createRelationTuple,
 			createRelationTupleTransaction,
 		).migrate,
+		nil,
 	)
 }
@@ -8,14 +8,23 @@ func createMetadataTable(driver *MySQLDriver) string {
 	return fmt.Sprintf(`CREATE TABLE %s (
 		id BIGINT UNSIGNED NOT NULL PRIMARY KEY,
 		unique_id VARCHAR(36));`,
-		driver.Metadata(),
+		QuoteIdentifier(driver.Metadata()),
 	)
 }
 
+func dropMetadataTable(driver *MySQLDriver) string {
+	return fmt.Sprintf(`DROP TABLE %s;`, QuoteIdentifier(driver.Metadata()))
+}
+
 func init() {
+	metadataExecutor := newExecutor(
+		createMetadataTable,
+	).withDown(
+		dropMetadataTable,
+	)
+
 	mustRegisterMigration("add_unique_datastore_id", "initial",
-		newExecutor(
-			createMetadataTable,
-		).migrate,
+		metadataExecutor.migrate,
+		metadataExecutor.rollback,
 	)
 }
@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+)
+
+const subjectIndexName = "ix_relation_tuple_by_subject_object"
+
+func createSubjectIndex(driver *MySQLDriver) string {
+	return fmt.Sprintf(`CREATE INDEX %s ON %s (userset_namespace, userset_object_id, userset_relation);`,
+		subjectIndexName,
+		QuoteIdentifier(driver.RelationTuple()),
+	)
+}
+
+func dropSubjectIndex(driver *MySQLDriver) string {
+	return fmt.Sprintf(`DROP INDEX %s ON %s;`,
+		subjectIndexName,
+		QuoteIdentifier(driver.RelationTuple()),
+	)
+}
+
+// addSubjectIndex creates the subject index, unless it already exists. MySQL does not
+// support CREATE INDEX IF NOT EXISTS, so the guard is done by reading the index metadata.
+func addSubjectIndex(driver *MySQLDriver) error {
+	exists, err := driver.indexExists(context.Background(), driver.RelationTuple(), subjectIndexName)
+	if err != nil {
+		return fmt.Errorf("unable to check for existing subject index: %w", err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	return newExecutor(createSubjectIndex).migrate(driver)
+}
+
+// removeSubjectIndex drops the subject index, if it exists.
+func removeSubjectIndex(driver *MySQLDriver) error {
+	exists, err := driver.indexExists(context.Background(), driver.RelationTuple(), subjectIndexName)
+	if err != nil {
+		return fmt.Errorf("unable to check for existing subject index: %w", err)
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return newExecutor().withDown(dropSubjectIndex).rollback(driver)
+}
+
+func init() {
+	mustRegisterMigration("add_subject_index", "add_unique_datastore_id", addSubjectIndex, removeSubjectIndex)
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"fmt"
+)
+
+// initialReachabilityCacheVersion is the reachability cache version stored for existing
+// datastores. It must never change, as later versions are written by the datastore itself.
+const initialReachabilityCacheVersion = 1
+
+func addReachabilityCacheVersionColumn(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s
+		ADD COLUMN reachability_cache_version INT UNSIGNED NOT NULL DEFAULT %d;`,
+		QuoteIdentifier(driver.Metadata()),
+		initialReachabilityCacheVersion,
+	)
+}
+
+func dropReachabilityCacheVersionColumn(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s DROP COLUMN reachability_cache_version;`, QuoteIdentifier(driver.Metadata()))
+}
+
+func init() {
+	cacheVersionExecutor := newExecutor(
+		addReachabilityCacheVersionColumn,
+	).withDown(
+		dropReachabilityCacheVersionColumn,
+	)
+
+	mustRegisterMigration("add_reachability_cache_version", "add_subject_index",
+		cacheVersionExecutor.migrate,
+		cacheVersionExecutor.rollback,
+	)
+}
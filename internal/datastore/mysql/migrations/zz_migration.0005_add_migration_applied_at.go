@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"fmt"
+)
+
+// appliedAtMigrationName is the name of the migration adding the column which records when each
+// migration was applied.
+const appliedAtMigrationName = "add_migration_applied_at"
+
+func addMigrationAppliedAtColumn(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s
+		ADD COLUMN %s DATETIME(6) NOT NULL;`,
+		QuoteIdentifier(driver.migrationVersion()),
+		QuoteIdentifier(colAppliedAt),
+	)
+}
+
+func dropMigrationAppliedAtColumn(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s;`,
+		QuoteIdentifier(driver.migrationVersion()),
+		QuoteIdentifier(colAppliedAt),
+	)
+}
+
+// deleteAppliedMigrations removes the records of the applied migrations, which have no meaning
+// once the column is dropped, so that the column can be added back to an empty table.
+func deleteAppliedMigrations(driver *MySQLDriver) string {
+	return fmt.Sprintf(`DELETE FROM %s;`, QuoteIdentifier(driver.migrationVersion()))
+}
+
+func init() {
+	appliedAtExecutor := newExecutor(
+		addMigrationAppliedAtColumn,
+	).withDown(
+		dropMigrationAppliedAtColumn,
+		deleteAppliedMigrations,
+	)
+
+	mustRegisterMigration(appliedAtMigrationName, "add_reachability_cache_version",
+		appliedAtExecutor.migrate,
+		appliedAtExecutor.rollback,
+	)
+}
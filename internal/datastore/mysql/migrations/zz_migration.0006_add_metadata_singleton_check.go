@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+)
+
+const (
+	// colMetadataSingleton is a generated column of the metadata table, which holds the same value
+	// for every row.
+	colMetadataSingleton = "singleton"
+
+	// metadataSingletonKey is the name of the unique key on colMetadataSingleton, which ensures that
+	// the metadata table holds at most a single row. A unique key is used rather than a CHECK
+	// constraint, which MySQL only enforces from 8.0.16 and can only drop from 8.0.19.
+	metadataSingletonKey = "uq_metadata_singleton"
+)
+
+func addMetadataSingletonKey(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s
+		ADD COLUMN %s TINYINT UNSIGNED AS (0) STORED NOT NULL,
+		ADD UNIQUE KEY %s (%s);`,
+		QuoteIdentifier(driver.Metadata()),
+		QuoteIdentifier(colMetadataSingleton),
+		QuoteIdentifier(driver.prefix+metadataSingletonKey),
+		QuoteIdentifier(colMetadataSingleton),
+	)
+}
+
+func dropMetadataSingletonKey(driver *MySQLDriver) string {
+	return fmt.Sprintf(`ALTER TABLE %s DROP INDEX %s, DROP COLUMN %s;`,
+		QuoteIdentifier(driver.Metadata()),
+		QuoteIdentifier(driver.prefix+metadataSingletonKey),
+		QuoteIdentifier(colMetadataSingleton),
+	)
+}
+
+func init() {
+	singletonExecutor := newExecutor(
+		addMetadataSingletonKey,
+	).withDown(
+		dropMetadataSingletonKey,
+	)
+
+	mustRegisterMigration("add_metadata_singleton_check", "add_migration_applied_at",
+		singletonExecutor.migrate,
+		singletonExecutor.rollback,
+	)
+}
@@ -1,8 +1,11 @@
 package mysql
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
+
+	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
 )
 
 const (
@@ -20,6 +23,7 @@ const (
 	defaultMaxRevisionStalenessPercent       = 0.1
 	defaultEnablePrometheusStats             = false
 	defaultMaxRetries                        = 8
+	defaultStatisticsCacheTTL                = 5 * time.Second
 )
 
 type mysqlOptions struct {
@@ -38,6 +42,15 @@ type mysqlOptions struct {
 	analyzeBeforeStats          bool
 	maxRetries                  uint8
 	lockWaitTimeoutSeconds      *uint8
+	statisticsCacheTTL          time.Duration
+	perNamespaceStats           bool
+	analyzeTimeout              time.Duration
+	periodicAnalyzeInterval     time.Duration
+	readReplicaDB               *sql.DB
+	relationTupleShards         []string
+	exactCountThreshold         uint64
+	liveOnlyEstimate            bool
+	slowQueryThreshold          time.Duration
 }
 
 // Option provides the facility to configure how clients within the
@@ -58,6 +71,8 @@ func generateConfig(options []Option) (mysqlOptions, error) {
 		maxRevisionStalenessPercent: defaultMaxRevisionStalenessPercent,
 		enablePrometheusStats:       defaultEnablePrometheusStats,
 		maxRetries:                  defaultMaxRetries,
+		statisticsCacheTTL:          defaultStatisticsCacheTTL,
+		slowQueryThreshold:          migrations.DefaultSlowQueryThreshold,
 	}
 
 	for _, option := range options {
@@ -65,6 +80,10 @@ func generateConfig(options []Option) (mysqlOptions, error) {
 	}
 
 	// Run any checks on the config that need to be done
+	if err := migrations.ValidateTablePrefix(computed.tablePrefix); err != nil {
+		return computed, err
+	}
+
 	if computed.revisionQuantization >= computed.gcWindow {
 		return computed, fmt.Errorf(
 			errQuantizationTooLarge,
@@ -135,7 +154,8 @@ func MaxRetries(maxRetries uint8) Option {
 	}
 }
 
-// TablePrefix allows defining a MySQL table name prefix.
+// TablePrefix allows defining a MySQL table name prefix. The prefix may only hold ASCII letters,
+// digits and underscores; see migrations.ValidateTablePrefix.
 //
 // No prefix is set by default
 func TablePrefix(prefix string) Option {
@@ -197,6 +217,31 @@ func DebugAnalyzeBeforeStatistics() Option {
 	}
 }
 
+// WithAnalyzeTimeout is the maximum amount of time for which the Analyze Table run before
+// returning statistics may block. If the timeout is reached, the statistics are returned using
+// the estimated relationship count last computed by MySQL. Only applies when
+// DebugAnalyzeBeforeStatistics is set.
+//
+// This value defaults to zero, which blocks until Analyze Table completes.
+func WithAnalyzeTimeout(timeout time.Duration) Option {
+	return func(po *mysqlOptions) {
+		po.analyzeTimeout = timeout
+	}
+}
+
+// WithPeriodicAnalyze runs ANALYZE TABLE on the relationships tables in the background at the given
+// interval, keeping the estimated relationship count returned by Statistics fresh without the cost
+// of analyzing on each call. When enabled, Statistics never runs ANALYZE TABLE itself, even if
+// DebugAnalyzeBeforeStatistics is set. Each run is bounded by WithAnalyzeTimeout, if set. The
+// background worker is stopped when the datastore is closed.
+//
+// This value defaults to zero, which disables the periodic analyze.
+func WithPeriodicAnalyze(interval time.Duration) Option {
+	return func(po *mysqlOptions) {
+		po.periodicAnalyzeInterval = interval
+	}
+}
+
 // OverrideLockWaitTimeout sets the lock wait timeout on each new connection established
 // with the databases. As an OLTP service, the default of 50s is unbearably long to block
 // a write for our service, so we suggest setting this value to the minimum of 1 second.
@@ -207,3 +252,85 @@ func OverrideLockWaitTimeout(seconds uint8) Option {
 		po.lockWaitTimeoutSeconds = &seconds
 	}
 }
+
+// StatisticsCacheTTL is the amount of time for which the estimated relationship count and
+// object type statistics returned by Statistics will be cached before being recomputed.
+// A value of zero disables caching.
+//
+// This value defaults to 5 seconds.
+func StatisticsCacheTTL(ttl time.Duration) Option {
+	return func(mo *mysqlOptions) {
+		mo.statisticsCacheTTL = ttl
+	}
+}
+
+// WithReadReplica sets a connection pool to a read replica of the database, on which the
+// read-only queries of Statistics are run to offload the primary. If the queries fail on the
+// replica, they are run on the primary instead. ANALYZE TABLE, if enabled, is always run
+// on the primary. The datastore does not close the replica's connection pool.
+//
+// By default, all queries are run on the primary.
+func WithReadReplica(db *sql.DB) Option {
+	return func(mo *mysqlOptions) {
+		mo.readReplicaDB = db
+	}
+}
+
+// WithRelationTupleShards sets the names of additional tables into which the relationship tuple
+// table is sharded. Statistics count and analyze the relationships across the relationship
+// tuple table and all its shards. The names are prefixed with the TablePrefix, if any.
+//
+// No shards are set by default.
+func WithRelationTupleShards(shards ...string) Option {
+	return func(mo *mysqlOptions) {
+		mo.relationTupleShards = shards
+	}
+}
+
+// WithExactCountThreshold sets the estimated number of relationships below which Statistics
+// counts the relationships exactly instead of returning the estimate. The estimate is fast but
+// can be inaccurate for small tables, for which an exact count is both accurate and cheap, e.g.
+// below 10,000 relationships. A threshold of zero always returns the estimate.
+//
+// Defaults to zero.
+func WithExactCountThreshold(threshold uint64) Option {
+	return func(mo *mysqlOptions) {
+		mo.exactCountThreshold = threshold
+	}
+}
+
+// WithLiveOnlyEstimate marks whether Statistics should exclude deleted relationships from the
+// estimated relationship count. Deleted relationships remain as rows in the relationships table
+// until garbage collected, and are therefore included in the estimate. If enabled, the deleted
+// rows are counted using the index on the deleted transaction, which only scans the rows awaiting
+// garbage collection, and are subtracted from the estimate.
+//
+// Disabled by default.
+func WithLiveOnlyEstimate(enabled bool) Option {
+	return func(mo *mysqlOptions) {
+		mo.liveOnlyEstimate = enabled
+	}
+}
+
+// WithPerNamespaceStatistics marks whether Statistics should compute the number of live
+// relationships for each namespace. Computing the breakdown requires counting the rows of
+// the relationships table, which can be expensive on large datastores.
+//
+// Disabled by default.
+func WithPerNamespaceStatistics(enabled bool) Option {
+	return func(mo *mysqlOptions) {
+		mo.perNamespaceStats = enabled
+	}
+}
+
+// WithSlowQueryThreshold sets the duration above which a query run by Statistics, or a statement
+// of a migration run through the datastore's migration driver, is logged at warn level along with
+// its duration, its SQL and the unique ID of the datastore. A threshold of zero disables slow query
+// logging.
+//
+// This value defaults to 1 second.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(mo *mysqlOptions) {
+		mo.slowQueryThreshold = threshold
+	}
+}
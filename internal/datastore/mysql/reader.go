@@ -172,7 +172,7 @@ func (mr *mysqlReader) ListNamespaces(ctx context.Context) ([]*core.NamespaceDef
 	return nsDefs, err
 }
 
-func loadAllNamespaces(ctx context.Context, tx *sql.Tx, queryBuilder sq.SelectBuilder) ([]*core.NamespaceDefinition, error) {
+func loadAllNamespaces(ctx context.Context, tx querier, queryBuilder sq.SelectBuilder) ([]*core.NamespaceDefinition, error) {
 	// TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 	query, args, err := queryBuilder.ToSql()
 	if err != nil {
@@ -2,82 +2,1163 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
 	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 
 	"github.com/Masterminds/squirrel"
 )
 
 const (
-	informationSchemaTableRowsColumn = "table_rows"
-	informationSchemaTablesTable     = "INFORMATION_SCHEMA.TABLES"
-	informationSchemaTableNameColumn = "table_name"
+	informationSchemaTableRowsColumn   = "table_rows"
+	informationSchemaTablesTable       = "INFORMATION_SCHEMA.TABLES"
+	informationSchemaTableNameColumn   = "table_name"
+	informationSchemaCurrentSchemaExpr = "table_schema = DATABASE()"
+	informationSchemaUpdateTimeColumn  = "update_time"
+	sumTableRowsColumn                 = "SUM(" + informationSchemaTableRowsColumn + ")"
+	minUpdateTimeColumn                = "UNIX_TIMESTAMP(MIN(" + informationSchemaUpdateTimeColumn + "))"
+
+	estimatesAlias     = "estimates"
+	estimatedRowsAlias = "estimated_rows"
+	tableCountAlias    = "table_count"
+	updatedAtAlias     = "updated_at"
+
+	analyzeTableQuery     = "ANALYZE TABLE %s"
+	analyzeMaxAttempts    = 3
+	analyzeRetryBackoff   = 50 * time.Millisecond
+	transientRetryBackoff = 50 * time.Millisecond
+	maxRetryBackoff       = 2 * time.Second
+	countAllColumn        = "COUNT(*)"
+	maxCreatedTxnColumn   = "MAX(" + colCreatedTxn + ")"
 
-	analyzeTableQuery = "ANALYZE TABLE %s"
+	// statisticsComputeTimeout bounds a computation of the statistics shared by concurrent
+	// callers, which is not bound to the context of any one of them.
+	statisticsComputeTimeout = 30 * time.Second
 
 	metadataIDColumn       = "id"
 	metadataUniqueIDColumn = "unique_id"
+
+	errMetadataUninitialized = "datastore metadata uninitialized; ensure migrations have been run and the datastore has been seeded"
+	errMetadataAmbiguous     = "datastore metadata is ambiguous; the metadata table must hold a single row"
+	errShuttingDown          = "datastore is shutting down; statistics are no longer available"
+	errStatementsClosed      = "prepared statements have been closed"
+)
+
+// statisticsTxOptions are the options of the transaction in which the statistics are read. A
+// repeatable read transaction reads every query from the snapshot established by the first.
+var statisticsTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+var (
+	loadNamespacesHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "mysql_statistics_load_namespaces_duration",
+		Help:      "distribution in seconds of time spent loading the namespaces to compute MySQL statistics.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+	}, []string{"unique_id"})
+	loadedNamespacesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "mysql_statistics_loaded_namespaces",
+		Help:      "number of namespaces loaded by the last load of namespaces to compute MySQL statistics.",
+	}, []string{"unique_id"})
 )
 
+// registerStatisticsMetrics registers the metrics collected when computing statistics.
+func registerStatisticsMetrics() error {
+	for _, collector := range []prometheus.Collector{loadNamespacesHistogram, loadedNamespacesGauge} {
+		if err := prometheus.Register(collector); err != nil {
+			return fmt.Errorf("unable to register metric: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Statistics returns the statistics for the datastore, which are cached for the configured
+// statistics TTL.
 func (mds *Datastore) Statistics(ctx context.Context) (datastore.Stats, error) {
-	if mds.analyzeBeforeStats {
-		_, err := mds.db.ExecContext(ctx, fmt.Sprintf(analyzeTableQuery, mds.driver.RelationTuple()))
+	end, err := mds.beginStatisticsCall()
+	if err != nil {
+		return datastore.Stats{}, err
+	}
+	defer end()
+
+	computed, err := mds.statsCache.get(ctx)
+	if err != nil {
+		return datastore.Stats{}, err
+	}
+
+	return computed.Stats, nil
+}
+
+// StatsOptions are the options of a single call to StatisticsWithOptions.
+type StatsOptions struct {
+	// ForceAnalyze runs ANALYZE TABLE on the relationships tables before computing the statistics,
+	// even if the datastore was not configured to, so that the estimated relationship count is
+	// fresh. The statistics are always recomputed, bypassing the statistics cache.
+	ForceAnalyze bool
+}
+
+// StatisticsWithOptions returns the statistics for the datastore, as returned by Statistics, with
+// the given options applied to this call only. With the zero options, this is Statistics. Statistics
+// recomputed because of the options replace the cached statistics.
+func (mds *Datastore) StatisticsWithOptions(ctx context.Context, options StatsOptions) (datastore.Stats, error) {
+	if !options.ForceAnalyze {
+		return mds.Statistics(ctx)
+	}
+
+	end, err := mds.beginStatisticsCall()
+	if err != nil {
+		return datastore.Stats{}, err
+	}
+	defer end()
+
+	computed, err := mds.computeStatisticsWithAnalyze(ctx, true)
+	if err != nil {
+		return datastore.Stats{}, err
+	}
+
+	mds.statsCache.store(computed)
+	return computed.Stats, nil
+}
+
+// StatisticsAtRevision returns the statistics for the datastore, as returned by Statistics, along
+// with the revision of the datastore at which they were computed. The revision is read in the same
+// snapshot as the namespaces from which the ObjectTypeStatistics are computed, and can therefore be
+// used to tag data derived from the statistics. The revision is NoRevision if no transaction has
+// been written.
+func (mds *Datastore) StatisticsAtRevision(ctx context.Context) (datastore.Stats, datastore.Revision, error) {
+	end, err := mds.beginStatisticsCall()
+	if err != nil {
+		return datastore.Stats{}, datastore.NoRevision, err
+	}
+	defer end()
+
+	computed, err := mds.statsCache.get(ctx)
+	if err != nil {
+		return datastore.Stats{}, datastore.NoRevision, err
+	}
+
+	return computed.Stats, computed.revision, nil
+}
+
+// Shutdown stops accepting statistics calls, waits for the statistics calls in flight to complete,
+// and then closes the datastore. Statistics calls made once shutting down return an error. If the
+// context is done before the calls in flight complete, the datastore is closed regardless, and the
+// error of the context is returned.
+func (mds *Datastore) Shutdown(ctx context.Context) error {
+	select {
+	case <-mds.statsCalls.close():
+		return mds.Close()
+
+	case <-ctx.Done():
+		if err := mds.Close(); err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("error closing datastore after timing out waiting for statistics calls")
+		}
+		return fmt.Errorf("timed out waiting for statistics calls to complete: %w", ctx.Err())
+	}
+}
+
+// beginStatisticsCall registers a statistics call in flight, returning the function to call once
+// it completes, or an error if the datastore is shutting down.
+func (mds *Datastore) beginStatisticsCall() (func(), error) {
+	if !mds.statsCalls.begin() {
+		return nil, errors.New(errShuttingDown)
+	}
+
+	return mds.statsCalls.inFlight.Done, nil
+}
+
+// statisticsCalls tracks the statistics calls in flight, such that shutting down the datastore can
+// wait for them before closing the connection pool. The zero value accepts calls.
+type statisticsCalls struct {
+	mu       sync.Mutex
+	closed   bool
+	inFlight sync.WaitGroup
+}
+
+// begin registers a call in flight, or returns false if no longer accepting calls.
+func (sc *statisticsCalls) begin() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return false
+	}
+
+	sc.inFlight.Add(1)
+	return true
+}
+
+// close stops accepting calls, and returns a channel which is closed once the calls in flight have
+// completed.
+func (sc *statisticsCalls) close() <-chan struct{} {
+	sc.mu.Lock()
+	sc.closed = true
+	sc.mu.Unlock()
+
+	completed := make(chan struct{})
+	go func() {
+		sc.inFlight.Wait()
+		close(completed)
+	}()
+	return completed
+}
+
+// PoolStats returns the statistics of the connection pool backing the datastore, such as the
+// number of open, in use and idle connections, and the number and duration of waits for a
+// connection.
+func (mds *Datastore) PoolStats() sql.DBStats {
+	return mds.db.Stats()
+}
+
+// ExactRelationshipCount returns the exact number of live relationships in the datastore. Unlike
+// the estimated count returned by Statistics, this requires scanning the relationships table,
+// and can therefore be slow for large datastores.
+func (mds *Datastore) ExactRelationshipCount(ctx context.Context) (uint64, error) {
+	end, err := mds.beginStatisticsCall()
+	if err != nil {
+		return 0, err
+	}
+	defer end()
+
+	return mds.exactRelationshipCount(ctx, mds.db)
+}
+
+// rowQuerier runs queries returning a single row, either directly on a connection pool or within a
+// transaction.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowsQuerier additionally runs queries returning several rows.
+type rowsQuerier interface {
+	rowQuerier
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// preparedStatements caches the statements prepared for the statistics queries by connection pool
+// and query, as the SQL of each of those queries is constant for a datastore. A statement prepared
+// on a pool is prepared again by database/sql on any connection of the pool on which it has not yet
+// been, such as a connection opened to replace a closed one, so it remains usable for the lifetime
+// of the pool. The zero value is an empty cache.
+type preparedStatements struct {
+	mu     sync.Mutex
+	closed bool
+	stmts  map[preparedStatementKey]*sql.Stmt
+}
+
+type preparedStatementKey struct {
+	db    *sql.DB
+	query string
+}
+
+// get returns the statement for the query prepared on the given pool, preparing and caching it if
+// it has not yet been. The statement is prepared without holding the lock, so that a slow prepare
+// does not block the queries whose statements are cached. If another call cached a statement for
+// the same query in the meantime, or the cache was closed, the statement prepared by this call is
+// closed.
+func (ps *preparedStatements) get(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	key := preparedStatementKey{db: db, query: query}
+	if stmt, err := ps.cached(key); stmt != nil || err != nil {
+		return stmt, err
+	}
+
+	prepared, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := ps.cacheOrGet(key, prepared)
+	if stmt != prepared {
+		migrations.LogOnError(ctx, prepared.Close)
+	}
+	return stmt, err
+}
+
+// cached returns the cached statement for the key, which is nil if there is none.
+func (ps *preparedStatements) cached(key preparedStatementKey) (*sql.Stmt, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return nil, errors.New(errStatementsClosed)
+	}
+
+	return ps.stmts[key], nil
+}
+
+// cacheOrGet caches the prepared statement for the key, unless a statement has already been cached
+// for it, in which case that statement is returned instead.
+func (ps *preparedStatements) cacheOrGet(key preparedStatementKey, prepared *sql.Stmt) (*sql.Stmt, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return nil, errors.New(errStatementsClosed)
+	}
+
+	if stmt, ok := ps.stmts[key]; ok {
+		return stmt, nil
+	}
+
+	if ps.stmts == nil {
+		ps.stmts = make(map[preparedStatementKey]*sql.Stmt)
+	}
+	ps.stmts[key] = prepared
+	return prepared, nil
+}
+
+// close closes the cached statements, after which no statement can be prepared.
+func (ps *preparedStatements) close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.closed = true
+
+	var err error
+	for key, stmt := range ps.stmts {
+		if closeErr := stmt.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(ps.stmts, key)
+	}
+
+	return err
+}
+
+// statisticsTx is a statistics transaction begun on a connection pool, which runs its single row
+// queries using the statements prepared on that pool, and logs its slow queries.
+type statisticsTx struct {
+	tx  *sql.Tx
+	db  *sql.DB
+	mds *Datastore
+}
+
+// QueryRowContext runs the query within the transaction as a prepared statement. If the statement
+// cannot be prepared, the query is run unprepared, so that the cache cannot fail the statistics.
+func (stx statisticsTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	defer stx.mds.logSlowQuery(ctx, start, query)
+
+	stmt, err := stx.mds.statsStatements.get(ctx, stx.db, query)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("unable to prepare statistics query, running it unprepared")
+		return stx.tx.QueryRowContext(ctx, query, args...)
+	}
+
+	return stx.tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+}
+
+// QueryContext runs the query within the transaction.
+func (stx statisticsTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	defer stx.mds.logSlowQuery(ctx, start, query)
+
+	return stx.tx.QueryContext(ctx, query, args...)
+}
+
+// logSlowQuery logs the query started at the given time if it ran for longer than the configured
+// slow query threshold.
+func (mds *Datastore) logSlowQuery(ctx context.Context, start time.Time, query string) {
+	uniqueID, _ := mds.uniqueID.Load().(string)
+	migrations.LogSlowQuery(ctx, mds.slowQueryThreshold, time.Since(start), query, uniqueID)
+}
+
+func (mds *Datastore) exactRelationshipCount(ctx context.Context, db rowQuerier) (uint64, error) {
+	return mds.countRelationTupleRows(ctx, db, squirrel.Eq{colDeletedTxn: liveDeletedTxnID})
+}
+
+// deletedRelationshipCount returns the number of deleted relationships which have not yet been
+// garbage collected. The rows are found using the index on the deleted transaction, so only the
+// deleted rows are scanned.
+func (mds *Datastore) deletedRelationshipCount(ctx context.Context, db rowQuerier) (uint64, error) {
+	return mds.countRelationTupleRows(ctx, db, squirrel.Lt{colDeletedTxn: liveDeletedTxnID})
+}
+
+// countRelationTupleRows sums the number of rows matching the given predicate across all the
+// relationship tuple tables.
+func (mds *Datastore) countRelationTupleRows(ctx context.Context, db rowQuerier, pred squirrel.Sqlizer) (uint64, error) {
+	var total uint64
+	for _, table := range mds.driver.RelationTupleTables() {
+		query, args, err := sb.
+			Select(countAllColumn).
+			From(table).
+			Where(pred).
+			ToSql()
 		if err != nil {
-			return datastore.Stats{}, fmt.Errorf("unable to run ANALYZE TABLE: %w", err)
+			return 0, fmt.Errorf("unable to generate query sql: %w", err)
+		}
+
+		var count uint64
+		if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+			return 0, fmt.Errorf("unable to count relationships in table `%s`: %w", table, err)
 		}
+		total += count
 	}
 
-	uniqueID, err := mds.getUniqueID(ctx)
+	return total, nil
+}
+
+// CountsOnly returns the unique ID of the datastore and the estimated number of relationships, as
+// returned by Statistics, without loading the namespaces from which the ObjectTypeStatistics are
+// computed. This is much cheaper than Statistics for datastores with many namespaces. Unlike
+// Statistics, the counts are not cached.
+func (mds *Datastore) CountsOnly(ctx context.Context) (uniqueID string, estimated uint64, err error) {
+	end, err := mds.beginStatisticsCall()
 	if err != nil {
-		return datastore.Stats{}, err
+		return "", 0, err
+	}
+	defer end()
+
+	uniqueID, estimate, err := mds.relationshipCounts(ctx, mds.analyzeBeforeStats)
+	return uniqueID, estimate.count, err
+}
+
+func (mds *Datastore) computeStatistics(ctx context.Context) (revisionedStats, error) {
+	return mds.computeStatisticsWithAnalyze(ctx, mds.analyzeBeforeStats)
+}
+
+// computeStatisticsWithAnalyze computes the statistics, first running ANALYZE TABLE if analyze is
+// true. The counts and the namespaces are read within a single read-only transaction, such that
+// they are mutually consistent.
+func (mds *Datastore) computeStatisticsWithAnalyze(ctx context.Context, analyze bool) (revisionedStats, error) {
+	if err := mds.analyzeForStatistics(ctx, analyze); err != nil {
+		return revisionedStats{}, err
+	}
+
+	var uniqueID string
+	var estimate relationshipEstimate
+	var snapshot namespaceSnapshot
+	if err := retryTransientErrors(ctx, mds.maxRetries, transientRetryBackoff, func() error {
+		return mds.onStatisticsDB(ctx, func(db *sql.DB) error {
+			return BeginTxFunc(ctx, db, statisticsTxOptions, func(tx *sql.Tx) (err error) {
+				stx := mds.statisticsTx(db, tx)
+
+				// The namespaces are read first, so that the snapshot is established by reading
+				// the head revision.
+				snapshot, err = mds.namespaceStatistics(ctx, stx)
+				if err != nil {
+					return err
+				}
+
+				uniqueID, estimate, err = mds.snapshotRelationshipCounts(ctx, stx)
+				return err
+			})
+		})
+	}); err != nil {
+		return revisionedStats{}, err
+	}
+
+	if snapshot.namespacesLoad != nil {
+		mds.observeNamespacesLoad(uniqueID, *snapshot.namespacesLoad)
+	}
+
+	return revisionedStats{
+		Stats: datastore.Stats{
+			UniqueID:                              uniqueID,
+			ObjectTypeStatistics:                  datastore.ComputeObjectTypeStats(snapshot.nsDefs),
+			EstimatedRelationshipCount:            estimate.count,
+			EstimatedRelationshipCountByNamespace: snapshot.countByNamespace,
+			EstimatedRelationshipCountUpdatedAt:   estimate.updatedAt,
+		},
+		revision: snapshot.revision,
+	}, nil
+}
+
+// relationshipCounts returns the unique ID of the datastore and the estimated number of
+// relationships, adjusted as configured, first running ANALYZE TABLE if analyze is true.
+func (mds *Datastore) relationshipCounts(ctx context.Context, analyze bool) (string, relationshipEstimate, error) {
+	if err := mds.analyzeForStatistics(ctx, analyze); err != nil {
+		return "", relationshipEstimate{}, err
+	}
+
+	var uniqueID string
+	var estimate relationshipEstimate
+	if err := retryTransientErrors(ctx, mds.maxRetries, transientRetryBackoff, func() error {
+		return mds.onStatisticsDB(ctx, func(db *sql.DB) error {
+			return BeginTxFunc(ctx, db, statisticsTxOptions, func(tx *sql.Tx) (err error) {
+				uniqueID, estimate, err = mds.snapshotRelationshipCounts(ctx, mds.statisticsTx(db, tx))
+				return err
+			})
+		})
+	}); err != nil {
+		return "", relationshipEstimate{}, err
+	}
+
+	return uniqueID, estimate, nil
+}
+
+// statisticsTx returns the statistics transaction for the given transaction begun on the given pool.
+func (mds *Datastore) statisticsTx(db *sql.DB, tx *sql.Tx) statisticsTx {
+	return statisticsTx{tx: tx, db: db, mds: mds}
+}
+
+// analyzeForStatistics runs ANALYZE TABLE on the relationship tuple tables if analyze is true.
+func (mds *Datastore) analyzeForStatistics(ctx context.Context, analyze bool) error {
+	if !analyze {
+		return nil
+	}
+
+	if err := analyzeWithTimeout(ctx, mds.analyzeTimeout, func(ctx context.Context) error {
+		return analyzeWithRetries(ctx, analyzeMaxAttempts, analyzeRetryBackoff, mds.analyzeRelationTupleTables)
+	}); err != nil {
+		return fmt.Errorf("unable to run ANALYZE TABLE: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotRelationshipCounts returns the unique ID of the datastore and the estimated number of
+// relationships, adjusted as configured, as seen by the given transaction. Note that the estimate
+// itself is read from INFORMATION_SCHEMA, which is not versioned, whereas the adjustments are read
+// from the snapshot of the transaction.
+func (mds *Datastore) snapshotRelationshipCounts(ctx context.Context, tx statisticsTx) (string, relationshipEstimate, error) {
+	uniqueID, estimate, err := mds.uniqueIDAndEstimatedRelationshipCount(ctx, tx)
+	if err != nil {
+		return "", relationshipEstimate{}, err
+	}
+
+	if mds.liveOnlyEstimate {
+		deleted, err := mds.deletedRelationshipCount(ctx, tx)
+		if err != nil {
+			return "", relationshipEstimate{}, err
+		}
+
+		// The estimate is approximate, and may therefore be lower than the number of deleted rows.
+		if deleted < estimate.count {
+			estimate.count -= deleted
+		} else {
+			estimate.count = 0
+		}
+	}
+
+	// Small tables are counted exactly, as the estimate is least accurate for them.
+	if estimate.count < mds.exactCountThreshold {
+		estimate.count, err = mds.exactRelationshipCount(ctx, tx)
+		if err != nil {
+			return "", relationshipEstimate{}, err
+		}
+	}
+
+	return uniqueID, estimate, nil
+}
+
+// onStatisticsDB runs the given read-only statistics queries on the read replica, if configured,
+// and on the primary otherwise. If the queries fail on the replica, they are run again on the
+// primary, unless the context is done.
+func (mds *Datastore) onStatisticsDB(ctx context.Context, fn func(db *sql.DB) error) error {
+	if mds.readReplicaDB == nil {
+		return fn(mds.db)
+	}
+
+	err := fn(mds.readReplicaDB)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Warn().Err(err).Msg("unable to compute statistics on the read replica, computing them on the primary")
+	return fn(mds.db)
+}
+
+// relationshipEstimate is the estimated number of relationships, along with the time at which the
+// relationship tuple tables were last updated, if known.
+type relationshipEstimate struct {
+	count     uint64
+	updatedAt *time.Time
+}
+
+// uniqueIDAndEstimatedRelationshipCount returns the unique ID of the datastore and the estimated
+// number of relationships. Until the unique ID has been cached, both are loaded in a single round
+// trip by joining the metadata row with the estimate, which requires no multi-statement support
+// from the connection. As with Metadata, up to two rows are loaded, so that ambiguous metadata is
+// reported rather than one of its rows being picked.
+func (mds *Datastore) uniqueIDAndEstimatedRelationshipCount(ctx context.Context, db rowsQuerier) (string, relationshipEstimate, error) {
+	if uniqueID, ok := mds.uniqueID.Load().(string); ok {
+		estimate, err := mds.estimatedRelationshipCount(ctx, db)
+		return uniqueID, estimate, err
+	}
+
+	estimateQuery, tableTotal := mds.estimatedRelationshipCountQuery()
+	estimateSQL, estimateArgs, err := estimateQuery.ToSql()
+	if err != nil {
+		return "", relationshipEstimate{}, err
 	}
 
 	query, args, err := sb.
-		Select(informationSchemaTableRowsColumn).
-		From(informationSchemaTablesTable).
-		Where(squirrel.Eq{informationSchemaTableNameColumn: mds.driver.RelationTuple()}).
+		Select(metadataUniqueIDColumn, estimatedRowsAlias, tableCountAlias, updatedAtAlias).
+		From(mds.driver.Metadata()).
+		JoinClause("CROSS JOIN ("+estimateSQL+") AS "+estimatesAlias, estimateArgs...).
+		Limit(2).
 		ToSql()
 	if err != nil {
-		return datastore.Stats{}, err
+		return "", relationshipEstimate{}, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", relationshipEstimate{}, fmt.Errorf("unable to query unique ID and estimated relationship count: %w", err)
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	var uniqueID string
+	var count, updatedAt sql.NullInt64
+	var tableCount uint64
+	rowCount := 0
+	for rows.Next() {
+		if err := rows.Scan(&uniqueID, &count, &tableCount, &updatedAt); err != nil {
+			return "", relationshipEstimate{}, fmt.Errorf("unable to query unique ID and estimated relationship count: %w", err)
+		}
+		rowCount++
 	}
-	var count uint64
-	err = mds.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	if err := rows.Err(); err != nil {
+		return "", relationshipEstimate{}, fmt.Errorf("unable to query unique ID and estimated relationship count: %w", err)
+	}
+
+	if err := checkMetadataRowCount(rowCount); err != nil {
+		return "", relationshipEstimate{}, err
+	}
+
+	if err := checkEstimatedTableCount(tableCount, tableTotal); err != nil {
+		return "", relationshipEstimate{}, err
+	}
+
+	mds.uniqueID.Store(uniqueID)
+	return uniqueID, newRelationshipEstimate(count, updatedAt), nil
+}
+
+// estimatedRelationshipCount sums the estimated number of rows across all the relationship tuple
+// tables, as last computed by ANALYZE TABLE.
+func (mds *Datastore) estimatedRelationshipCount(ctx context.Context, db rowQuerier) (relationshipEstimate, error) {
+	estimateQuery, tableTotal := mds.estimatedRelationshipCountQuery()
+	query, args, err := estimateQuery.ToSql()
 	if err != nil {
-		return datastore.Stats{}, err
+		return relationshipEstimate{}, err
 	}
 
-	nsQuery := mds.ReadNamespaceQuery.Where(squirrel.Eq{colDeletedTxn: liveDeletedTxnID})
+	var count, updatedAt sql.NullInt64
+	var tableCount uint64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count, &tableCount, &updatedAt); err != nil {
+		return relationshipEstimate{}, err
+	}
+
+	if err := checkEstimatedTableCount(tableCount, tableTotal); err != nil {
+		return relationshipEstimate{}, err
+	}
+
+	return newRelationshipEstimate(count, updatedAt), nil
+}
+
+// estimatedRelationshipCountQuery returns the query over all the relationship tuple tables, along
+// with the number of tables which must be found. The query selects:
+//   - the estimated number of rows across the tables, which is NULL if none of them reports one;
+//   - the number of tables found;
+//   - the time at which the least recently updated table was last updated, as a Unix timestamp,
+//     so that it can be read regardless of whether the connection parses times.
+func (mds *Datastore) estimatedRelationshipCountQuery() (squirrel.SelectBuilder, int) {
+	tables := mds.driver.RelationTupleTables()
+	return sb.
+		Select(
+			sumTableRowsColumn+" AS "+estimatedRowsAlias,
+			countAllColumn+" AS "+tableCountAlias,
+			minUpdateTimeColumn+" AS "+updatedAtAlias,
+		).
+		From(informationSchemaTablesTable).
+		Where(informationSchemaCurrentSchemaExpr).
+		Where(squirrel.Eq{informationSchemaTableNameColumn: tables}), len(tables)
+}
 
-	tx, err := mds.db.BeginTx(ctx, nil)
+// newRelationshipEstimate returns the estimate for the given count and update time. The count is
+// NULL if none of the tables reported a number of rows, as freshly created tables may not, in
+// which case the estimate is zero. The update time is NULL if none of the tables reported one.
+func newRelationshipEstimate(count sql.NullInt64, updatedAt sql.NullInt64) relationshipEstimate {
+	var estimate relationshipEstimate
+	if count.Valid && count.Int64 > 0 {
+		estimate.count = uint64(count.Int64)
+	}
+	if updatedAt.Valid {
+		updated := time.Unix(updatedAt.Int64, 0)
+		estimate.updatedAt = &updated
+	}
+
+	return estimate
+}
+
+func checkEstimatedTableCount(tableCount uint64, tableTotal int) error {
+	if tableCount != uint64(tableTotal) {
+		return fmt.Errorf("found statistics for %d of the %d relationship tables", tableCount, tableTotal)
+	}
+
+	return nil
+}
+
+// namespaceVersion identifies a set of live namespaces by the highest transaction in which one
+// of them was written and by their number. Writing or deleting any namespace changes at least
+// one of the two.
+type namespaceVersion struct {
+	lastWrittenTxn uint64
+	liveCount      uint64
+}
+
+// decodedNamespaces holds the live namespaces decoded for statistics at a namespace version.
+type decodedNamespaces struct {
+	version namespaceVersion
+	nsDefs  []*core.NamespaceDefinition
+}
+
+// namespaceSnapshot holds the statistics read from a single snapshot of the datastore.
+type namespaceSnapshot struct {
+	revision         datastore.Revision
+	nsDefs           []*core.NamespaceDefinition
+	countByNamespace map[string]uint64
+
+	// namespacesLoad describes the load of the namespaces, or is nil if the namespaces decoded by
+	// a previous call were reused.
+	namespacesLoad *namespacesLoad
+}
+
+// namespacesLoad describes a load of the live namespaces for statistics.
+type namespacesLoad struct {
+	duration time.Duration
+	count    int
+}
+
+// namespaceStatistics loads the head revision, the live namespaces and, if enabled, the
+// relationship count for each namespace, all from the snapshot of the given transaction.
+func (mds *Datastore) namespaceStatistics(ctx context.Context, tx statisticsTx) (namespaceSnapshot, error) {
+	revision, err := mds.snapshotRevision(ctx, tx)
 	if err != nil {
-		return datastore.Stats{}, err
+		return namespaceSnapshot{}, err
+	}
+
+	nsDefs, load, err := mds.statisticsNamespaces(ctx, tx)
+	if err != nil {
+		return namespaceSnapshot{}, fmt.Errorf("unable to load namespaces: %w", err)
+	}
+
+	snapshot := namespaceSnapshot{revision: revision, nsDefs: nsDefs, namespacesLoad: load}
+	if !mds.perNamespaceStats {
+		return snapshot, nil
 	}
-	defer migrations.LogOnError(ctx, tx.Rollback)
 
+	snapshot.countByNamespace, err = mds.relationshipCountByNamespace(ctx, tx)
+	if err != nil {
+		return namespaceSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// snapshotRevision returns the head revision as seen by the given transaction, or NoRevision if no
+// transaction has been written.
+func (mds *Datastore) snapshotRevision(ctx context.Context, tx rowQuerier) (datastore.Revision, error) {
+	query, args, err := mds.GetLastRevision.ToSql()
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+
+	var revision sql.NullInt64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return datastore.NoRevision, nil
+		}
+		return datastore.NoRevision, fmt.Errorf(errRevision, err)
+	}
+
+	if !revision.Valid || revision.Int64 == 0 {
+		return datastore.NoRevision, nil
+	}
+
+	return revisionFromTransaction(uint64(revision.Int64)), nil
+}
+
+// statisticsNamespaces returns the live namespaces, reusing the namespaces decoded by a previous
+// call if no namespace has been written or deleted since, as decoding every namespace definition
+// is expensive when there are many of them. The load of the namespaces is returned if they were
+// not reused.
+func (mds *Datastore) statisticsNamespaces(ctx context.Context, tx statisticsTx) ([]*core.NamespaceDefinition, *namespacesLoad, error) {
+	version, err := mds.namespaceVersion(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if decoded, ok := mds.statsNamespaces.Load().(decodedNamespaces); ok && decoded.version == version {
+		return decoded.nsDefs, nil, nil
+	}
+
+	nsQuery := mds.ReadNamespaceQuery.Where(squirrel.Eq{colDeletedTxn: liveDeletedTxnID})
+	start := time.Now()
 	nsDefs, err := loadAllNamespaces(ctx, tx, nsQuery)
 	if err != nil {
-		return datastore.Stats{}, fmt.Errorf("unable to load namespaces: %w", err)
+		return nil, nil, err
 	}
 
-	return datastore.Stats{
-		UniqueID:                   uniqueID,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs),
-		EstimatedRelationshipCount: count,
+	mds.statsNamespaces.Store(decodedNamespaces{version: version, nsDefs: nsDefs})
+	return nsDefs, &namespacesLoad{duration: time.Since(start), count: len(nsDefs)}, nil
+}
+
+// observeNamespacesLoad records the duration and the number of namespaces of a load of the
+// namespaces for statistics, labeled by the unique ID of the datastore, if Prometheus stats are
+// enabled.
+func (mds *Datastore) observeNamespacesLoad(uniqueID string, load namespacesLoad) {
+	if !mds.enablePrometheusStats {
+		return
+	}
+
+	loadNamespacesHistogram.WithLabelValues(uniqueID).Observe(load.duration.Seconds())
+	loadedNamespacesGauge.WithLabelValues(uniqueID).Set(float64(load.count))
+}
+
+// namespaceVersion returns the version of the live namespaces, without loading their definitions.
+func (mds *Datastore) namespaceVersion(ctx context.Context, tx rowQuerier) (namespaceVersion, error) {
+	query, args, err := sb.
+		Select(maxCreatedTxnColumn, countAllColumn).
+		From(mds.driver.Namespace()).
+		Where(squirrel.Eq{colDeletedTxn: liveDeletedTxnID}).
+		ToSql()
+	if err != nil {
+		return namespaceVersion{}, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	// The highest transaction is NULL if there are no live namespaces.
+	var lastWrittenTxn sql.NullInt64
+	var liveCount uint64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&lastWrittenTxn, &liveCount); err != nil {
+		return namespaceVersion{}, fmt.Errorf("unable to query namespace version: %w", err)
+	}
+
+	return namespaceVersion{
+		lastWrittenTxn: uint64(lastWrittenTxn.Int64),
+		liveCount:      liveCount,
 	}, nil
 }
 
+// retryTransientErrors runs the given function, retrying it up to the given maximum number of
+// retries for as long as it fails with a transient error, waiting for a jittered backoff between
+// attempts so that the retries do not all land within the same lock conflict or failover.
+func retryTransientErrors(ctx context.Context, maxRetries uint8, backoff time.Duration, fn func() error) error {
+	var err error
+	for i := uint8(0); i <= maxRetries; i++ {
+		err = fn()
+		if err == nil || !isErrorTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		if i == maxRetries {
+			break
+		}
+
+		log.Ctx(ctx).Debug().Err(err).Uint8("attempt", i+1).Msg("retrying statistics query after transient error")
+		if err := waitForRetry(ctx, backoff, int(i)+1); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("max retries exceeded: %w", err)
+}
+
+// isErrorTransient returns whether the error is expected to resolve itself, such as a lost
+// connection, in addition to the errors retried for transactions.
+func isErrorTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var mysqlerr *mysql.MySQLError
+	if errors.As(err, &mysqlerr) {
+		switch mysqlerr.Number {
+		case errMysqlServerShutdown, errMysqlServerGone, errMysqlServerLost:
+			return true
+		}
+	}
+
+	return isErrorRetryable(err)
+}
+
+// relationshipCountByNamespace counts the live relationships for each namespace. Unlike the
+// estimated total, this requires scanning the relationships table.
+func (mds *Datastore) relationshipCountByNamespace(ctx context.Context, tx querier) (map[string]uint64, error) {
+	countByNamespace := make(map[string]uint64)
+	for _, table := range mds.driver.RelationTupleTables() {
+		if err := countRelationshipsByNamespace(ctx, tx, table, countByNamespace); err != nil {
+			return nil, err
+		}
+	}
+
+	return countByNamespace, nil
+}
+
+// countRelationshipsByNamespace adds the number of live relationships for each namespace in the
+// given table to countByNamespace.
+func countRelationshipsByNamespace(ctx context.Context, tx querier, table string, countByNamespace map[string]uint64) error {
+	query, args, err := sb.
+		Select(colNamespace, countAllColumn).
+		From(table).
+		Where(squirrel.Eq{colDeletedTxn: liveDeletedTxnID}).
+		GroupBy(colNamespace).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("unable to count relationships by namespace in table `%s`: %w", table, err)
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	for rows.Next() {
+		var namespace string
+		var count uint64
+		if err := rows.Scan(&namespace, &count); err != nil {
+			return fmt.Errorf("unable to count relationships by namespace in table `%s`: %w", table, err)
+		}
+		countByNamespace[namespace] += count
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("unable to count relationships by namespace in table `%s`: %w", table, err)
+	}
+
+	return nil
+}
+
+// analyzeRelationTupleTables runs ANALYZE TABLE on each of the relationship tuple tables in turn,
+// so that their estimated number of rows is up to date.
+func (mds *Datastore) analyzeRelationTupleTables(ctx context.Context) error {
+	// Only one analyze runs at a time, as concurrent runs on the same tables only contend.
+	select {
+	case mds.analyzeSem <- struct{}{}:
+		defer func() { <-mds.analyzeSem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, table := range mds.driver.RelationTupleTables() {
+		query := fmt.Sprintf(analyzeTableQuery, migrations.QuoteIdentifier(table))
+		start := time.Now()
+		_, err := mds.db.ExecContext(ctx, query)
+		mds.logSlowQuery(ctx, start, query)
+		if err != nil {
+			return fmt.Errorf("unable to analyze table `%s`: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// runPeriodicAnalyze runs the given analyze function at the given interval until the context is
+// canceled. Errors are logged rather than stopping the worker, as the next run may succeed.
+func runPeriodicAnalyze(ctx context.Context, interval time.Duration, analyzeFn func(context.Context) error) {
+	log.Info().Dur("interval", interval).Msg("periodic analyze worker started for mysql driver")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("shutting down periodic analyze worker for mysql driver")
+			return
+
+		case <-ticker.C:
+			if err := analyzeFn(ctx); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Msg("error when attempting to perform periodic analyze")
+			}
+		}
+	}
+}
+
+// analyzeWithTimeout runs the given analyze function, bounded by the given timeout. If the
+// timeout is reached, the error is dropped so that statistics can still be returned using the
+// previously analyzed table statistics. A timeout of zero or less does not bound the analyze.
+func analyzeWithTimeout(ctx context.Context, timeout time.Duration, analyzeFn func(context.Context) error) error {
+	if timeout <= 0 {
+		return analyzeFn(ctx)
+	}
+
+	analyzeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := analyzeFn(analyzeCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(analyzeCtx.Err(), context.DeadlineExceeded) {
+		log.Ctx(ctx).Warn().Dur("timeout", timeout).Msg("timed out analyzing relationships table, using last known statistics")
+		return nil
+	}
+
+	return err
+}
+
+// analyzeWithRetries runs the given analyze function up to the given number of attempts for as
+// long as it fails with a lock wait timeout or a deadlock, such as when colliding with concurrent
+// DDL, waiting for a jittered backoff between attempts. If the attempts are exhausted, the error is
+// dropped so that statistics can still be returned using the previously analyzed table statistics.
+func analyzeWithRetries(ctx context.Context, maxAttempts int, backoff time.Duration, analyzeFn func(context.Context) error) error {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = analyzeFn(ctx)
+		if err == nil || !isErrorRetryable(err) {
+			return err
+		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		log.Ctx(ctx).Debug().Err(err).Int("attempt", attempt).Msg("retrying analyze after lock conflict")
+		if err := waitForRetry(ctx, backoff, attempt); err != nil {
+			return err
+		}
+	}
+
+	log.Ctx(ctx).Warn().Err(err).Int("attempts", maxAttempts).Msg("unable to analyze relationships table after lock conflicts, using last known statistics")
+	return nil
+}
+
+// waitForRetry waits before retrying after the given failed attempt, counted from 1, or returns the
+// error of the context if it is done first. The backoff doubles on each attempt up to
+// maxRetryBackoff, and is jittered so that concurrent callers do not retry in lockstep.
+func waitForRetry(ctx context.Context, backoff time.Duration, attempt int) error {
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	half := int64(backoff) / 2
+	delay := time.Duration(half + rand.Int63n(half+1))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (mds *Datastore) getUniqueID(ctx context.Context) (string, error) {
-	sql, args, err := sb.Select(metadataUniqueIDColumn).From(mds.driver.Metadata()).ToSql()
+	metadata, err := mds.Metadata(ctx)
 	if err != nil {
-		return "", fmt.Errorf("unable to generate query sql: %w", err)
+		return "", err
 	}
 
-	var uniqueID string
-	if err := mds.db.QueryRowContext(ctx, sql, args...).Scan(&uniqueID); err != nil {
-		return "", fmt.Errorf("unable to query unique ID: %w", err)
+	return metadata.UniqueID, nil
+}
+
+// revisionedStats are statistics along with the revision at which they were computed.
+type revisionedStats struct {
+	datastore.Stats
+	revision datastore.Revision
+}
+
+type statisticsFunction func(context.Context) (revisionedStats, error)
+
+// statisticsCache caches computed statistics for a fixed TTL, and deduplicates concurrent
+// requests to recompute them.
+type statisticsCache struct {
+	ttl            time.Duration
+	computeFn      statisticsFunction
+	clockFn        clock.Clock
+	computeTimeout time.Duration
+
+	// this value is read and set by multiple consumers, it's protected
+	// by atomic load/store
+	lastStats atomic.Value
+
+	// the updategroup consolidates concurrent requests to the database into 1
+	updateGroup singleflight.Group
+}
+
+type validStatistics struct {
+	stats        revisionedStats
+	validThrough time.Time
+}
+
+func newStatisticsCache(ttl time.Duration, computeFn statisticsFunction) *statisticsCache {
+	return &statisticsCache{
+		ttl:            ttl,
+		computeFn:      computeFn,
+		clockFn:        clock.New(),
+		computeTimeout: statisticsComputeTimeout,
+	}
+}
+
+// get returns the cached statistics, recomputing them once they have expired. A recomputation is
+// shared by the concurrent callers, and therefore runs on a context detached from the cancellation
+// of the caller which started it, bounded by the compute timeout instead. Each caller stops waiting
+// for it once its own context is done.
+func (sc *statisticsCache) get(ctx context.Context) (revisionedStats, error) {
+	if sc.ttl <= 0 {
+		return sc.computeFn(ctx)
+	}
+
+	if last, ok := sc.lastStats.Load().(validStatistics); ok && sc.clockFn.Now().Before(last.validThrough) {
+		return last.stats, nil
+	}
+
+	computeCtx := detachedContext{ctx}
+	resultChan := sc.updateGroup.DoChan("", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(computeCtx, sc.computeTimeout)
+		defer cancel()
+
+		computed, err := sc.computeFn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		sc.lastStats.Store(validStatistics{computed, sc.clockFn.Now().Add(sc.ttl)})
+		return computed, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return revisionedStats{}, ctx.Err()
+	case result := <-resultChan:
+		if result.Err != nil {
+			return revisionedStats{}, result.Err
+		}
+		return result.Val.(revisionedStats), nil
+	}
+}
+
+// detachedContext carries the values of its parent context, but neither its deadline nor its
+// cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (dc detachedContext) Value(key interface{}) interface{} { return dc.parent.Value(key) }
+
+// store replaces the cached statistics with the given statistics, computed outside of the cache.
+func (sc *statisticsCache) store(stats revisionedStats) {
+	if sc.ttl <= 0 {
+		return
 	}
 
-	return uniqueID, nil
+	sc.lastStats.Store(validStatistics{stats, sc.clockFn.Now().Add(sc.ttl)})
 }
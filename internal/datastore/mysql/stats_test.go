@@ -0,0 +1,611 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func TestStatisticsCache(t *testing.T) {
+	require := require.New(t)
+
+	var computeCount uint64
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		count := atomic.AddUint64(&computeCount, 1)
+		return revisionedStats{Stats: datastore.Stats{UniqueID: "someid", EstimatedRelationshipCount: count}}, nil
+	})
+
+	mockClock := clock.NewMock()
+	sc.clockFn = mockClock
+
+	ctx := context.Background()
+	stats, err := sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(1), stats.EstimatedRelationshipCount)
+
+	mockClock.Add(4 * time.Second)
+	stats, err = sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(1), stats.EstimatedRelationshipCount)
+
+	mockClock.Add(2 * time.Second)
+	stats, err = sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(2), stats.EstimatedRelationshipCount)
+	require.Equal("someid", stats.UniqueID)
+}
+
+func TestStatisticsCacheDisabled(t *testing.T) {
+	require := require.New(t)
+
+	var computeCount uint64
+	sc := newStatisticsCache(0, func(ctx context.Context) (revisionedStats, error) {
+		return revisionedStats{Stats: datastore.Stats{EstimatedRelationshipCount: atomic.AddUint64(&computeCount, 1)}}, nil
+	})
+
+	for i := uint64(1); i <= 3; i++ {
+		stats, err := sc.get(context.Background())
+		require.NoError(err)
+		require.Equal(i, stats.EstimatedRelationshipCount)
+	}
+}
+
+func TestStatisticsCacheSingleFlight(t *testing.T) {
+	require := require.New(t)
+
+	var computeCount uint64
+	release := make(chan struct{})
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		<-release
+		return revisionedStats{Stats: datastore.Stats{EstimatedRelationshipCount: atomic.AddUint64(&computeCount, 1)}}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats, err := sc.get(context.Background())
+			require.NoError(err)
+			require.Equal(uint64(1), stats.EstimatedRelationshipCount)
+		}()
+	}
+
+	// Give the goroutines a chance to all request the statistics.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	require.Equal(uint64(1), atomic.LoadUint64(&computeCount))
+}
+
+func TestStatisticsCacheCallerCanceled(t *testing.T) {
+	require := require.New(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	computeErr := make(chan error, 1)
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		close(started)
+		<-release
+		computeErr <- ctx.Err()
+		return revisionedStats{Stats: datastore.Stats{UniqueID: "someid"}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := sc.get(ctx)
+		firstErr <- err
+	}()
+	<-started
+
+	secondStats := make(chan revisionedStats)
+	go func() {
+		stats, err := sc.get(context.Background())
+		require.NoError(err)
+		secondStats <- stats
+	}()
+
+	// Canceling the caller which started the computation neither cancels it nor fails the
+	// other callers waiting for it.
+	cancel()
+	require.ErrorIs(<-firstErr, context.Canceled)
+
+	close(release)
+	require.Equal("someid", (<-secondStats).UniqueID)
+	require.NoError(<-computeErr)
+}
+
+func TestStatisticsCacheComputeTimeout(t *testing.T) {
+	require := require.New(t)
+
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		<-ctx.Done()
+		return revisionedStats{}, ctx.Err()
+	})
+	sc.computeTimeout = 10 * time.Millisecond
+
+	_, err := sc.get(context.Background())
+	require.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func TestStatisticsCacheValidFromComputation(t *testing.T) {
+	require := require.New(t)
+
+	mockClock := clock.NewMock()
+	var computeCount uint64
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		// The statistics are only valid for the TTL from the time they were computed.
+		mockClock.Add(4 * time.Second)
+		return revisionedStats{Stats: datastore.Stats{EstimatedRelationshipCount: atomic.AddUint64(&computeCount, 1)}}, nil
+	})
+	sc.clockFn = mockClock
+
+	_, err := sc.get(context.Background())
+	require.NoError(err)
+
+	mockClock.Add(4 * time.Second)
+	stats, err := sc.get(context.Background())
+	require.NoError(err)
+	require.Equal(uint64(1), stats.EstimatedRelationshipCount)
+}
+
+func TestAnalyzeWithTimeout(t *testing.T) {
+	slowAnalyze := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Minute):
+			return nil
+		}
+	}
+
+	t.Run("timed out", func(t *testing.T) {
+		require.NoError(t, analyzeWithTimeout(context.Background(), 10*time.Millisecond, slowAnalyze))
+	})
+
+	t.Run("parent canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, analyzeWithTimeout(ctx, time.Minute, slowAnalyze), context.Canceled)
+	})
+
+	t.Run("analyze error", func(t *testing.T) {
+		analyzeErr := errors.New("analyze failed")
+		err := analyzeWithTimeout(context.Background(), time.Minute, func(ctx context.Context) error {
+			return analyzeErr
+		})
+		require.ErrorIs(t, err, analyzeErr)
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		var called bool
+		err := analyzeWithTimeout(context.Background(), 0, func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			require.False(t, hasDeadline)
+			called = true
+			return nil
+		})
+		require.NoError(t, err)
+		require.True(t, called)
+	})
+}
+
+func TestAnalyzeWithRetries(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: errMysqlDeadlock, Message: "Deadlock found when trying to get lock"}
+	lockWaitTimeout := &mysql.MySQLError{Number: errMysqlLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	missingTable := &mysql.MySQLError{Number: 1146, Message: "Table 'relation_tuple' doesn't exist"}
+
+	testCases := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"deadlock on first attempt", []error{deadlock}, 2, nil},
+		{"lock wait timeout twice", []error{lockWaitTimeout, lockWaitTimeout}, 3, nil},
+		{"missing table", []error{missingTable}, 1, missingTable},
+		{"attempts exhausted", []error{deadlock, deadlock, deadlock, deadlock}, 3, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			calls := 0
+			err := analyzeWithRetries(context.Background(), 3, time.Millisecond, func(ctx context.Context) error {
+				calls++
+				if calls <= len(tc.errs) {
+					return tc.errs[calls-1]
+				}
+				return nil
+			})
+
+			require.Equal(tc.expectedCalls, calls)
+			if tc.expectedErr == nil {
+				require.NoError(err)
+			} else {
+				require.ErrorIs(err, tc.expectedErr)
+			}
+		})
+	}
+
+	t.Run("canceled during backoff", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := analyzeWithRetries(ctx, 3, time.Minute, func(ctx context.Context) error {
+			cancel()
+			return deadlock
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestRunPeriodicAnalyze(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	var calls uint64
+	go func() {
+		defer close(done)
+		runPeriodicAnalyze(ctx, time.Millisecond, func(ctx context.Context) error {
+			// Errors do not stop the worker.
+			if atomic.AddUint64(&calls, 1) == 1 {
+				return errors.New("analyze failed")
+			}
+			return nil
+		})
+	}()
+
+	require.Eventually(func() bool {
+		return atomic.LoadUint64(&calls) >= 3
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow("periodic analyze did not stop once canceled")
+	}
+}
+
+func TestAnalyzeRelationTupleTablesCanceledWhileAnotherRuns(t *testing.T) {
+	mds := &Datastore{analyzeSem: make(chan struct{}, 1)}
+
+	// Another analyze holds the token, so this one waits until canceled without running.
+	mds.analyzeSem <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, mds.analyzeRelationTupleTables(ctx), context.DeadlineExceeded)
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	missingTable := &mysql.MySQLError{Number: 1146, Message: "Table 'relation_tuple' doesn't exist"}
+
+	testCases := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{"success", nil, 1, nil},
+		{"connection lost once", []error{mysql.ErrInvalidConn}, 2, nil},
+		{"bad connection once", []error{driver.ErrBadConn}, 2, nil},
+		{"server gone once", []error{&mysql.MySQLError{Number: errMysqlServerGone}}, 2, nil},
+		{"deadlock once", []error{&mysql.MySQLError{Number: errMysqlDeadlock}}, 2, nil},
+		{"missing table", []error{missingTable}, 1, missingTable},
+		{"retries exhausted", []error{mysql.ErrInvalidConn, mysql.ErrInvalidConn, mysql.ErrInvalidConn}, 3, mysql.ErrInvalidConn},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			calls := 0
+			err := retryTransientErrors(context.Background(), 2, time.Millisecond, func() error {
+				calls++
+				if calls <= len(tc.errs) {
+					return tc.errs[calls-1]
+				}
+				return nil
+			})
+
+			require.Equal(tc.expectedCalls, calls)
+			if tc.expectedErr == nil {
+				require.NoError(err)
+			} else {
+				require.ErrorIs(err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestRetryTransientErrorsBacksOff(t *testing.T) {
+	require := require.New(t)
+
+	const backoff = 20 * time.Millisecond
+
+	var attempts []time.Time
+	err := retryTransientErrors(context.Background(), 2, backoff, func() error {
+		attempts = append(attempts, time.Now())
+		return mysql.ErrInvalidConn
+	})
+	require.ErrorIs(err, mysql.ErrInvalidConn)
+	require.Len(attempts, 3)
+
+	// The backoff is jittered between half of and the full backoff, which doubles on each attempt.
+	require.GreaterOrEqual(attempts[1].Sub(attempts[0]), backoff/2)
+	require.GreaterOrEqual(attempts[2].Sub(attempts[1]), backoff)
+}
+
+func TestRetryTransientErrorsCanceledWhileWaiting(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := retryTransientErrors(ctx, 2, time.Hour, func() error {
+		calls++
+		return mysql.ErrInvalidConn
+	})
+	require.ErrorIs(err, context.DeadlineExceeded)
+	require.Equal(1, calls)
+}
+
+func TestStatisticsCacheStore(t *testing.T) {
+	require := require.New(t)
+
+	var computeCount uint64
+	sc := newStatisticsCache(5*time.Second, func(ctx context.Context) (revisionedStats, error) {
+		return revisionedStats{Stats: datastore.Stats{EstimatedRelationshipCount: atomic.AddUint64(&computeCount, 1)}}, nil
+	})
+
+	mockClock := clock.NewMock()
+	sc.clockFn = mockClock
+
+	ctx := context.Background()
+	stats, err := sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(1), stats.EstimatedRelationshipCount)
+
+	// Stored statistics replace the cached statistics, and are valid for the full TTL.
+	mockClock.Add(4 * time.Second)
+	sc.store(revisionedStats{Stats: datastore.Stats{EstimatedRelationshipCount: 42}})
+
+	mockClock.Add(4 * time.Second)
+	stats, err = sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(42), stats.EstimatedRelationshipCount)
+
+	mockClock.Add(2 * time.Second)
+	stats, err = sc.get(ctx)
+	require.NoError(err)
+	require.Equal(uint64(2), stats.EstimatedRelationshipCount)
+}
+
+func TestNewRelationshipEstimate(t *testing.T) {
+	require := require.New(t)
+
+	count := sql.NullInt64{Int64: 42, Valid: true}
+	estimate := newRelationshipEstimate(count, sql.NullInt64{})
+	require.Equal(uint64(42), estimate.count)
+	require.Nil(estimate.updatedAt)
+
+	estimate = newRelationshipEstimate(count, sql.NullInt64{Int64: 1660000000, Valid: true})
+	require.Equal(uint64(42), estimate.count)
+	require.NotNil(estimate.updatedAt)
+	require.Equal(time.Unix(1660000000, 0), *estimate.updatedAt)
+
+	// Tables which report no number of rows are estimated to be empty.
+	estimate = newRelationshipEstimate(sql.NullInt64{}, sql.NullInt64{})
+	require.Zero(estimate.count)
+	require.Nil(estimate.updatedAt)
+}
+
+func TestPreparedStatementsClosed(t *testing.T) {
+	require := require.New(t)
+
+	db, err := sql.Open("mysql", "root:secret@tcp(localhost:3306)/spicedb")
+	require.NoError(err)
+
+	var statements preparedStatements
+	require.NoError(statements.close())
+
+	// Once closed, no statement is prepared, so the database is never reached.
+	_, err = statements.get(context.Background(), db, "SELECT 1")
+	require.EqualError(err, errStatementsClosed)
+}
+
+func TestPreparedStatementsCacheOrGet(t *testing.T) {
+	require := require.New(t)
+
+	db, err := sql.Open("mysql", "root:secret@tcp(localhost:3306)/spicedb")
+	require.NoError(err)
+
+	var statements preparedStatements
+	key := preparedStatementKey{db: db, query: "SELECT 1"}
+
+	stmt, err := statements.cached(key)
+	require.NoError(err)
+	require.Nil(stmt)
+
+	// The first statement prepared for a query is cached, and returned to the calls which prepared
+	// the same query concurrently.
+	first, second := &sql.Stmt{}, &sql.Stmt{}
+	stmt, err = statements.cacheOrGet(key, first)
+	require.NoError(err)
+	require.Same(first, stmt)
+
+	stmt, err = statements.cacheOrGet(key, second)
+	require.NoError(err)
+	require.Same(first, stmt)
+
+	stmt, err = statements.cached(key)
+	require.NoError(err)
+	require.Same(first, stmt)
+
+	// A statement prepared while the cache was closed is not cached.
+	statements.mu.Lock()
+	statements.closed = true
+	statements.mu.Unlock()
+
+	_, err = statements.cacheOrGet(preparedStatementKey{db: db, query: "SELECT 2"}, second)
+	require.EqualError(err, errStatementsClosed)
+	require.Len(statements.stmts, 1)
+}
+
+func TestOnStatisticsDB(t *testing.T) {
+	primary, err := sql.Open("mysql", "root:secret@tcp(localhost:3306)/spicedb")
+	require.NoError(t, err)
+	replica, err := sql.Open("mysql", "root:secret@tcp(localhost:3307)/spicedb")
+	require.NoError(t, err)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name          string
+		replica       *sql.DB
+		ctx           context.Context
+		replicaErr    error
+		expectedDBs   []*sql.DB
+		expectedError error
+	}{
+		{"no replica", nil, context.Background(), nil, []*sql.DB{primary}, nil},
+		{"replica", replica, context.Background(), nil, []*sql.DB{replica}, nil},
+		{"replica fails", replica, context.Background(), driver.ErrBadConn, []*sql.DB{replica, primary}, nil},
+		{"context done", replica, canceled, context.Canceled, []*sql.DB{replica}, context.Canceled},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mds := &Datastore{db: primary, readReplicaDB: tc.replica}
+
+			var used []*sql.DB
+			err := mds.onStatisticsDB(tc.ctx, func(db *sql.DB) error {
+				used = append(used, db)
+				if db == replica {
+					return tc.replicaErr
+				}
+				return nil
+			})
+			require.ErrorIs(t, err, tc.expectedError)
+			require.Equal(t, tc.expectedDBs, used)
+		})
+	}
+}
+
+func TestObserveNamespacesLoad(t *testing.T) {
+	require := require.New(t)
+
+	// Nothing is observed unless Prometheus stats are enabled.
+	mds := &Datastore{}
+	mds.observeNamespacesLoad("observedid", namespacesLoad{duration: time.Second, count: 3})
+	require.Equal(0, testutil.CollectAndCount(loadedNamespacesGauge))
+
+	mds.enablePrometheusStats = true
+	mds.observeNamespacesLoad("observedid", namespacesLoad{duration: time.Second, count: 3})
+	mds.observeNamespacesLoad("observedid", namespacesLoad{duration: 2 * time.Second, count: 5})
+	require.Equal(float64(5), testutil.ToFloat64(loadedNamespacesGauge.WithLabelValues("observedid")))
+	require.Equal(1, testutil.CollectAndCount(loadNamespacesHistogram))
+}
+
+func TestShutdownWaitsForStatistics(t *testing.T) {
+	require := require.New(t)
+
+	db, err := sql.Open("mysql", "root:secret@tcp(localhost:3306)/spicedb")
+	require.NoError(err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mds := &Datastore{
+		db:       db,
+		cancelGc: func() {},
+		statsCache: newStatisticsCache(time.Minute, func(ctx context.Context) (revisionedStats, error) {
+			close(started)
+			<-release
+			return revisionedStats{Stats: datastore.Stats{UniqueID: "someid"}}, nil
+		}),
+	}
+
+	statsErr := make(chan error)
+	go func() {
+		_, err := mds.Statistics(context.Background())
+		statsErr <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error)
+	go func() {
+		shutdownErr <- mds.Shutdown(context.Background())
+	}()
+
+	// Shutting down waits for the statistics call in flight, while rejecting new calls.
+	require.Eventually(func() bool {
+		_, err := mds.Statistics(context.Background())
+		return err != nil && err.Error() == errShuttingDown
+	}, time.Second, time.Millisecond)
+
+	select {
+	case <-shutdownErr:
+		require.FailNow("shutdown completed before the statistics call")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(<-statsErr)
+	require.NoError(<-shutdownErr)
+
+	_, _, err = mds.CountsOnly(context.Background())
+	require.EqualError(err, errShuttingDown)
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	require := require.New(t)
+
+	db, err := sql.Open("mysql", "root:secret@tcp(localhost:3306)/spicedb")
+	require.NoError(err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	mds := &Datastore{
+		db:       db,
+		cancelGc: func() {},
+		statsCache: newStatisticsCache(time.Minute, func(ctx context.Context) (revisionedStats, error) {
+			close(started)
+			<-release
+			return revisionedStats{}, nil
+		}),
+	}
+
+	go func() {
+		_, _ = mds.Statistics(context.Background())
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = mds.Shutdown(ctx)
+	require.ErrorIs(err, context.DeadlineExceeded)
+}
@@ -65,7 +65,11 @@ func (crr *ConcurrentReachableResources) ReachableResources(
 		return err
 	}
 
-	rg := namespace.ReachabilityGraphFor(typeSystem.AsValidated())
+	rg, err := namespace.ReachabilityGraphFor(typeSystem.AsValidated())
+	if err != nil {
+		return err
+	}
+
 	entrypoints, err := rg.OptimizedEntrypointsForSubjectToResource(ctx, &core.RelationReference{
 		Namespace: req.Subject.Namespace,
 		Relation:  req.Subject.Relation,
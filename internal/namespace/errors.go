@@ -1,11 +1,15 @@
 package namespace
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/rs/zerolog"
 
 	"github.com/authzed/spicedb/internal/sharederrors"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 // ErrRelationNotFound occurs when a relation was not found under a namespace.
@@ -39,3 +43,109 @@ func NewRelationNotFoundErr(nsName string, relationName string) error {
 }
 
 var _ sharederrors.UnknownRelationError = ErrRelationNotFound{}
+
+// ErrNamespaceNotFound occurs when a namespace looked up by the type system does not exist. It
+// wraps the error returned by the lookup, if any.
+type ErrNamespaceNotFound struct {
+	error
+	namespaceName string
+	cause         error
+}
+
+// NotFoundNamespaceName returns the name of the namespace not found.
+func (enf ErrNamespaceNotFound) NotFoundNamespaceName() string {
+	return enf.namespaceName
+}
+
+// Unwrap returns the error returned by the lookup of the namespace, if any.
+func (enf ErrNamespaceNotFound) Unwrap() error {
+	return enf.cause
+}
+
+func (enf ErrNamespaceNotFound) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("error", enf.Error()).Str("namespace", enf.namespaceName)
+}
+
+// NewNamespaceNotFoundErr constructs a new namespace not found error, wrapping the error
+// returned by the lookup of the namespace, if any. The message of the lookup error is kept.
+func NewNamespaceNotFoundErr(nsName string, cause error) error {
+	err := cause
+	if err == nil {
+		err = fmt.Errorf("object definition `%s` not found", nsName)
+	}
+
+	return ErrNamespaceNotFound{
+		error:         err,
+		namespaceName: nsName,
+		cause:         cause,
+	}
+}
+
+var _ sharederrors.UnknownNamespaceError = ErrNamespaceNotFound{}
+
+// ErrReferencedNamespaceNotFound occurs when a relation references a namespace which no longer
+// exists, such as after the namespace was deleted by a schema change. It wraps the
+// ErrNamespaceNotFound for the namespace.
+type ErrReferencedNamespaceNotFound struct {
+	error
+	namespaceName       string
+	referencingRelation *core.RelationReference
+	cause               error
+}
+
+// NotFoundNamespaceName returns the name of the namespace not found.
+func (erf ErrReferencedNamespaceNotFound) NotFoundNamespaceName() string {
+	return erf.namespaceName
+}
+
+// ReferencingRelation returns the relation or permission which references the namespace.
+func (erf ErrReferencedNamespaceNotFound) ReferencingRelation() *core.RelationReference {
+	return erf.referencingRelation
+}
+
+// Unwrap returns the ErrNamespaceNotFound for the namespace.
+func (erf ErrReferencedNamespaceNotFound) Unwrap() error {
+	return erf.cause
+}
+
+func (erf ErrReferencedNamespaceNotFound) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("error", erf.Error()).Str("namespace", erf.namespaceName).Str("relation", tuple.StringRR(erf.referencingRelation))
+}
+
+var _ sharederrors.UnknownNamespaceError = ErrReferencedNamespaceNotFound{}
+
+// wrapReferencedNamespaceNotFound wraps the given error with the relation referencing the
+// missing namespace if the error is an ErrNamespaceNotFound, and returns it unchanged otherwise.
+// An error already wrapped with a referencing relation is left unchanged.
+func wrapReferencedNamespaceNotFound(err error, referencingRelation *core.RelationReference) error {
+	var referencedErr ErrReferencedNamespaceNotFound
+	if errors.As(err, &referencedErr) {
+		return err
+	}
+
+	var notFoundErr ErrNamespaceNotFound
+	if !errors.As(err, &notFoundErr) {
+		return err
+	}
+
+	return ErrReferencedNamespaceNotFound{
+		error: fmt.Errorf(
+			"definition `%s` referenced by relation/permission `%s` no longer exists",
+			notFoundErr.namespaceName,
+			tuple.StringRR(referencingRelation),
+		),
+		namespaceName:       notFoundErr.namespaceName,
+		referencingRelation: referencingRelation,
+		cause:               err,
+	}
+}
+
+// asNamespaceNotFound converts an error returned by a lookup of the namespace with the given
+// name into an ErrNamespaceNotFound, if it reports that the namespace does not exist.
+func asNamespaceNotFound(err error, nsName string) error {
+	if errors.As(err, &ErrNamespaceNotFound{}) || !errors.As(err, &datastore.ErrNamespaceNotFound{}) {
+		return err
+	}
+
+	return NewNamespaceNotFoundErr(nsName, err)
+}
@@ -2,6 +2,10 @@ package namespace
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
 
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
@@ -16,7 +20,35 @@ func decorateRelationOpPaths(relation *core.Relation) error {
 		return nil
 	}
 
-	return decorateRelationRewritePath(rewrite, []uint32{})
+	if err := decorateRelationRewritePath(rewrite, []uint32{}); err != nil {
+		return fmt.Errorf("unable to decorate operation paths of relation `%s`: %w", relation.Name, err)
+	}
+	return nil
+}
+
+// decorateNamespaceOpPaths decorates all of the relations of the given namespace with operation
+// paths. The relations of a namespace must all be decorated before any of them is walked, as
+// computing the reachability of a relation reads the operation paths of the relations it
+// references.
+func decorateNamespaceOpPaths(nsDef *core.NamespaceDefinition) error {
+	for _, relation := range nsDef.Relation {
+		if err := decorateRelationOpPaths(relation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildDecoratedTypeSystem builds a type system over a copy of the given namespace definition,
+// with all of its relations decorated with operation paths. Definitions may be shared by several
+// type systems and read concurrently, so they are never decorated in place.
+func buildDecoratedTypeSystem(nsDef *core.NamespaceDefinition, lookupNamespace LookupNamespace) (*TypeSystem, error) {
+	decorated := proto.Clone(nsDef).(*core.NamespaceDefinition)
+	if err := decorateNamespaceOpPaths(decorated); err != nil {
+		return nil, fmt.Errorf("unable to compute reachability under namespace `%s`: %w", nsDef.Name, err)
+	}
+
+	return BuildNamespaceTypeSystem(decorated, lookupNamespace)
 }
 
 func decorateRelationRewritePath(rewrite *core.UsersetRewrite, parentPath []uint32) error {
@@ -28,7 +60,7 @@ func decorateRelationRewritePath(rewrite *core.UsersetRewrite, parentPath []uint
 	case *core.UsersetRewrite_Exclusion:
 		return decorateRelationRewriteChildrenWithPath(rw.Exclusion, parentPath)
 	default:
-		return fmt.Errorf("unknown type of rewrite operation in rewrite path annotator: %T", rw)
+		return fmt.Errorf("unknown type of rewrite operation at operation [%s] in rewrite path annotator: %T", formatOperationPath(parentPath), rw)
 	}
 }
 
@@ -38,10 +70,15 @@ func decorateRelationRewriteChildrenWithPath(so *core.SetOperation, parentPath [
 			return nil
 		}
 
-		newPath := append(parentPath, uint32(index))
+		// Copy the parent path, as siblings must not share the backing array of their paths.
+		newPath := make([]uint32, 0, len(parentPath)+1)
+		newPath = append(newPath, parentPath...)
+		newPath = append(newPath, uint32(index))
 		childOneof.OperationPath = newPath
 
 		switch child := childOneof.ChildType.(type) {
+		case nil:
+			return fmt.Errorf("missing child for operation [%s] in rewrite path annotator", formatOperationPath(newPath))
 		case *core.SetOperation_Child_UsersetRewrite:
 			if err := decorateRelationRewritePath(child.UsersetRewrite, newPath); err != nil {
 				return err
@@ -50,3 +87,12 @@ func decorateRelationRewriteChildrenWithPath(so *core.SetOperation, parentPath [
 	}
 	return nil
 }
+
+// formatOperationPath returns the dotted form of an operation path, e.g. `0.2`.
+func formatOperationPath(operationPath []uint32) string {
+	pathParts := make([]string, 0, len(operationPath))
+	for _, index := range operationPath {
+		pathParts = append(pathParts, strconv.FormatUint(uint64(index), 10))
+	}
+	return strings.Join(pathParts, ".")
+}
@@ -0,0 +1,71 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func TestDecorateRelationOpPaths(t *testing.T) {
+	testCases := []struct {
+		name          string
+		relation      *core.Relation
+		expectedError string
+	}{
+		{
+			"valid rewrite",
+			ns.Relation("view", ns.Union(
+				ns.ComputedUserset("viewer"),
+				ns.Rewrite(ns.Intersection(ns.ComputedUserset("editor"), ns.ComputedUserset("member"))),
+			)),
+			"",
+		},
+		{
+			"missing nested rewrite operation",
+			ns.Relation("view", ns.Union(
+				ns.ComputedUserset("viewer"),
+				ns.Rewrite(ns.Intersection(ns.ComputedUserset("editor"), ns.Rewrite(&core.UsersetRewrite{}))),
+			)),
+			"unable to decorate operation paths of relation `view`: unknown type of rewrite operation at operation [1.1] in rewrite path annotator: <nil>",
+		},
+		{
+			"missing child",
+			ns.Relation("view", ns.Union(
+				ns.ComputedUserset("viewer"),
+				&core.SetOperation_Child{},
+			)),
+			"unable to decorate operation paths of relation `view`: missing child for operation [1] in rewrite path annotator",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := decorateRelationOpPaths(tc.relation)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestDecorateRelationOpPathsOfSiblings(t *testing.T) {
+	relation := ns.Relation("view", ns.Union(
+		ns.Rewrite(ns.Exclusion(
+			ns.ComputedUserset("viewer"),
+			ns.ComputedUserset("banned"),
+		)),
+		ns.ComputedUserset("editor"),
+	))
+	require.NoError(t, decorateRelationOpPaths(relation))
+
+	nested := relation.GetUsersetRewrite().GetUnion().Child[0].GetUsersetRewrite().GetExclusion()
+	require.Equal(t, []uint32{0, 0}, nested.Child[0].OperationPath)
+	require.Equal(t, []uint32{0, 1}, nested.Child[1].OperationPath)
+}
@@ -0,0 +1,307 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/authzed/spicedb/pkg/graph"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// AmbiguousArrowInfo describes an arrow whose tupleset relation allows subjects of more than one
+// namespace, such that the computed userset of the arrow is resolved across all of them.
+type AmbiguousArrowInfo struct {
+	// Entrypoint is the entrypoint of the arrow into its containing relation or permission, of
+	// kind TUPLESET_TO_USERSET_ENTRYPOINT.
+	Entrypoint ReachabilityEntrypoint
+
+	// TuplesetRelation is the tupleset relation of the arrow, on the left hand side of the arrow.
+	TuplesetRelation *core.RelationReference
+
+	// TargetNamespaces are the distinct namespaces allowed on the tupleset relation, sorted by
+	// name, and always holding more than one namespace.
+	TargetNamespaces []string
+}
+
+// AmbiguousArrowEntrypoints walks the reachability graph from the given subject type to the given
+// resource type and returns the arrows walked whose tupleset relation allows subjects of more than
+// one namespace. For example, given `relation parent: folder | organization` and
+// `permission view = parent->viewer`, the arrow is resolved against both `folder#viewer` and
+// `organization#viewer`, which may not be intended by the schema author.
+//
+// An arrow is walked if its computed userset is reached by the subject type, either directly or
+// via other relations. Each arrow is reported once, even if walked via more than one of its target
+// namespaces, and the arrows are sorted by entrypoint.
+func (rg *ReachabilityGraph) AmbiguousArrowEntrypoints(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]AmbiguousArrowInfo, error) {
+	ec, err := rg.walkSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
+	if err != nil {
+		return nil, err
+	}
+
+	subjectTypeKey := relationKey(subjectType.Namespace, subjectType.Relation)
+	typeSystems := map[string]*TypeSystem{}
+	reported := map[string]struct{}{}
+	ambiguous := []AmbiguousArrowInfo{}
+	for _, relation := range ec.traversed {
+		g := ec.computedGraphs[relationKey(relation.Namespace, relation.Relation)]
+		for subjectRelationKey, entrypointSet := range g.EntrypointsBySubjectRelation {
+			// Only arrows from the subject type or from a walked relation were walked.
+			if _, walked := ec.encounteredRelations[subjectRelationKey]; !walked && subjectRelationKey != subjectTypeKey {
+				continue
+			}
+
+			for _, entrypoint := range entrypointSet.Entrypoints {
+				if entrypoint.Kind != core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT {
+					continue
+				}
+
+				arrow := ReachabilityEntrypoint{re: entrypoint, parentRelation: relation}
+				hashKey := arrow.HashKey()
+				if _, ok := reported[hashKey]; ok {
+					continue
+				}
+				reported[hashKey] = struct{}{}
+
+				ts, ok := typeSystems[relation.Namespace]
+				if !ok {
+					ts, err = rg.ts.typeSystemForNamespace(ctx, relation.Namespace)
+					if err != nil {
+						return nil, err
+					}
+					typeSystems[relation.Namespace] = ts
+				}
+
+				info, err := ambiguousArrowInfo(ts, arrow)
+				if err != nil {
+					return nil, err
+				}
+
+				if info != nil {
+					ambiguous = append(ambiguous, *info)
+				}
+			}
+		}
+	}
+
+	sort.Slice(ambiguous, func(i, j int) bool {
+		return ambiguous[i].Entrypoint.String() < ambiguous[j].Entrypoint.String()
+	})
+	return ambiguous, nil
+}
+
+// ambiguousArrowInfo returns the AmbiguousArrowInfo for the given arrow entrypoint, or nil if its
+// tupleset relation allows subjects of a single namespace.
+func ambiguousArrowInfo(ts *TypeSystem, entrypoint ReachabilityEntrypoint) (*AmbiguousArrowInfo, error) {
+	ttu, err := entrypoint.TupleToUsersetE(ts.nsDef)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttu == nil {
+		return nil, fmt.Errorf("missing arrow for entrypoint %s", entrypoint)
+	}
+
+	allowedRelations, err := ts.AllowedDirectRelationsAndWildcards(ttu.Tupleset.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := map[string]struct{}{}
+	for _, allowedRelation := range allowedRelations {
+		namespaces[allowedRelation.Namespace] = struct{}{}
+	}
+
+	if len(namespaces) < 2 {
+		return nil, nil
+	}
+
+	targetNamespaces := make([]string, 0, len(namespaces))
+	for namespaceName := range namespaces {
+		targetNamespaces = append(targetNamespaces, namespaceName)
+	}
+	sort.Strings(targetNamespaces)
+
+	return &AmbiguousArrowInfo{
+		Entrypoint: entrypoint,
+		TuplesetRelation: &core.RelationReference{
+			Namespace: ts.nsDef.Name,
+			Relation:  ttu.Tupleset.Relation,
+		},
+		TargetNamespaces: targetNamespaces,
+	}, nil
+}
+
+// ArrowError describes an arrow which can never be resolved, either because its tupleset relation
+// does not exist, or because its computed userset relation does not exist on one of the types
+// allowed on its tupleset relation.
+type ArrowError struct {
+	// Relation is the relation or permission containing the arrow.
+	Relation *core.RelationReference
+
+	// TuplesetRelation is the tupleset relation of the arrow, on the left hand side of the arrow.
+	TuplesetRelation *core.RelationReference
+
+	// ComputedUsersetRelation is the name of the relation on the right hand side of the arrow.
+	ComputedUsersetRelation string
+
+	// TargetNamespace is the namespace allowed on the tupleset relation which has no computed
+	// userset relation, or empty if the tupleset relation does not exist.
+	TargetNamespace string
+}
+
+// Error implements the error interface.
+func (ae ArrowError) Error() string {
+	if ae.TargetNamespace == "" {
+		return fmt.Sprintf("under `%s`: tupleset relation `%s` of arrow `%s->%s` was not found",
+			tuple.StringRR(ae.Relation), tuple.StringRR(ae.TuplesetRelation), ae.TuplesetRelation.Relation, ae.ComputedUsersetRelation)
+	}
+
+	return fmt.Sprintf("under `%s`: relation/permission `%s` of arrow `%s->%s` was not found under definition `%s`",
+		tuple.StringRR(ae.Relation), ae.ComputedUsersetRelation, ae.TuplesetRelation.Relation, ae.ComputedUsersetRelation, ae.TargetNamespace)
+}
+
+// ValidateArrowTargets walks every relation and permission reachable from the given resource type,
+// and returns an ArrowError for each arrow whose tupleset relation does not exist or whose computed
+// userset relation does not exist on a type allowed on its tupleset relation. Such arrows are
+// silently skipped when computing entrypoints, so they are not caught by walks. For example, given
+// `relation parent: folder` and `permission view = parent->viewer`, an error is reported if
+// `folder` has no `viewer` relation or permission.
+//
+// The relations walked are those rewritten into the resource type, those reached through arrows,
+// and the subject relations allowed on them. The errors are sorted by containing relation, then
+// by tupleset relation and target namespace.
+func (rg *ReachabilityGraph) ValidateArrowTargets(ctx context.Context, resourceType *core.RelationReference) ([]ArrowError, error) {
+	arrowErrors := []ArrowError{}
+	encountered := map[string]struct{}{}
+	toWalk := []*core.RelationReference{resourceType}
+	for len(toWalk) > 0 {
+		relation := toWalk[0]
+		toWalk = toWalk[1:]
+
+		key := relationKey(relation.Namespace, relation.Relation)
+		if _, ok := encountered[key]; ok {
+			continue
+		}
+		encountered[key] = struct{}{}
+
+		ts, err := rg.walkedTypeSystem(ctx, relation.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		found, ok := ts.relationMap[relation.Relation]
+		if !ok {
+			return nil, fmt.Errorf("unknown relation `%s` under namespace `%s` for reachability", relation.Relation, relation.Namespace)
+		}
+
+		reached, relationErrors, err := validateRelationArrowTargets(ctx, ts, found)
+		if err != nil {
+			return nil, err
+		}
+
+		arrowErrors = append(arrowErrors, relationErrors...)
+		toWalk = append(toWalk, reached...)
+	}
+
+	sort.Slice(arrowErrors, func(i, j int) bool {
+		first, second := arrowErrors[i], arrowErrors[j]
+		if first.Relation.Namespace != second.Relation.Namespace || first.Relation.Relation != second.Relation.Relation {
+			return tuple.StringRR(first.Relation) < tuple.StringRR(second.Relation)
+		}
+		if first.TuplesetRelation.Relation != second.TuplesetRelation.Relation {
+			return first.TuplesetRelation.Relation < second.TuplesetRelation.Relation
+		}
+		return first.TargetNamespace < second.TargetNamespace
+	})
+	return arrowErrors, nil
+}
+
+// validateRelationArrowTargets returns the relations reached from the given relation and the
+// ArrowErrors for the arrows found in its rewrite.
+func validateRelationArrowTargets(ctx context.Context, ts *TypeSystem, relation *core.Relation) ([]*core.RelationReference, []ArrowError, error) {
+	rr := &core.RelationReference{
+		Namespace: ts.nsDef.Name,
+		Relation:  relation.Name,
+	}
+
+	reached := []*core.RelationReference{}
+	if ts.HasTypeInformation(relation.Name) {
+		allowedRelations, err := ts.AllowedSubjectRelations(relation.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, allowedRelation := range allowedRelations {
+			if allowedRelation.Relation != tuple.Ellipsis {
+				reached = append(reached, allowedRelation)
+			}
+		}
+	}
+
+	arrowErrors := []ArrowError{}
+	result := graph.WalkRewrite(relation.GetUsersetRewrite(), func(childOneof *core.SetOperation_Child) interface{} {
+		switch child := childOneof.ChildType.(type) {
+		case *core.SetOperation_Child_ComputedUserset:
+			reached = append(reached, &core.RelationReference{
+				Namespace: ts.nsDef.Name,
+				Relation:  child.ComputedUserset.Relation,
+			})
+
+		case *core.SetOperation_Child_TupleToUserset:
+			arrowError := ArrowError{
+				Relation: rr,
+				TuplesetRelation: &core.RelationReference{
+					Namespace: ts.nsDef.Name,
+					Relation:  child.TupleToUserset.Tupleset.Relation,
+				},
+				ComputedUsersetRelation: child.TupleToUserset.ComputedUserset.Relation,
+			}
+
+			if !ts.HasRelation(arrowError.TuplesetRelation.Relation) {
+				arrowErrors = append(arrowErrors, arrowError)
+				return nil
+			}
+
+			allowedRelations, err := ts.AllowedDirectRelationsAndWildcards(arrowError.TuplesetRelation.Relation)
+			if err != nil {
+				return err
+			}
+
+			targetNamespaces := map[string]struct{}{}
+			for _, allowedRelation := range allowedRelations {
+				targetNamespaces[allowedRelation.Namespace] = struct{}{}
+			}
+
+			for namespaceName := range targetNamespaces {
+				targetTypeSystem, err := ts.typeSystemForNamespace(ctx, namespaceName)
+				if err != nil {
+					return wrapReferencedNamespaceNotFound(err, rr)
+				}
+
+				if !targetTypeSystem.HasRelation(arrowError.ComputedUsersetRelation) {
+					targetError := arrowError
+					targetError.TargetNamespace = namespaceName
+					arrowErrors = append(arrowErrors, targetError)
+					continue
+				}
+
+				reached = append(reached, &core.RelationReference{
+					Namespace: namespaceName,
+					Relation:  arrowError.ComputedUsersetRelation,
+				})
+			}
+		}
+		return nil
+	})
+	if result != nil {
+		return nil, nil, result.(error)
+	}
+
+	return reached, arrowErrors, nil
+}
@@ -0,0 +1,100 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+)
+
+func TestReachabilityGraphAmbiguousArrowEntrypoints(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition folder {
+		relation viewer: user
+	}
+
+	definition organization {
+		relation viewer: user
+		relation admin: user
+	}
+
+	definition document {
+		relation parent: folder | organization
+		relation org: organization
+		permission view = parent->viewer
+		permission admin = org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	ambiguous, err := rg.AmbiguousArrowEntrypoints(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Len(ambiguous, 1)
+	require.Equal("document#view", relationRefKey(ambiguous[0].Entrypoint.ContainingRelationOrPermission()))
+	require.Equal("document#parent", relationRefKey(ambiguous[0].TuplesetRelation))
+	require.Equal([]string{"folder", "organization"}, ambiguous[0].TargetNamespaces)
+
+	ambiguous, err = rg.AmbiguousArrowEntrypoints(ctx, rr("user", "..."), rr("document", "admin"))
+	require.NoError(err)
+	require.Empty(ambiguous)
+}
+
+func TestReachabilityGraphValidateArrowTargets(t *testing.T) {
+	require := require.New(t)
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: `definition user {}
+
+		definition folder {
+			relation viewer: user
+		}
+
+		definition organization {
+			relation admin: user
+			permission manage = missing->admin
+		}
+
+		definition team {
+			relation member: user
+		}
+
+		definition document {
+			relation parent: folder | organization
+			relation org: organization
+			relation owner: team#member
+			permission view = parent->viewer + org->manage
+			permission edit = owner + org->admin
+		}`},
+	}, &empty)
+	require.NoError(err)
+
+	rg, err := ReachabilityGraphFromDefinitions(defs, "document")
+	require.NoError(err)
+
+	arrowErrors, err := rg.ValidateArrowTargets(context.Background(), rr("document", "view"))
+	require.NoError(err)
+	require.Len(arrowErrors, 2)
+
+	require.Equal("document#view", relationRefKey(arrowErrors[0].Relation))
+	require.Equal("document#parent", relationRefKey(arrowErrors[0].TuplesetRelation))
+	require.Equal("viewer", arrowErrors[0].ComputedUsersetRelation)
+	require.Equal("organization", arrowErrors[0].TargetNamespace)
+
+	require.Equal("organization#manage", relationRefKey(arrowErrors[1].Relation))
+	require.Equal("organization#missing", relationRefKey(arrowErrors[1].TuplesetRelation))
+	require.Empty(arrowErrors[1].TargetNamespace)
+
+	arrowErrors, err = rg.ValidateArrowTargets(context.Background(), rr("document", "edit"))
+	require.NoError(err)
+	require.Empty(arrowErrors)
+
+	_, err = rg.ValidateArrowTargets(context.Background(), rr("document", "unknown"))
+	require.Error(err)
+}
@@ -0,0 +1,66 @@
+package namespace
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/ristretto"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// ReachabilityGraphCache is a cache of computed reachability graphs, keyed by the namespace and
+// relation for which the graph was computed, as well as the datastore revision at which the
+// namespace definitions were read. A single cache can be shared amongst many ReachabilityGraphs.
+type ReachabilityGraphCache struct {
+	c *ristretto.Cache
+}
+
+// NewReachabilityGraphCache creates a new cache for computed reachability graphs. If the given
+// config is nil, a default configuration is used.
+func NewReachabilityGraphCache(cacheConfig *ristretto.Config) (*ReachabilityGraphCache, error) {
+	if cacheConfig == nil {
+		cacheConfig = &ristretto.Config{
+			NumCounters: 1e4,     // number of keys to track frequency of (10k).
+			MaxCost:     1 << 22, // maximum cost of cache (4MB).
+			BufferItems: 64,      // number of keys per Get buffer.
+		}
+	}
+
+	cache, err := ristretto.NewCache(cacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create reachability graph cache: %w", err)
+	}
+
+	return &ReachabilityGraphCache{cache}, nil
+}
+
+// Close closes the cache, freeing its resources.
+func (rgc *ReachabilityGraphCache) Close() {
+	rgc.c.Close()
+}
+
+func (rgc *ReachabilityGraphCache) get(resourceType *core.RelationReference, option reachabilityOption, revision datastore.Revision) (*core.ReachabilityGraph, bool) {
+	found, ok := rgc.c.Get(reachabilityCacheKey(resourceType, option, revision))
+	if !ok {
+		return nil, false
+	}
+
+	return found.(*core.ReachabilityGraph), true
+}
+
+func (rgc *ReachabilityGraphCache) set(resourceType *core.RelationReference, option reachabilityOption, revision datastore.Revision, graph *core.ReachabilityGraph) {
+	// NOTE: sets are buffered, so the graph may not be available to a subsequent get right away.
+	// A graph not yet available is simply computed again.
+	rgc.c.Set(reachabilityCacheKey(resourceType, option, revision), graph, int64(proto.Size(graph)))
+}
+
+// reachabilityCacheKey returns the key for the graph of the given relation. Since namespace
+// definitions are immutable at a given datastore revision, including the revision ensures that
+// a change to the definition will never return a stale graph. The revision must therefore never
+// be datastore.NoRevision, which would share graphs across all revisions. The option is included as well,
+// since the full and optimized graphs for a relation differ.
+func reachabilityCacheKey(resourceType *core.RelationReference, option reachabilityOption, revision datastore.Revision) string {
+	return fmt.Sprintf("%s:%d@%s", relationKey(resourceType.Namespace, resourceType.Relation), option, revision)
+}
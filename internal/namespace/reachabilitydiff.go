@@ -0,0 +1,66 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// DiffReachability computes the entrypoints from the given subject type to the given resource type
+// under both the old and the new type systems, and returns the entrypoints found only under the
+// new type system as added and those found only under the old type system as removed.
+//
+// Entrypoints are compared by their HashKey, so a change to a rewrite which leaves the set of
+// entrypoints unchanged results in an empty diff. Both sets of entrypoints are returned sorted.
+func DiffReachability(
+	ctx context.Context,
+	oldTS *ValidatedNamespaceTypeSystem,
+	newTS *ValidatedNamespaceTypeSystem,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (added []ReachabilityEntrypoint, removed []ReachabilityEntrypoint, err error) {
+	oldEntrypoints, err := allEntrypointsForSubjectToResource(ctx, oldTS, subjectType, resourceType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to compute reachability under old type system: %w", err)
+	}
+
+	newEntrypoints, err := allEntrypointsForSubjectToResource(ctx, newTS, subjectType, resourceType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to compute reachability under new type system: %w", err)
+	}
+
+	return entrypointsMissingFrom(newEntrypoints, oldEntrypoints), entrypointsMissingFrom(oldEntrypoints, newEntrypoints), nil
+}
+
+func allEntrypointsForSubjectToResource(
+	ctx context.Context,
+	ts *ValidatedNamespaceTypeSystem,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]ReachabilityEntrypoint, error) {
+	rg, err := ReachabilityGraphFor(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return rg.AllEntrypointsForSubjectToResource(ctx, subjectType, resourceType)
+}
+
+// entrypointsMissingFrom returns the entrypoints which are not found in the other entrypoints,
+// retaining their order.
+func entrypointsMissingFrom(entrypoints []ReachabilityEntrypoint, other []ReachabilityEntrypoint) []ReachabilityEntrypoint {
+	otherKeys := make(map[string]struct{}, len(other))
+	for _, entrypoint := range other {
+		otherKeys[entrypoint.HashKey()] = struct{}{}
+	}
+
+	missing := []ReachabilityEntrypoint{}
+	for _, entrypoint := range entrypoints {
+		if _, ok := otherKeys[entrypoint.HashKey()]; !ok {
+			missing = append(missing, entrypoint)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,91 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffReachability(t *testing.T) {
+	oldSchema := `definition user {}
+	definition bot {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		relation banned: bot
+		permission view = viewer + org->admin
+	}`
+
+	testCases := []struct {
+		name            string
+		newSchema       string
+		expectedAdded   []string
+		expectedRemoved []string
+	}{
+		{
+			"unchanged",
+			oldSchema,
+			[]string{},
+			[]string{},
+		},
+		{
+			"rewrite changed with same entrypoints",
+			`definition user {}
+			definition bot {}
+
+			definition organization {
+				relation admin: user
+			}
+
+			definition document {
+				relation org: organization
+				relation viewer: user
+				relation editor: user
+				relation banned: bot
+				permission view = (viewer + org->admin) - banned
+			}`,
+			[]string{},
+			[]string{},
+		},
+		{
+			"relation added and removed",
+			`definition user {}
+			definition bot {}
+
+			definition organization {
+				relation admin: user
+			}
+
+			definition document {
+				relation org: organization
+				relation viewer: user
+				relation editor: user
+				relation banned: bot
+				permission view = viewer + editor
+			}`,
+			[]string{"RELATION_ENTRYPOINT document#editor[]"},
+			[]string{"RELATION_ENTRYPOINT organization#admin[]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			oldTS, _ := buildReachabilityTypeSystem(t, oldSchema, "document")
+			newTS, ctx := buildReachabilityTypeSystem(t, tc.newSchema, "document")
+
+			added, removed, err := DiffReachability(ctx, oldTS, newTS, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Equal(tc.expectedAdded, entrypointStrings(added))
+			require.Equal(tc.expectedRemoved, entrypointStrings(removed))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// RenderDOT renders the relations walked from the given subject type to the given resource type
+// as a Graphviz DOT digraph, which can be rendered with e.g. `dot -Tsvg`. Each relation found on
+// an entrypoint path is a node, and each hop of a path is an edge labeled with the kind of the
+// entrypoint, drawn dotted if the hop is not a direct result. A hop which revisits a relation
+// already on the path is drawn once as a dashed back-edge. A relation at which a path stops
+// without being walked, per WithMaxDepth or WithNamespaceAllowlist, is drawn as a dashed node.
+//
+// The walk honors the same options as EntrypointPaths. The output is stable for a given schema.
+func (rg *ReachabilityGraph) RenderDOT(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (string, error) {
+	paths, err := rg.EntrypointPaths(ctx, subjectType, resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	subjectNode := tuple.StringRR(subjectType)
+	nodes := map[string]struct{}{
+		tuple.StringRR(resourceType): {},
+	}
+	edges := map[string]struct{}{}
+	boundaryNodes := map[string]struct{}{}
+
+	for _, path := range paths {
+		from := subjectNode
+		switch {
+		case path.Truncated:
+			from = tuple.StringRR(path.Revisited)
+		case path.Boundary != nil:
+			from = tuple.StringRR(path.Boundary)
+			boundaryNodes[from] = struct{}{}
+		default:
+			nodes[subjectNode] = struct{}{}
+		}
+
+		for index, hop := range path.Hops {
+			to := tuple.StringRR(hop.Relation)
+			nodes[to] = struct{}{}
+			edges[dotEdge(from, to, hop, index == 0 && path.Truncated)] = struct{}{}
+			from = to
+		}
+	}
+
+	// A relation walked on another path is not drawn as a boundary.
+	for node := range boundaryNodes {
+		if _, ok := nodes[node]; ok {
+			delete(boundaryNodes, node)
+			continue
+		}
+		nodes[node] = struct{}{}
+	}
+
+	sortedNodes := make([]string, 0, len(nodes))
+	for node := range nodes {
+		sortedNodes = append(sortedNodes, node)
+	}
+	sort.Strings(sortedNodes)
+
+	sortedEdges := make([]string, 0, len(edges))
+	for edge := range edges {
+		sortedEdges = append(sortedEdges, edge)
+	}
+	sort.Strings(sortedEdges)
+
+	var sb strings.Builder
+	sb.WriteString("digraph reachability {\n")
+	for _, node := range sortedNodes {
+		if node == subjectNode {
+			fmt.Fprintf(&sb, "\t%s [shape=box];\n", strconv.Quote(node))
+			continue
+		}
+		if _, ok := boundaryNodes[node]; ok {
+			fmt.Fprintf(&sb, "\t%s [style=dashed];\n", strconv.Quote(node))
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%s;\n", strconv.Quote(node))
+	}
+	for _, edge := range sortedEdges {
+		fmt.Fprintf(&sb, "\t%s;\n", edge)
+	}
+	sb.WriteString("}\n")
+	return sb.String(), nil
+}
+
+// dotEdge renders the edge for the given hop, from the relation or subject at which it starts.
+func dotEdge(from string, to string, hop ReachabilityHop, isBackEdge bool) string {
+	attributes := []string{fmt.Sprintf("label=%s", strconv.Quote(EntrypointKind(hop.Kind).String()))}
+	switch {
+	case isBackEdge:
+		attributes = append(attributes, "style=dashed", "constraint=false")
+	case !hop.IsDirectResult:
+		attributes = append(attributes, "style=dotted")
+	}
+
+	return fmt.Sprintf("%s -> %s [%s]", strconv.Quote(from), strconv.Quote(to), strings.Join(attributes, ", "))
+}
@@ -0,0 +1,76 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReachabilityGraphRenderDOT(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation banned: user
+		permission view = viewer - banned
+	}`, "document")
+
+	rendered, err := reachabilityGraphForTest(t, rts).RenderDOT(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Equal(`digraph reachability {
+	"document#banned";
+	"document#view";
+	"document#viewer";
+	"group#member";
+	"user#..." [shape=box];
+	"document#banned" -> "document#view" [label="subject-relation", style=dotted];
+	"document#viewer" -> "document#view" [label="subject-relation", style=dotted];
+	"group#member" -> "document#viewer" [label="relation"];
+	"group#member" -> "group#member" [label="relation", style=dashed, constraint=false];
+	"user#..." -> "document#banned" [label="relation"];
+	"user#..." -> "document#viewer" [label="relation"];
+	"user#..." -> "group#member" [label="relation"];
+}
+`, rendered)
+}
+
+func TestReachabilityGraphRenderDOTWithNamespaceAllowlist(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	rendered, err := reachabilityGraphForTest(t, rts, WithNamespaceAllowlist("document")).RenderDOT(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Equal(`digraph reachability {
+	"document#view";
+	"document#viewer";
+	"group#member" [style=dashed];
+	"organization#admin" [style=dashed];
+	"user#..." [shape=box];
+	"document#viewer" -> "document#view" [label="subject-relation"];
+	"group#member" -> "document#viewer" [label="relation"];
+	"organization#admin" -> "document#view" [label="arrow"];
+	"user#..." -> "document#viewer" [label="relation"];
+}
+`, rendered)
+}
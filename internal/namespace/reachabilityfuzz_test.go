@@ -0,0 +1,63 @@
+package namespace_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func FuzzReachability(f *testing.F) {
+	for seed := int64(0); seed < 32; seed++ {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		defs := testfixtures.RandomNamespaceDefinitions(rand.New(rand.NewSource(seed)))
+		for _, def := range defs {
+			rg, err := namespace.ReachabilityGraphFromDefinitions(defs, def.Name)
+			if err != nil {
+				// Definitions which fail validation are not walked.
+				continue
+			}
+
+			for _, relation := range def.Relation {
+				resourceType := ns.RelationReference(def.Name, relation.Name)
+				for _, subjectDef := range defs {
+					subjectType := ns.RelationReference(subjectDef.Name, tuple.Ellipsis)
+					entrypoints, err := rg.AllEntrypointsForSubjectToResource(context.Background(), subjectType, resourceType)
+					require.NoError(t, err)
+
+					for _, entrypoint := range entrypoints {
+						containingRelation := entrypoint.ContainingRelationOrPermission()
+						for _, containingDef := range defs {
+							if containingDef.Name != containingRelation.Namespace {
+								continue
+							}
+
+							_, err := entrypoint.ContainingExpression(containingDef)
+							require.NoError(t, err)
+
+							if entrypoint.Kind() == namespace.ArrowEntrypointKind {
+								_, err = entrypoint.TupleToUsersetE(containingDef)
+								require.NoError(t, err)
+							}
+						}
+					}
+
+					_, err = rg.OptimizedEntrypointsForSubjectToResource(context.Background(), subjectType, resourceType)
+					require.NoError(t, err)
+				}
+
+				_, err := rg.ValidateArrowTargets(context.Background(), resourceType)
+				require.NoError(t, err)
+			}
+		}
+	})
+}
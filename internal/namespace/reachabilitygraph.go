@@ -2,18 +2,216 @@ package namespace
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/authzed/spicedb/pkg/datastore"
 	"github.com/authzed/spicedb/pkg/graph"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
 
+var tracer = otel.Tracer("spicedb/internal/namespace")
+
+var (
+	// resourceNamespaceKey is a tracing attribute representing the namespace of the resource
+	// type being walked.
+	resourceNamespaceKey = attribute.Key("authzed.com/spicedb/reachability/resourceNamespace")
+
+	// resourceRelationKey is a tracing attribute representing the relation of the resource type
+	// being walked.
+	resourceRelationKey = attribute.Key("authzed.com/spicedb/reachability/resourceRelation")
+
+	// subjectTypeKey is a tracing attribute representing the subject type from which a walk
+	// starts.
+	subjectTypeKey = attribute.Key("authzed.com/spicedb/reachability/subjectType")
+
+	// entrypointCountKey is a tracing attribute representing the number of entrypoints found.
+	entrypointCountKey = attribute.Key("authzed.com/spicedb/reachability/entrypointCount")
+
+	// namespaceNameKey is a tracing attribute representing the name of a namespace loaded.
+	namespaceNameKey = attribute.Key("authzed.com/spicedb/reachability/namespaceName")
+)
+
 // ReachabilityGraph is a helper struct that provides an easy way to determine all entrypoints
 // for a subject of a particular type into a schema, for the purpose of walking from the subject
 // to a specific resource relation.
 type ReachabilityGraph struct {
 	ts *TypeSystem
+
+	// rootTypeSystem is a copy of ts with all of its relations decorated with operation paths,
+	// and is used to walk its relations without reloading the namespace. If nil, the namespace is
+	// reloaded like any other.
+	rootTypeSystem *TypeSystem
+
+	// walkedNamespaces holds the type system of each other namespace walked into, which is loaded
+	// and decorated with operation paths once for the lifetime of the graph.
+	walkedMu         sync.Mutex
+	walkedNamespaces map[string]*walkedNamespace
+
+	cache    *ReachabilityGraphCache
+	revision datastore.Revision
+
+	maxConcurrency uint16
+
+	// maxDepth is the maximum depth to which walks recurse, or unlimitedDepth.
+	maxDepth int
+
+	// deduplicate is true if entrypoints found more than once in a walk are only returned once.
+	deduplicate bool
+
+	// namespaceAllowlist holds the namespaces to which walks are confined, or nil if walks
+	// are not confined.
+	namespaceAllowlist map[string]struct{}
+
+	// excludedRelations holds the keys of the relations pruned from walks, if any.
+	excludedRelations map[string]struct{}
+
+	// containingKind filters the entrypoints returned by the kind of their containing relation or
+	// permission.
+	containingKind containingKindFilter
+
+	// directResultsOnly is true if entrypoints which are not direct results are pruned from the
+	// entrypoints collected.
+	directResultsOnly bool
+}
+
+// containingKindFilter filters entrypoints by the kind of their containing relation or permission.
+type containingKindFilter int
+
+const (
+	// anyContainingKind returns all entrypoints.
+	anyContainingKind containingKindFilter = iota
+
+	// relationsOnly returns the entrypoints contained by relations.
+	relationsOnly
+
+	// permissionsOnly returns the entrypoints contained by permissions.
+	permissionsOnly
+)
+
+// ReachabilityGraphOption is an option for configuring a ReachabilityGraph.
+type ReachabilityGraphOption func(rg *ReachabilityGraph)
+
+// WithMaxConcurrency sets the maximum number of subject relation branches that will be walked
+// concurrently when collecting entrypoints. Note that when collecting concurrently, the order
+// of the returned entrypoints may differ between calls.
+//
+// Defaults to 1, which walks all branches sequentially.
+func WithMaxConcurrency(maxConcurrency uint16) ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.maxConcurrency = maxConcurrency
+	}
+}
+
+// WithDeduplication sets whether an entrypoint found more than once in a walk, e.g. by an arrow
+// whose tupleset relation allows several subject types of the same namespace, is only returned
+// once. Entrypoints are deduplicated by their HashKey. Disabling deduplication returns every
+// entrypoint as found, which can be useful to debug the multiplicity of entrypoints.
+//
+// Defaults to true.
+func WithDeduplication(enabled bool) ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.deduplicate = enabled
+	}
+}
+
+// unlimitedDepth indicates that walks are not limited in depth.
+const unlimitedDepth = -1
+
+// WithMaxDepth limits walks collecting entrypoints to relations at most the given number of
+// subject relations away from the resource type at which the walk starts, with a depth of 0
+// walking only the resource type itself. Instead of walking into a relation beyond the maximum
+// depth, a boundary entrypoint is returned for each entrypoint by which that relation reaches a
+// relation at the maximum depth; see IsBoundary. A relation found at several depths is walked
+// from the shallowest, so no boundary entrypoint is returned for a relation which was walked.
+//
+// By default, walks are not limited in depth.
+func WithMaxDepth(maxDepth uint16) ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.maxDepth = int(maxDepth)
+	}
+}
+
+// WithNamespaceAllowlist confines walks collecting entrypoints to the relations found in the given
+// namespaces. Instead of walking into a relation outside of the allowlist, a boundary entrypoint
+// is returned for each entrypoint by which that relation reaches the walked relation; see
+// IsBoundary. The namespace of the resource type at which a walk starts is always walked.
+//
+// By default, walks are not confined.
+func WithNamespaceAllowlist(namespaceNames ...string) ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.namespaceAllowlist = make(map[string]struct{}, len(namespaceNames))
+		for _, namespaceName := range namespaceNames {
+			rg.namespaceAllowlist[namespaceName] = struct{}{}
+		}
+	}
+}
+
+// WithExcludedRelations prunes the given relations and permissions from walks collecting
+// entrypoints, as if they did not exist in the schema: an excluded relation is never walked, so
+// neither its entrypoints nor those of the relations only reachable through it are returned. No
+// boundary entrypoint is returned for an excluded relation. This can be used to preview the effect
+// of removing a relation or permission before editing the schema.
+//
+// Excluded relations are likewise never walked by HasAnyEntrypoint, SubjectTypesReaching,
+// ReachableRelations and ReverseEntrypoints.
+//
+// By default, no relations are excluded.
+func WithExcludedRelations(refs ...*core.RelationReference) ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.excludedRelations = make(map[string]struct{}, len(refs))
+		for _, ref := range refs {
+			rg.excludedRelations[relationKey(ref.Namespace, ref.Relation)] = struct{}{}
+		}
+	}
+}
+
+// WithRelationsOnly filters the entrypoints returned by walks to those whose containing relation
+// or permission is a relation, onto which relationships can be written. These are the entrypoints
+// for the relationships which, if written, would affect the resource type. The relations reached
+// via permissions are still walked; only the returned entrypoints are filtered.
+//
+// WithRelationsOnly and WithPermissionsOnly are exclusive, and the last given applies. By default,
+// entrypoints are not filtered.
+func WithRelationsOnly() ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.containingKind = relationsOnly
+	}
+}
+
+// WithPermissionsOnly filters the entrypoints returned by walks to those whose containing relation
+// or permission is a permission. The relations reached via relations are still walked; only the
+// returned entrypoints are filtered.
+//
+// WithRelationsOnly and WithPermissionsOnly are exclusive, and the last given applies. By default,
+// entrypoints are not filtered.
+func WithPermissionsOnly() ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.containingKind = permissionsOnly
+	}
+}
+
+// WithDirectResultsOnly filters the entrypoints returned by walks to the direct results; see
+// IsDirectResult. The entrypoints which are conditional, being under an intersection or exclusion,
+// are dropped as they are found rather than collected, which saves allocating them in schemas where
+// most entrypoints are conditional. The relations reached via conditional entrypoints are still
+// walked; only the returned entrypoints are filtered.
+//
+// By default, entrypoints are not filtered.
+func WithDirectResultsOnly() ReachabilityGraphOption {
+	return func(rg *ReachabilityGraph) {
+		rg.directResultsOnly = true
+	}
 }
 
 // ReachabilityEntrypoint is an entrypoint into the reachability graph for a subject of particular
@@ -21,6 +219,33 @@ type ReachabilityGraph struct {
 type ReachabilityEntrypoint struct {
 	re             *core.ReachabilityEntrypoint
 	parentRelation *core.RelationReference
+
+	// boundaryRelation is the relation outside of the namespace allowlist from which this
+	// entrypoint is reached, if a boundary entrypoint.
+	boundaryRelation *core.RelationReference
+
+	// wildcard is true if the entrypoint is reached by a public wildcard of the subject type.
+	wildcard bool
+}
+
+// IsWildcard returns whether the entrypoint is reached by a public wildcard of the subject type
+// (e.g. `user:*`), rather than by the subject type itself.
+func (re ReachabilityEntrypoint) IsWildcard() bool {
+	return re.wildcard
+}
+
+// IsBoundary returns whether the entrypoint marks the boundary of a walk confined by
+// WithNamespaceAllowlist or WithMaxDepth. A boundary entrypoint is not reached by the subject
+// directly, but by the BoundaryRelation, which was not walked as its namespace is outside of the
+// allowlist or as it is beyond the maximum depth.
+func (re ReachabilityEntrypoint) IsBoundary() bool {
+	return re.boundaryRelation != nil
+}
+
+// BoundaryRelation is the relation, outside of the namespace allowlist or beyond the maximum
+// depth, from which this entrypoint is reached, or nil if not a boundary entrypoint.
+func (re ReachabilityEntrypoint) BoundaryRelation() *core.RelationReference {
+	return re.boundaryRelation
 }
 
 // EntrypointKind is the kind of the entrypoint.
@@ -28,32 +253,197 @@ func (re ReachabilityEntrypoint) EntrypointKind() core.ReachabilityEntrypoint_Re
 	return re.re.Kind
 }
 
+// Kind is the kind of the entrypoint, as an EntrypointKind.
+func (re ReachabilityEntrypoint) Kind() EntrypointKind {
+	return EntrypointKind(re.re.Kind)
+}
+
 // TupleToUserset returns the TTU associated with this entrypoint, if a TUPLESET_TO_USERSET_ENTRYPOINT.
+//
+// Panics if the entrypoint is of another kind, or if the namespace definition is not that of the
+// containing relation; see TupleToUsersetE for a version returning an error instead.
 func (re ReachabilityEntrypoint) TupleToUserset(nsDef *core.NamespaceDefinition) *core.TupleToUserset {
+	ttu, err := re.TupleToUsersetE(nsDef)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return ttu
+}
+
+// TupleToUsersetE returns the TTU associated with this entrypoint, if a TUPLESET_TO_USERSET_ENTRYPOINT,
+// or an error if the entrypoint is of another kind or if the namespace definition is not that of
+// the containing relation.
+func (re ReachabilityEntrypoint) TupleToUsersetE(nsDef *core.NamespaceDefinition) (*core.TupleToUserset, error) {
 	if re.EntrypointKind() != core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT {
-		panic(fmt.Sprintf("cannot call TupleToUserset for kind %v", re.EntrypointKind()))
+		return nil, fmt.Errorf("cannot call TupleToUserset for kind %v", re.EntrypointKind())
 	}
 
 	if nsDef.Name != re.parentRelation.Namespace {
-		panic("invalid namespace definition given to TupleToUserset")
+		return nil, fmt.Errorf("invalid namespace definition given to TupleToUserset")
 	}
 
 	for _, relation := range nsDef.Relation {
 		if relation.Name == re.parentRelation.Relation {
-			return graph.FindOperation[core.TupleToUserset](relation.GetUsersetRewrite(), re.re.OperationPath)
+			return graph.FindOperation[core.TupleToUserset](relation.GetUsersetRewrite(), re.re.OperationPath), nil
 		}
 	}
 
-	return nil
+	return nil, nil
+}
+
+// TuplesetRelation returns the name of the tupleset relation on the left side of the arrow, if a
+// TUPLESET_TO_USERSET_ENTRYPOINT, without requiring the namespace definition. Returns false for
+// entrypoints of other kinds, and for arrow entrypoints of graphs computed before the tupleset
+// relation was recorded, for which TupleToUsersetE must be used instead.
+func (re ReachabilityEntrypoint) TuplesetRelation() (string, bool) {
+	if re.EntrypointKind() != core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT {
+		return "", false
+	}
+
+	if re.re.TuplesetRelation == "" {
+		return "", false
+	}
+
+	return re.re.TuplesetRelation, true
+}
+
+// ContainingExpression returns the child of the set operation found at the operation path of this
+// entrypoint, in the userset rewrite of the containing relation or permission: the part of its
+// definition which introduces the entrypoint, whatever its kind. For example, the child is the
+// TupleToUserset of an arrow entrypoint, or the ComputedUserset of a subject relation entrypoint.
+//
+// Returns nil if the containing relation has no userset rewrite, as for an entrypoint found
+// directly on a relation. Returns an error if the namespace definition is not that of the
+// containing relation, or if the operation path is not found in its userset rewrite.
+func (re ReachabilityEntrypoint) ContainingExpression(nsDef *core.NamespaceDefinition) (*core.SetOperation_Child, error) {
+	if nsDef.Name != re.parentRelation.Namespace {
+		return nil, fmt.Errorf("invalid namespace definition given to ContainingExpression")
+	}
+
+	for _, relation := range nsDef.Relation {
+		if relation.Name == re.parentRelation.Relation {
+			child, _, err := walkOperationPath(relation.GetUsersetRewrite(), re.re.OperationPath)
+			return child, err
+		}
+	}
+
+	return nil, nil
+}
+
+// walkOperationPath walks the given operation path in the rewrite, returning the set operation
+// child found at its end, or nil if the operation path is empty, along with the types of the set
+// operations it passes through, ordered from the root of the rewrite.
+func walkOperationPath(rewrite *core.UsersetRewrite, opPath []uint32) (*core.SetOperation_Child, []SetOperationType, error) {
+	var child *core.SetOperation_Child
+	operationTypes := make([]SetOperationType, 0, len(opPath))
+	for _, index := range opPath {
+		var so *core.SetOperation
+		switch rw := rewrite.GetRewriteOperation().(type) {
+		case *core.UsersetRewrite_Union:
+			operationTypes = append(operationTypes, SetOperationUnion)
+			so = rw.Union
+		case *core.UsersetRewrite_Intersection:
+			operationTypes = append(operationTypes, SetOperationIntersection)
+			so = rw.Intersection
+		case *core.UsersetRewrite_Exclusion:
+			operationTypes = append(operationTypes, SetOperationExclusion)
+			so = rw.Exclusion
+		default:
+			return nil, nil, fmt.Errorf("operation path [%s] not found in userset rewrite", formatOperationPath(opPath))
+		}
+
+		if index >= uint32(len(so.Child)) {
+			return nil, nil, fmt.Errorf("operation path [%s] not found in userset rewrite", formatOperationPath(opPath))
+		}
+
+		child = so.Child[index]
+		rewrite = child.GetUsersetRewrite()
+	}
+
+	return child, operationTypes, nil
+}
+
+// ContainingOperationTypes returns the types of the set operations under which this entrypoint
+// is found, in the userset rewrite of the containing relation or permission. The types are ordered
+// from the root of the rewrite to the operation directly containing the entrypoint, with one type
+// for each element of the OperationPath.
+//
+// Returns an error if the namespace definition is not that of the containing relation, if the
+// containing relation is not found in it, or if the operation path is not found in its userset
+// rewrite.
+func (re ReachabilityEntrypoint) ContainingOperationTypes(nsDef *core.NamespaceDefinition) ([]SetOperationType, error) {
+	if nsDef.Name != re.parentRelation.Namespace {
+		return nil, fmt.Errorf("invalid namespace definition given to ContainingOperationTypes")
+	}
+
+	for _, relation := range nsDef.Relation {
+		if relation.Name == re.parentRelation.Relation {
+			_, operationTypes, err := walkOperationPath(relation.GetUsersetRewrite(), re.re.OperationPath)
+			return operationTypes, err
+		}
+	}
+
+	return nil, fmt.Errorf("relation `%s` not found under namespace `%s`", re.parentRelation.Relation, nsDef.Name)
 }
 
 // DirectRelation is the relation that this entrypoint represents, if a RELATION_ENTRYPOINT.
+//
+// Panics if the entrypoint is of another kind; see DirectRelationE for a version returning an
+// error instead.
 func (re ReachabilityEntrypoint) DirectRelation() *core.RelationReference {
+	relation, err := re.DirectRelationE()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return relation
+}
+
+// DirectRelationE is the relation that this entrypoint represents, if a RELATION_ENTRYPOINT, or an
+// error if the entrypoint is of another kind.
+func (re ReachabilityEntrypoint) DirectRelationE() (*core.RelationReference, error) {
 	if re.EntrypointKind() != core.ReachabilityEntrypoint_RELATION_ENTRYPOINT {
-		panic(fmt.Sprintf("cannot call DirectRelation for kind %v", re.EntrypointKind()))
+		return nil, fmt.Errorf("cannot call DirectRelation for kind %v", re.EntrypointKind())
+	}
+
+	return re.re.TargetRelation, nil
+}
+
+// AllowedSubjectTypes returns the subject types allowed on the relation that this entrypoint
+// represents, if a RELATION_ENTRYPOINT, including any public wildcards.
+//
+// Panics if the entrypoint is of another kind, or if the namespace definition is not that of the
+// relation; see AllowedSubjectTypesE for a version returning an error instead.
+func (re ReachabilityEntrypoint) AllowedSubjectTypes(nsDef *core.NamespaceDefinition) []*core.AllowedRelation {
+	allowed, err := re.AllowedSubjectTypesE(nsDef)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return allowed
+}
+
+// AllowedSubjectTypesE returns the subject types allowed on the relation that this entrypoint
+// represents, if a RELATION_ENTRYPOINT, including any public wildcards, or an error if the
+// entrypoint is of another kind or if the namespace definition is not that of the relation.
+func (re ReachabilityEntrypoint) AllowedSubjectTypesE(nsDef *core.NamespaceDefinition) ([]*core.AllowedRelation, error) {
+	relation, err := re.DirectRelationE()
+	if err != nil {
+		return nil, fmt.Errorf("cannot call AllowedSubjectTypes for kind %v", re.EntrypointKind())
+	}
+
+	if nsDef.Name != relation.Namespace {
+		return nil, fmt.Errorf("invalid namespace definition given to AllowedSubjectTypes")
+	}
+
+	for _, found := range nsDef.Relation {
+		if found.Name == relation.Relation {
+			return found.GetTypeInformation().GetAllowedDirectRelations(), nil
+		}
 	}
 
-	return re.re.TargetRelation
+	return nil, nil
 }
 
 // ContainingRelationOrPermission is the relation or permission containing this entrypoint.
@@ -69,13 +459,291 @@ func (re ReachabilityEntrypoint) IsDirectResult() bool {
 	return re.re.ResultStatus == core.ReachabilityEntrypoint_DIRECT_OPERATION_RESULT
 }
 
-// ReachabilityGraphFor returns a reachability graph for the given namespace.
-func ReachabilityGraphFor(ts *ValidatedNamespaceTypeSystem) *ReachabilityGraph {
-	return &ReachabilityGraph{ts.TypeSystem}
+// OperationPath returns the path of the operation containing this entrypoint, within the userset
+// rewrite of the containing relation or permission.
+func (re ReachabilityEntrypoint) OperationPath() []uint32 {
+	return re.re.OperationPath
+}
+
+// String returns a human-readable form of the entrypoint, containing its kind, the containing
+// relation or permission and the operation path of the entrypoint under that relation, e.g.
+// `TUPLESET_TO_USERSET_ENTRYPOINT document#view[0.2]`.
+func (re ReachabilityEntrypoint) String() string {
+	rendered := fmt.Sprintf("%s %s[%s]", re.EntrypointKind(), tuple.StringRR(re.parentRelation), re.operationPathString())
+	if re.IsBoundary() {
+		rendered += fmt.Sprintf(" boundary %s", tuple.StringRR(re.boundaryRelation))
+	}
+	if re.IsWildcard() {
+		rendered += " wildcard"
+	}
+	return rendered
+}
+
+// Equal returns whether the other entrypoint is the same entrypoint: of the same kind, under the
+// same containing relation or permission and operation path, for the same target relation,
+// reached by a wildcard or not and, if a boundary entrypoint, with the same boundary relation.
+func (re ReachabilityEntrypoint) Equal(other ReachabilityEntrypoint) bool {
+	return compareEntrypoints(re, other) == 0
+}
+
+// HashKey returns a key for the entrypoint, which is the same for two entrypoints only if they
+// are Equal. It can be used to collect entrypoints into a set.
+func (re ReachabilityEntrypoint) HashKey() string {
+	boundary := ""
+	if re.IsBoundary() {
+		boundary = tuple.StringRR(re.boundaryRelation)
+	}
+
+	return fmt.Sprintf("%d:%s:%s:%s:%s:%t",
+		re.EntrypointKind(),
+		tuple.StringRR(re.parentRelation),
+		re.operationPathString(),
+		tuple.StringRR(re.re.TargetRelation),
+		boundary,
+		re.IsWildcard(),
+	)
+}
+
+func (re ReachabilityEntrypoint) operationPathString() string {
+	return formatOperationPath(re.re.OperationPath)
+}
+
+// SetOperationType is the type of a set operation found in a userset rewrite.
+type SetOperationType int
+
+const (
+	// SetOperationUnion is a union of its children.
+	SetOperationUnion SetOperationType = iota
+
+	// SetOperationIntersection is an intersection of its children.
+	SetOperationIntersection
+
+	// SetOperationExclusion is its first child, excluding its other children.
+	SetOperationExclusion
+)
+
+func (sot SetOperationType) String() string {
+	switch sot {
+	case SetOperationUnion:
+		return "union"
+	case SetOperationIntersection:
+		return "intersection"
+	case SetOperationExclusion:
+		return "exclusion"
+	default:
+		return fmt.Sprintf("SetOperationType(%d)", int(sot))
+	}
+}
+
+// EntrypointKind is the kind of a reachability entrypoint.
+type EntrypointKind core.ReachabilityEntrypoint_ReachabilityEntrypointKind
+
+const (
+	// RelationEntrypointKind is an entrypoint for a subject found directly on a relation.
+	RelationEntrypointKind = EntrypointKind(core.ReachabilityEntrypoint_RELATION_ENTRYPOINT)
+
+	// SubjectRelationEntrypointKind is an entrypoint for a relation or permission rewritten from
+	// another relation or permission of the same object, via a computed userset.
+	SubjectRelationEntrypointKind = EntrypointKind(core.ReachabilityEntrypoint_COMPUTED_USERSET_ENTRYPOINT)
+
+	// ArrowEntrypointKind is an entrypoint for a relation or permission reached by walking an
+	// arrow (a tupleset to userset) from another object.
+	ArrowEntrypointKind = EntrypointKind(core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT)
+)
+
+func (ek EntrypointKind) String() string {
+	switch ek {
+	case RelationEntrypointKind:
+		return "relation"
+	case SubjectRelationEntrypointKind:
+		return "subject-relation"
+	case ArrowEntrypointKind:
+		return "arrow"
+	default:
+		return fmt.Sprintf("EntrypointKind(%d)", int(ek))
+	}
+}
+
+// In returns whether the kind is one of the given kinds.
+func (ek EntrypointKind) In(kinds ...EntrypointKind) bool {
+	for _, kind := range kinds {
+		if ek == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SortEntrypoints sorts the given entrypoints, in place, into a stable order: by the namespace
+// and then name of the containing relation or permission, then by the entrypoint kind, then by
+// the operation path (compared element-wise, with shorter paths first when one is a prefix of the
+// other), then by the target relation, then by the boundary relation, with non-boundary
+// entrypoints first, and finally with wildcard entrypoints after the others. This order will
+// remain the same between versions.
+//
+// All methods on ReachabilityGraph returning entrypoints return them in this order.
+func SortEntrypoints(entrypoints []ReachabilityEntrypoint) {
+	sort.SliceStable(entrypoints, func(i, j int) bool {
+		return compareEntrypoints(entrypoints[i], entrypoints[j]) < 0
+	})
+}
+
+func compareEntrypoints(first ReachabilityEntrypoint, second ReachabilityEntrypoint) int {
+	if c := strings.Compare(first.parentRelation.Namespace, second.parentRelation.Namespace); c != 0 {
+		return c
+	}
+
+	if c := strings.Compare(first.parentRelation.Relation, second.parentRelation.Relation); c != 0 {
+		return c
+	}
+
+	if first.re.Kind != second.re.Kind {
+		if first.re.Kind < second.re.Kind {
+			return -1
+		}
+		return 1
+	}
+
+	firstPath, secondPath := first.re.OperationPath, second.re.OperationPath
+	for index := 0; index < len(firstPath) && index < len(secondPath); index++ {
+		if firstPath[index] != secondPath[index] {
+			if firstPath[index] < secondPath[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	if len(firstPath) != len(secondPath) {
+		if len(firstPath) < len(secondPath) {
+			return -1
+		}
+		return 1
+	}
+
+	if c := strings.Compare(tuple.StringRR(first.re.TargetRelation), tuple.StringRR(second.re.TargetRelation)); c != 0 {
+		return c
+	}
+
+	if first.IsBoundary() != second.IsBoundary() {
+		if !first.IsBoundary() {
+			return -1
+		}
+		return 1
+	}
+
+	if first.IsWildcard() != second.IsWildcard() {
+		if !first.IsWildcard() {
+			return -1
+		}
+		return 1
+	}
+
+	if !first.IsBoundary() {
+		return 0
+	}
+
+	return strings.Compare(tuple.StringRR(first.boundaryRelation), tuple.StringRR(second.boundaryRelation))
+}
+
+// ReachabilityGraphFor returns a reachability graph for the given namespace. Returns an error if
+// the relations of the namespace cannot be decorated with operation paths.
+func ReachabilityGraphFor(ts *ValidatedNamespaceTypeSystem, options ...ReachabilityGraphOption) (*ReachabilityGraph, error) {
+	return newReachabilityGraph(&ReachabilityGraph{ts: ts.TypeSystem}, options)
+}
+
+// ReachabilityGraphForWithCache returns a reachability graph for the given namespace, which
+// stores and reuses computed graphs in the given cache. The revision must be the datastore
+// revision at which the namespace definitions are being read; returns an error if it is
+// datastore.NoRevision, as the cached graphs could then not be told apart across schema changes.
+func ReachabilityGraphForWithCache(ts *ValidatedNamespaceTypeSystem, cache *ReachabilityGraphCache, revision datastore.Revision, options ...ReachabilityGraphOption) (*ReachabilityGraph, error) {
+	if revision == datastore.NoRevision {
+		return nil, fmt.Errorf("a datastore revision is required to cache reachability graphs")
+	}
+
+	return newReachabilityGraph(&ReachabilityGraph{ts: ts.TypeSystem, cache: cache, revision: revision}, options)
+}
+
+// ReachabilityGraphFromDefinitions returns a reachability graph for the target namespace found
+// in the given definitions, without requiring a datastore. All namespaces referenced by the walks
+// are looked up in the given definitions, and the type system of the target namespace is
+// validated against them.
+func ReachabilityGraphFromDefinitions(defs []*core.NamespaceDefinition, targetNamespace string, options ...ReachabilityGraphOption) (*ReachabilityGraph, error) {
+	var targetDef *core.NamespaceDefinition
+	for _, def := range defs {
+		if def.Name == targetNamespace {
+			targetDef = def
+			break
+		}
+	}
+
+	if targetDef == nil {
+		return nil, NewNamespaceNotFoundErr(targetNamespace, nil)
+	}
+
+	ts, err := BuildNamespaceTypeSystemForDefs(targetDef, defs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The definitions are held in memory, so validation never blocks on a lookup.
+	vts, err := ts.Validate(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate definition `%s` for reachability: %w", targetNamespace, err)
+	}
+
+	return ReachabilityGraphFor(vts, options...)
+}
+
+// ReachabilityGraphAtRevision returns a reachability graph for the target namespace as defined at
+// the given datastore revision. The target namespace, and all namespaces referenced by the walks,
+// are read at that revision, so that the entrypoints are those of the schema at that revision,
+// e.g. to audit authorization decisions made under an earlier schema.
+//
+// Returns ErrNamespaceNotFound if the target namespace did not exist at the revision.
+func ReachabilityGraphAtRevision(ctx context.Context, ds datastore.Datastore, revision datastore.Revision, targetNamespace string, options ...ReachabilityGraphOption) (*ReachabilityGraph, error) {
+	_, ts, err := ReadNamespaceAndTypes(ctx, targetNamespace, ds.SnapshotReader(revision))
+	if err != nil {
+		return nil, asNamespaceNotFound(err, targetNamespace)
+	}
+
+	vts, err := ts.Validate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to validate definition `%s` for reachability at revision %s: %w", targetNamespace, revision, err)
+	}
+
+	return newReachabilityGraph(&ReachabilityGraph{ts: vts.TypeSystem, revision: revision}, options)
+}
+
+func newReachabilityGraph(rg *ReachabilityGraph, options []ReachabilityGraphOption) (*ReachabilityGraph, error) {
+	rg.maxConcurrency = 1
+	rg.maxDepth = unlimitedDepth
+	rg.deduplicate = true
+	for _, option := range options {
+		option(rg)
+	}
+
+	// Decorate the relations of the namespace upfront, so that walks only ever read them.
+	rootTypeSystem, err := buildDecoratedTypeSystem(rg.ts.nsDef, rg.ts.lookupNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	rg.rootTypeSystem = rootTypeSystem
+	return rg, nil
 }
 
 // AllEntrypointsForSubjectToResource returns the entrypoints into the reachability graph, starting
 // at the given subject type and walking to the given resource type.
+//
+// The subject type can either be a type, with the ellipsis relation (e.g. `user#...`), or a
+// subject relation (e.g. `group#member`). For a subject relation, the entrypoints are those by
+// which a subject set of that relation reaches the resource type: the relations on which it is
+// allowed, and the relations and permissions rewritten from it. Wildcard entrypoints are only
+// reached by types, as a wildcard never matches a subject relation.
+//
+// Returns an error if the namespace of the subject type does not exist. If the namespace exists
+// but the subject type cannot reach the resource type, no entrypoints are returned.
 func (rg *ReachabilityGraph) AllEntrypointsForSubjectToResource(
 	ctx context.Context,
 	subjectType *core.RelationReference,
@@ -97,84 +765,1147 @@ func (rg *ReachabilityGraph) OptimizedEntrypointsForSubjectToResource(
 	return rg.entrypointsForSubjectToResource(ctx, subjectType, resourceType, reachabilityOptimized)
 }
 
-func (rg *ReachabilityGraph) entrypointsForSubjectToResource(
+// CanonicalEntrypointsForSubjectToResource returns the full set of entrypoints into the
+// reachability graph, starting at the given subject type and walking to the given resource type,
+// with alias-only permissions (e.g. `permission view = viewer`) resolved down to the relations
+// they alias. Entrypoints are therefore reported against the aliased relation rather than once
+// for the alias and once for the relation.
+//
+// Aliases are only collapsed where they are referenced by another relation or permission. The
+// resource type itself is never resolved: if it is an alias, it is walked as given, and the
+// entrypoint from the relation it aliases still targets the alias.
+func (rg *ReachabilityGraph) CanonicalEntrypointsForSubjectToResource(
 	ctx context.Context,
 	subjectType *core.RelationReference,
 	resourceType *core.RelationReference,
-	reachabilityOption reachabilityOption,
 ) ([]ReachabilityEntrypoint, error) {
-	if resourceType.Namespace != rg.ts.nsDef.Name {
-		return nil, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
-	}
-
-	collected := &[]ReachabilityEntrypoint{}
-	err := rg.collectEntrypoints(ctx, subjectType, resourceType, collected, map[string]struct{}{}, reachabilityOption)
-	return *collected, err
+	return rg.entrypointsForSubjectToResource(ctx, subjectType, resourceType, reachabilityCanonical)
 }
 
-func (rg *ReachabilityGraph) collectEntrypoints(
+// AllEntrypointsForWildcardSubject returns the entrypoints into the reachability graph reached by
+// a public wildcard of the given subject namespace (e.g. `user:*`), walking to the given resource
+// type. These are the entrypoints returned by AllEntrypointsForSubjectToResource for the subject
+// type which are marked as IsWildcard.
+func (rg *ReachabilityGraph) AllEntrypointsForWildcardSubject(
 	ctx context.Context,
-	subjectType *core.RelationReference,
+	subjectNamespace string,
 	resourceType *core.RelationReference,
-	collected *[]ReachabilityEntrypoint,
-	encounteredRelations map[string]struct{},
-	reachabilityOption reachabilityOption,
-) error {
-	// Ensure that we only process each relation once.
-	key := relationKey(resourceType.Namespace, resourceType.Relation)
-	if _, ok := encounteredRelations[key]; ok {
-		return nil
+) ([]ReachabilityEntrypoint, error) {
+	subjectType := &core.RelationReference{
+		Namespace: subjectNamespace,
+		Relation:  tuple.Ellipsis,
 	}
 
-	encounteredRelations[key] = struct{}{}
-
-	// Load the type system for the target resource relation.
-	namespace, err := rg.ts.lookupNamespace(ctx, resourceType.Namespace)
+	found, err := rg.entrypointsForSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rts, err := BuildNamespaceTypeSystem(namespace, rg.ts.lookupNamespace)
-	if err != nil {
-		return err
+	wildcards := make([]ReachabilityEntrypoint, 0, len(found))
+	for _, entrypoint := range found {
+		if entrypoint.IsWildcard() {
+			wildcards = append(wildcards, entrypoint)
+		}
 	}
 
-	// TODO(jschorr): cache the graph somewhere.
-	rrg := ReachabilityGraph{rts}
+	return wildcards, nil
+}
+
+// ForEachEntrypoint walks the reachability graph from the given subject type to the given resource
+// type, invoking the callback with each entrypoint as it is found. Unlike
+// AllEntrypointsForSubjectToResource, the entrypoints are not collected or sorted, and are
+// instead given in the order in which the walk finds them. The callback is never invoked
+// concurrently, even if the graph was created with WithMaxConcurrency.
+//
+// If the callback returns an error, the walk is stopped and the error is returned.
+func (rg *ReachabilityGraph) ForEachEntrypoint(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+	callback func(ReachabilityEntrypoint) error,
+) error {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	return rg.forEachEntrypoint(ctx, subjectType, resourceType, callback)
+}
+
+// forEachEntrypoint is ForEachEntrypoint for a resource type under any namespace, which is loaded
+// as walked.
+func (rg *ReachabilityGraph) forEachEntrypoint(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+	callback func(ReachabilityEntrypoint) error,
+) error {
+	if err := rg.validateSubjectType(ctx, subjectType); err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	defer func() {
+		walkDurationHistogram.WithLabelValues(rg.ts.nsDef.Name).Observe(time.Since(startTime).Seconds())
+	}()
+
+	ec := rg.newEntrypointCollector(subjectType, reachabilityFull, map[string]*core.ReachabilityGraph{})
+	ec.onEntrypoint = callback
+	if rg.containingKind != anyContainingKind {
+		matcher := rg.newContainingKindMatcher()
+		ec.onEntrypoint = func(entrypoint ReachabilityEntrypoint) error {
+			matches, err := matcher.matches(ctx, entrypoint)
+			if err != nil || !matches {
+				return err
+			}
+			return callback(entrypoint)
+		}
+	}
+
+	return rg.collectAllEntrypoints(ctx, ec, resourceType)
+}
+
+// errEntrypointFound stops a walk once an entrypoint has been found.
+var errEntrypointFound = errors.New("entrypoint found")
+
+// IsReachable returns whether the given subject type can reach the given resource type, i.e.
+// whether AllEntrypointsForSubjectToResource would return any entrypoint which is not a boundary.
+// The walk stops as soon as the first such entrypoint is found, and no entrypoints are collected.
+//
+// Boundary entrypoints are not considered, as it is unknown whether the subject type reaches the
+// relations beyond the boundary.
+func (rg *ReachabilityGraph) IsReachable(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (bool, error) {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return false, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	return rg.isReachable(ctx, subjectType, resourceType)
+}
+
+// isReachable is IsReachable for a resource type under any namespace, which is loaded as walked.
+func (rg *ReachabilityGraph) isReachable(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (bool, error) {
+	err := rg.forEachEntrypoint(ctx, subjectType, resourceType, func(entrypoint ReachabilityEntrypoint) error {
+		if entrypoint.IsBoundary() {
+			return nil
+		}
+		return errEntrypointFound
+	})
+	if errors.Is(err, errEntrypointFound) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// HasDirectEntrypoint returns whether the given subject type reaches the given resource type
+// through any entrypoint which is a direct result, i.e. whether AllEntrypointsForSubjectToResource
+// would return any entrypoint for which IsDirectResult is true. The walk stops as soon as the first
+// such entrypoint is found, and no entrypoints are collected.
+//
+// As with IsDirectResult, an entrypoint is a direct result if it is not under an intersection or
+// exclusion within its containing relation or permission; the relations or permissions through
+// which that one is in turn reached are not considered. Boundary entrypoints are not considered.
+func (rg *ReachabilityGraph) HasDirectEntrypoint(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (bool, error) {
+	err := rg.ForEachEntrypoint(ctx, subjectType, resourceType, func(entrypoint ReachabilityEntrypoint) error {
+		if entrypoint.IsBoundary() || !entrypoint.IsDirectResult() {
+			return nil
+		}
+		return errEntrypointFound
+	})
+	if errors.Is(err, errEntrypointFound) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// AreMutuallyReachable returns whether each of the given relations or permissions is reachable
+// from the other, i.e. whether a subject set of first reaches second and a subject set of second
+// reaches first, as reported by IsReachable. Mutually reachable relations form a structural cycle
+// in the schema, which may be intentional, such as nested groups, but is often worth reviewing.
+//
+// Either relation may be under any namespace, such as `document#viewer` and `folder#viewer`, as
+// each is walked to by loading its namespace like any other walked relation. The walk from second
+// to first is skipped if first does not reach second.
+func (rg *ReachabilityGraph) AreMutuallyReachable(
+	ctx context.Context,
+	first *core.RelationReference,
+	second *core.RelationReference,
+) (bool, error) {
+	reachable, err := rg.isReachable(ctx, first, second)
+	if err != nil || !reachable {
+		return false, err
+	}
+
+	return rg.isReachable(ctx, second, first)
+}
+
+// CountEntrypoints returns the number of entrypoints that AllEntrypointsForSubjectToResource would
+// return for the given subject type and resource type, including boundary entrypoints, without
+// collecting them.
+func (rg *ReachabilityGraph) CountEntrypoints(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (int, error) {
+	count := 0
+	err := rg.ForEachEntrypoint(ctx, subjectType, resourceType, func(ReachabilityEntrypoint) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GroupedEntrypoints holds the entrypoints found by a walk of the reachability graph, grouped
+// by their kind. Each group is sorted in the same order as AllEntrypointsForSubjectToResource.
+type GroupedEntrypoints struct {
+	// Relation holds the entrypoints of kind RelationEntrypointKind.
+	Relation []ReachabilityEntrypoint
+
+	// Arrow holds the entrypoints of kind ArrowEntrypointKind.
+	Arrow []ReachabilityEntrypoint
+
+	// SubjectRelation holds the entrypoints of kind SubjectRelationEntrypointKind.
+	SubjectRelation []ReachabilityEntrypoint
+}
+
+// GroupedEntrypointsForSubjectToResource returns the entrypoints returned by
+// AllEntrypointsForSubjectToResource, grouped by their kind.
+func (rg *ReachabilityGraph) GroupedEntrypointsForSubjectToResource(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) (GroupedEntrypoints, error) {
+	found, err := rg.entrypointsForSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
+	if err != nil {
+		return GroupedEntrypoints{}, err
+	}
+
+	grouped := GroupedEntrypoints{
+		Relation:        []ReachabilityEntrypoint{},
+		Arrow:           []ReachabilityEntrypoint{},
+		SubjectRelation: []ReachabilityEntrypoint{},
+	}
+	for _, entrypoint := range found {
+		switch entrypoint.Kind() {
+		case RelationEntrypointKind:
+			grouped.Relation = append(grouped.Relation, entrypoint)
+		case ArrowEntrypointKind:
+			grouped.Arrow = append(grouped.Arrow, entrypoint)
+		case SubjectRelationEntrypointKind:
+			grouped.SubjectRelation = append(grouped.SubjectRelation, entrypoint)
+		default:
+			return GroupedEntrypoints{}, fmt.Errorf("unknown kind of entrypoint: %v", entrypoint.Kind())
+		}
+	}
+
+	return grouped, nil
+}
+
+// AllEntrypointsForSubjectToResourceWithDiagnostics returns the entrypoints into the reachability
+// graph, starting at the given subject type and walking to the given resource type, along with
+// information about each relation that was skipped during the walk because it was already
+// encountered.
+func (rg *ReachabilityGraph) AllEntrypointsForSubjectToResourceWithDiagnostics(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]ReachabilityEntrypoint, []CycleInfo, error) {
+	ec, err := rg.walkSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ec.collected, ec.cycles, nil
+}
+
+// WalkDiagnostics describes a reachability walk from a subject type to a resource type.
+type WalkDiagnostics struct {
+	// Cycles holds each relation that was skipped during the walk because it was already
+	// encountered.
+	Cycles []CycleInfo
+
+	// MaxObservedDepth is the deepest depth at which a relation was walked, counted in subject
+	// relations away from the resource type, which has a depth of 0. Each relation is counted at
+	// the shallowest depth at which it was walked.
+	MaxObservedDepth int
+}
+
+// WalkDiagnosticsForSubjectToResource returns the entrypoints into the reachability graph,
+// starting at the given subject type and walking to the given resource type, along with the
+// diagnostics of the walk.
+//
+// A recursive relation, such as `relation member: user | group#member`, is walked once and then
+// skipped when reached again, which is reported as a cycle. This never prunes entrypoints: the
+// entrypoints of a relation do not depend on the path by which it was reached, so they were all
+// found when the relation was first walked. Nested groups therefore do not increase the observed
+// depth.
+func (rg *ReachabilityGraph) WalkDiagnosticsForSubjectToResource(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]ReachabilityEntrypoint, WalkDiagnostics, error) {
+	ec, err := rg.walkSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
+	if err != nil {
+		return nil, WalkDiagnostics{}, err
+	}
+
+	diagnostics := WalkDiagnostics{Cycles: ec.cycles}
+	for _, depth := range ec.encounteredRelations {
+		if depth > diagnostics.MaxObservedDepth {
+			diagnostics.MaxObservedDepth = depth
+		}
+	}
+
+	return ec.collected, diagnostics, nil
+}
+
+// EntrypointWithDepth is an entrypoint along with the depth at which it was found.
+type EntrypointWithDepth struct {
+	Entrypoint ReachabilityEntrypoint
+
+	// Depth is the shallowest depth at which the entrypoint was found, counted in subject
+	// relations away from the resource type, as limited by WithMaxDepth. Entrypoints found in the
+	// reachability graph of the resource type itself have a depth of 0.
+	Depth int
+}
+
+// EntrypointsWithDepth returns the entrypoints into the reachability graph, starting at the given
+// subject type and walking to the given resource type, each along with the depth at which it was
+// found. The entrypoints are sorted by depth, closest to the resource type first, and then as
+// sorted by SortEntrypoints.
+//
+// Each entrypoint is returned once, even if the graph was created without deduplication. To find
+// the shallowest depth of each entrypoint, a relation reached again at a shallower depth is walked
+// again, which may make the walk more expensive than that of AllEntrypointsForSubjectToResource.
+func (rg *ReachabilityGraph) EntrypointsWithDepth(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]EntrypointWithDepth, error) {
+	ec := rg.newEntrypointCollector(subjectType, reachabilityFull, map[string]*core.ReachabilityGraph{})
+	ec.foundEntrypoints = map[string]struct{}{}
+	ec.entrypointDepths = map[string]int{}
+	if err := rg.walkWithCollector(ctx, ec, resourceType); err != nil {
+		return nil, err
+	}
+
+	withDepths := make([]EntrypointWithDepth, 0, len(ec.collected))
+	for _, entrypoint := range ec.collected {
+		withDepths = append(withDepths, EntrypointWithDepth{
+			Entrypoint: entrypoint,
+			Depth:      ec.entrypointDepths[entrypoint.HashKey()],
+		})
+	}
+
+	sort.SliceStable(withDepths, func(i, j int) bool {
+		return withDepths[i].Depth < withDepths[j].Depth
+	})
+	return withDepths, nil
+}
+
+// AllEntrypointsForSubjectToResourceWithTraversedRelations returns the entrypoints into the
+// reachability graph, starting at the given subject type and walking to the given resource type,
+// along with every relation and permission traversed by the walk, including those which
+// contributed no entrypoints. The traversed relations are sorted by namespace and then by name.
+func (rg *ReachabilityGraph) AllEntrypointsForSubjectToResourceWithTraversedRelations(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]ReachabilityEntrypoint, []*core.RelationReference, error) {
+	ec, err := rg.walkSubjectToResource(ctx, subjectType, resourceType, reachabilityFull)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(ec.traversed, func(i, j int) bool {
+		if ec.traversed[i].Namespace != ec.traversed[j].Namespace {
+			return ec.traversed[i].Namespace < ec.traversed[j].Namespace
+		}
+		return ec.traversed[i].Relation < ec.traversed[j].Relation
+	})
+
+	return ec.collected, ec.traversed, nil
+}
+
+// HasAnyEntrypoint returns whether any subject can ever reach the given resource relation or
+// permission. A resource relation is considered reachable if at least one relation can be found
+// in the walk to it, whether directly or via computed usersets or arrows, onto which a subject
+// can be written.
+//
+// This can be used to find permissions that can never be satisfied.
+func (rg *ReachabilityGraph) HasAnyEntrypoint(ctx context.Context, resourceType *core.RelationReference) (bool, error) {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return false, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	return rg.hasAnyEntrypoint(ctx, resourceType, map[string]struct{}{})
+}
+
+func (rg *ReachabilityGraph) hasAnyEntrypoint(ctx context.Context, resourceType *core.RelationReference, encounteredRelations map[string]struct{}) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	key := relationKey(resourceType.Namespace, resourceType.Relation)
+	if rg.isRelationExcluded(key) {
+		return false, nil
+	}
+
+	if _, ok := encounteredRelations[key]; ok {
+		return false, nil
+	}
+	encounteredRelations[key] = struct{}{}
+
+	g, err := rg.reachabilityGraphFor(ctx, resourceType, reachabilityFull)
+	if err != nil {
+		return false, err
+	}
+
+	// Wildcards are always relation entrypoints.
+	if len(g.EntrypointsBySubjectType) > 0 {
+		return true, nil
+	}
+
+	for _, entrypointSet := range g.EntrypointsBySubjectRelation {
+		for _, entrypoint := range entrypointSet.Entrypoints {
+			if entrypoint.Kind == core.ReachabilityEntrypoint_RELATION_ENTRYPOINT {
+				return true, nil
+			}
+		}
+	}
+
+	// Otherwise, the relation is only reachable if one of the relations it is computed from
+	// (including those on the right side of arrows) is itself reachable.
+	for _, entrypointSet := range g.EntrypointsBySubjectRelation {
+		if entrypointSet.SubjectRelation == nil || entrypointSet.SubjectRelation.Relation == tuple.Ellipsis {
+			continue
+		}
+
+		found, err := rg.hasAnyEntrypoint(ctx, entrypointSet.SubjectRelation, encounteredRelations)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// SubjectTypesReaching returns every subject type with at least one entrypoint into the given
+// resource relation or permission, sorted by namespace and then by relation. A subject type is
+// returned for each subject relation (including `...`) found in the walk to the resource type, as
+// well as for each namespace with a public wildcard found in the walk, which is returned with the
+// `...` relation. AllEntrypointsForSubjectToResource returns entrypoints for each returned type.
+// A relation excluded with WithExcludedRelations is not walked, but is still returned if it is a
+// subject relation of a walked relation, as it has entrypoints into that relation.
+func (rg *ReachabilityGraph) SubjectTypesReaching(ctx context.Context, resourceType *core.RelationReference) ([]*core.RelationReference, error) {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return nil, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	subjectTypes := map[string]*core.RelationReference{}
+	if err := rg.collectSubjectTypes(ctx, resourceType, map[string]*core.RelationReference{}, subjectTypes); err != nil {
+		return nil, err
+	}
+
+	return sortedRelationReferences(subjectTypes), nil
+}
+
+// ReachableRelations returns every relation and permission transitively reachable from the given
+// resource relation or permission, including itself, sorted by namespace and then by relation.
+// The relations are those walked by AllEntrypointsForSubjectToResource for any subject type, i.e.
+// all the relations and permissions on which the resource relation depends, whether via computed
+// usersets, arrows or subject relations. The tupleset relation of an arrow is not walked, and is
+// therefore only returned if otherwise reachable. Each relation is walked once, so the walk
+// terminates on cyclic schemas.
+func (rg *ReachabilityGraph) ReachableRelations(ctx context.Context, resourceType *core.RelationReference) ([]*core.RelationReference, error) {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return nil, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	encounteredRelations := map[string]*core.RelationReference{}
+	if err := rg.collectSubjectTypes(ctx, resourceType, encounteredRelations, map[string]*core.RelationReference{}); err != nil {
+		return nil, err
+	}
+
+	return sortedRelationReferences(encounteredRelations), nil
+}
+
+// ReverseEntrypoints returns every entrypoint into the given resource relation or permission,
+// for any subject type, keyed by the namespace of the subject type. The entrypoints for a
+// namespace are those which AllEntrypointsForSubjectToResource returns for the subject types of
+// that namespace, including its subject relations, found in a single walk of the relations
+// returned by ReachableRelations. Each list of entrypoints is sorted, and namespaces without
+// entrypoints are omitted.
+func (rg *ReachabilityGraph) ReverseEntrypoints(ctx context.Context, resourceType *core.RelationReference) (map[string][]ReachabilityEntrypoint, error) {
+	relations, err := rg.ReachableRelations(ctx, resourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	collectors := map[string]*entrypointCollector{}
+	collectorFor := func(subjectNamespace string) *entrypointCollector {
+		ec, ok := collectors[subjectNamespace]
+		if !ok {
+			ec = rg.newEntrypointCollector(nil, reachabilityFull, nil)
+			collectors[subjectNamespace] = ec
+		}
+		return ec
+	}
+
+	for _, relation := range relations {
+		g, err := rg.reachabilityGraphFor(ctx, relation, reachabilityFull)
+		if err != nil {
+			return nil, err
+		}
+
+		for subjectNamespace, entrypoints := range g.EntrypointsBySubjectType {
+			if err := collectorFor(subjectNamespace).addEntrypoints(entrypoints, relation, nil, 0); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, entrypoints := range g.EntrypointsBySubjectRelation {
+			if entrypoints.SubjectRelation == nil {
+				continue
+			}
+
+			if err := collectorFor(entrypoints.SubjectRelation.Namespace).addEntrypoints(entrypoints, relation, nil, 0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	found := make(map[string][]ReachabilityEntrypoint, len(collectors))
+	for subjectNamespace, ec := range collectors {
+		if err := rg.filterByContainingKind(ctx, ec); err != nil {
+			return nil, err
+		}
+
+		if len(ec.collected) == 0 {
+			continue
+		}
+
+		SortEntrypoints(ec.collected)
+		found[subjectNamespace] = ec.collected
+	}
+
+	return found, nil
+}
+
+// sortedRelationReferences returns the relation references of the given map, sorted by namespace
+// and then by relation.
+func sortedRelationReferences(refs map[string]*core.RelationReference) []*core.RelationReference {
+	sorted := make([]*core.RelationReference, 0, len(refs))
+	for _, ref := range refs {
+		sorted = append(sorted, ref)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Relation < sorted[j].Relation
+	})
+	return sorted
+}
+
+// collectSubjectTypes walks from the given resource type, adding each walked relation to
+// encounteredRelations and each subject type found to subjectTypes.
+func (rg *ReachabilityGraph) collectSubjectTypes(
+	ctx context.Context,
+	resourceType *core.RelationReference,
+	encounteredRelations map[string]*core.RelationReference,
+	subjectTypes map[string]*core.RelationReference,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key := relationKey(resourceType.Namespace, resourceType.Relation)
+	if rg.isRelationExcluded(key) {
+		return nil
+	}
+
+	if _, ok := encounteredRelations[key]; ok {
+		return nil
+	}
+	encounteredRelations[key] = resourceType
+
+	g, err := rg.reachabilityGraphFor(ctx, resourceType, reachabilityFull)
+	if err != nil {
+		return err
+	}
+
+	for subjectNamespace := range g.EntrypointsBySubjectType {
+		subjectTypes[relationKey(subjectNamespace, tuple.Ellipsis)] = &core.RelationReference{
+			Namespace: subjectNamespace,
+			Relation:  tuple.Ellipsis,
+		}
+	}
+
+	for subjectRelationKey, entrypointSet := range g.EntrypointsBySubjectRelation {
+		if entrypointSet.SubjectRelation == nil {
+			continue
+		}
+
+		subjectTypes[subjectRelationKey] = entrypointSet.SubjectRelation
+		if entrypointSet.SubjectRelation.Relation == tuple.Ellipsis {
+			continue
+		}
+
+		if err := rg.collectSubjectTypes(ctx, entrypointSet.SubjectRelation, encounteredRelations, subjectTypes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CycleInfo describes a relation that was skipped during a reachability walk because it had
+// already been encountered, either because the schema is self-referential or because the
+// relation was reachable via more than one path.
+type CycleInfo struct {
+	// Relation is the relation that was skipped.
+	Relation *core.RelationReference
+
+	// Path is the path of relations walked to reach the skipped relation, starting at the
+	// resource relation and not including the skipped relation itself.
+	Path []*core.RelationReference
+
+	// IsCycle is true if the skipped relation is found on its own path, rather than having
+	// been reached by a different branch of the walk.
+	IsCycle bool
+}
+
+func (rg *ReachabilityGraph) entrypointsForSubjectToResource(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+	reachabilityOption reachabilityOption,
+) ([]ReachabilityEntrypoint, error) {
+	ctx, span := tracer.Start(ctx, "EntrypointsForSubjectToResource")
+	defer span.End()
+
+	ec, err := rg.walkSubjectToResource(ctx, subjectType, resourceType, reachabilityOption)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	// Attributes are only built if the span is recorded, so that tracing is negligible when
+	// disabled.
+	if span.IsRecording() {
+		span.SetAttributes(
+			resourceNamespaceKey.String(resourceType.Namespace),
+			resourceRelationKey.String(resourceType.Relation),
+			subjectTypeKey.String(tuple.StringRR(subjectType)),
+			entrypointCountKey.Int(len(ec.collected)),
+		)
+	}
+
+	return ec.collected, nil
+}
+
+// AllEntrypointsForSubjectToResources returns the entrypoints into the reachability graph, starting
+// at the given subject type and walking to each of the given resource types, keyed by the string
+// form of the resource type (`namespace#relation`). The resource types can be found in any
+// namespace available to the graph, and the reachability computed for each relation is reused
+// across all the walks.
+func (rg *ReachabilityGraph) AllEntrypointsForSubjectToResources(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceTypes []*core.RelationReference,
+) (map[string][]ReachabilityEntrypoint, error) {
+	if err := rg.validateSubjectType(ctx, subjectType); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	defer func() {
+		walkDurationHistogram.WithLabelValues(rg.ts.nsDef.Name).Observe(time.Since(startTime).Seconds())
+	}()
+
+	computedGraphs := map[string]*core.ReachabilityGraph{}
+	found := make(map[string][]ReachabilityEntrypoint, len(resourceTypes))
+	for _, resourceType := range resourceTypes {
+		// NOTE: each walk must have its own set of encountered relations, as a relation
+		// already walked for one resource type still needs to be walked for the others.
+		ec := rg.newEntrypointCollector(subjectType, reachabilityFull, computedGraphs)
+		if err := rg.collectAllEntrypoints(ctx, ec, resourceType); err != nil {
+			return nil, err
+		}
+
+		SortEntrypoints(ec.collected)
+		found[tuple.StringRR(resourceType)] = ec.collected
+	}
+
+	return found, nil
+}
+
+// AllResourcesReachableFrom returns the entrypoints by which the given subject type reaches each
+// relation and permission of the given candidate namespaces, keyed by the string form of the
+// resource type (`namespace#relation`). Resource types without entrypoints are omitted. As with
+// AllEntrypointsForSubjectToResources, the reachability computed for each relation is reused
+// across all the walks.
+//
+// Returns an error if any of the candidate namespaces does not exist.
+func (rg *ReachabilityGraph) AllResourcesReachableFrom(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	candidateNamespaces []string,
+) (map[string][]ReachabilityEntrypoint, error) {
+	resourceTypes := []*core.RelationReference{}
+	for _, namespaceName := range candidateNamespaces {
+		ts, err := rg.walkedTypeSystem(ctx, namespaceName)
+		if err != nil {
+			return nil, fmt.Errorf("unknown candidate namespace `%s` for reachability: %w", namespaceName, err)
+		}
+
+		for _, relation := range ts.nsDef.Relation {
+			resourceTypes = append(resourceTypes, &core.RelationReference{
+				Namespace: namespaceName,
+				Relation:  relation.Name,
+			})
+		}
+	}
+
+	found, err := rg.AllEntrypointsForSubjectToResources(ctx, subjectType, resourceTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	for resourceType, entrypoints := range found {
+		if len(entrypoints) == 0 {
+			delete(found, resourceType)
+		}
+	}
+
+	return found, nil
+}
+
+// validateSubjectType ensures that the namespace of the subject type exists, as otherwise a walk
+// would silently find no entrypoints. A subject type whose namespace exists but which has no
+// entrypoints into the resource is not an error, and will result in no entrypoints.
+func (rg *ReachabilityGraph) validateSubjectType(ctx context.Context, subjectType *core.RelationReference) error {
+	if subjectType.Namespace == rg.ts.nsDef.Name {
+		return nil
+	}
+
+	if _, err := rg.ts.lookupNamespaceDefinition(ctx, subjectType.Namespace); err != nil {
+		return fmt.Errorf("unknown subject namespace `%s` for reachability: %w", subjectType.Namespace, err)
+	}
+
+	return nil
+}
+
+// entrypointCollector holds the state of a single walk over the reachability graph. All
+// mutable state is guarded by the mutex, as branches of the walk may run concurrently.
+type entrypointCollector struct {
+	subjectType        *core.RelationReference
+	reachabilityOption reachabilityOption
+
+	// onEntrypoint, if non-nil, is invoked with each entrypoint as it is found, instead of
+	// the entrypoint being collected.
+	onEntrypoint func(ReachabilityEntrypoint) error
+
+	// directResultsOnly is true if entrypoints which are not direct results are dropped.
+	directResultsOnly bool
+
+	// workers holds a token for each additional branch being walked concurrently, if
+	// concurrency is enabled.
+	workers chan struct{}
+
+	mu        sync.Mutex
+	collected []ReachabilityEntrypoint
+	traversed []*core.RelationReference
+	cycles    []CycleInfo
+
+	// encounteredRelations holds the shallowest depth at which each relation was walked. It is
+	// keyed by relation rather than by path, as a relation reached again by another path (or by
+	// recursion) has no entrypoints beyond those found when it was first walked.
+	encounteredRelations map[string]int
+
+	// foundEntrypoints holds the HashKey of each entrypoint found, if deduplicating.
+	foundEntrypoints map[string]struct{}
+
+	// entrypointDepths holds the shallowest depth at which each entrypoint was found, keyed by its
+	// HashKey, if tracking depths.
+	entrypointDepths map[string]int
+
+	// computedGraphs holds the reachability graphs computed for each relation, and can be
+	// shared between collectors with the same reachability option.
+	computedGraphs map[string]*core.ReachabilityGraph
+}
+
+func (rg *ReachabilityGraph) newEntrypointCollector(
+	subjectType *core.RelationReference,
+	reachabilityOption reachabilityOption,
+	computedGraphs map[string]*core.ReachabilityGraph,
+) *entrypointCollector {
+	ec := &entrypointCollector{
+		subjectType:          subjectType,
+		reachabilityOption:   reachabilityOption,
+		collected:            []ReachabilityEntrypoint{},
+		encounteredRelations: map[string]int{},
+		computedGraphs:       computedGraphs,
+		directResultsOnly:    rg.directResultsOnly,
+	}
+
+	if rg.maxConcurrency > 1 {
+		ec.workers = make(chan struct{}, rg.maxConcurrency-1)
+	}
+
+	if rg.deduplicate {
+		ec.foundEntrypoints = map[string]struct{}{}
+	}
+
+	return ec
+}
+
+func (rg *ReachabilityGraph) walkSubjectToResource(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+	reachabilityOption reachabilityOption,
+) (*entrypointCollector, error) {
+	ec := rg.newEntrypointCollector(subjectType, reachabilityOption, map[string]*core.ReachabilityGraph{})
+	if err := rg.walkWithCollector(ctx, ec, resourceType); err != nil {
+		return nil, err
+	}
+
+	return ec, nil
+}
+
+// walkWithCollector walks from the subject type of the collector to the given resource type,
+// collecting the entrypoints found into the collector and sorting them.
+func (rg *ReachabilityGraph) walkWithCollector(
+	ctx context.Context,
+	ec *entrypointCollector,
+	resourceType *core.RelationReference,
+) error {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	if err := rg.validateSubjectType(ctx, ec.subjectType); err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	defer func() {
+		walkDurationHistogram.WithLabelValues(rg.ts.nsDef.Name).Observe(time.Since(startTime).Seconds())
+	}()
+
+	if err := rg.collectAllEntrypoints(ctx, ec, resourceType); err != nil {
+		return err
+	}
+
+	SortEntrypoints(ec.collected)
+	return nil
+}
+
+// collectAllEntrypoints walks from the given resource type, collecting its entrypoints, and then
+// adds the boundary entrypoints for the relations which were not walked.
+func (rg *ReachabilityGraph) collectAllEntrypoints(
+	ctx context.Context,
+	ec *entrypointCollector,
+	resourceType *core.RelationReference,
+) error {
+	if err := rg.collectEntrypoints(ctx, ec, resourceType, nil); err != nil {
+		return err
+	}
+
+	if err := rg.addBoundaryEntrypoints(ec); err != nil {
+		return err
+	}
+
+	return rg.filterByContainingKind(ctx, ec)
+}
+
+// filterByContainingKind removes the collected entrypoints whose containing relation or permission
+// is not of the kind configured by WithRelationsOnly or WithPermissionsOnly.
+func (rg *ReachabilityGraph) filterByContainingKind(ctx context.Context, ec *entrypointCollector) error {
+	if rg.containingKind == anyContainingKind {
+		return nil
+	}
+
+	matcher := rg.newContainingKindMatcher()
+	filtered := ec.collected[:0]
+	for _, entrypoint := range ec.collected {
+		matches, err := matcher.matches(ctx, entrypoint)
+		if err != nil {
+			return err
+		}
+
+		if matches {
+			filtered = append(filtered, entrypoint)
+		}
+	}
+
+	ec.collected = filtered
+	return nil
+}
+
+// containingKindMatcher matches entrypoints against the kind configured by WithRelationsOnly or
+// WithPermissionsOnly, reusing the type system of each namespace looked up.
+type containingKindMatcher struct {
+	rg          *ReachabilityGraph
+	typeSystems map[string]*TypeSystem
+}
+
+func (rg *ReachabilityGraph) newContainingKindMatcher() *containingKindMatcher {
+	return &containingKindMatcher{rg: rg, typeSystems: map[string]*TypeSystem{}}
+}
 
-	relation, ok := rts.relationMap[resourceType.Relation]
+// matches returns whether the containing relation or permission of the entrypoint is of the
+// configured kind.
+func (m *containingKindMatcher) matches(ctx context.Context, entrypoint ReachabilityEntrypoint) (bool, error) {
+	containing := entrypoint.ContainingRelationOrPermission()
+	ts, ok := m.typeSystems[containing.Namespace]
 	if !ok {
-		return fmt.Errorf("unknown relation `%s` under namespace `%s` for reachability", resourceType.Relation, resourceType.Namespace)
+		found, err := m.rg.ts.typeSystemForNamespace(ctx, containing.Namespace)
+		if err != nil {
+			return false, err
+		}
+
+		ts = found
+		m.typeSystems[containing.Namespace] = ts
+	}
+
+	return ts.IsPermission(containing.Relation) == (m.rg.containingKind == permissionsOnly), nil
+}
+
+func (rg *ReachabilityGraph) collectEntrypoints(
+	ctx context.Context,
+	ec *entrypointCollector,
+	resourceType *core.RelationReference,
+	path []*core.RelationReference,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// Decorate with operation paths, if necessary.
-	derr := decorateRelationOpPaths(relation)
-	if derr != nil {
-		return derr
+	key := relationKey(resourceType.Namespace, resourceType.Relation)
+	if rg.isRelationExcluded(key) {
+		return nil
 	}
 
-	g, err := computeReachability(ctx, rrg.ts, resourceType.Relation, reachabilityOption)
-	if err != nil {
+	ctx, span := tracer.Start(ctx, "collectEntrypoints")
+	defer span.End()
+
+	// Ensure that we only process each relation once.
+	g, ok, revisited, err := rg.beginRelation(ctx, ec, key, resourceType, path)
+	if err != nil || !ok {
 		return err
 	}
 
-	// Add subject type entrypoints.
-	subjectTypeEntrypoints, ok := g.EntrypointsBySubjectType[subjectType.Namespace]
-	if ok {
-		addEntrypoints(subjectTypeEntrypoints, resourceType, collected)
+	subjectType := ec.subjectType
+
+	// The entrypoints of a relation walked again from a shallower depth were already added, unless
+	// tracking their depths, in which case they are added again to lower their depths.
+	entrypointCount := 0
+	if !revisited || ec.entrypointDepths != nil {
+		// Add subject type entrypoints. These are reached by a wildcard, which only matches
+		// subjects without a relation, so a subject relation never reaches them.
+		subjectTypeEntrypoints, ok := g.EntrypointsBySubjectType[subjectType.Namespace]
+		if ok && subjectType.Relation == tuple.Ellipsis {
+			if err := ec.addEntrypoints(subjectTypeEntrypoints, resourceType, nil, len(path)); err != nil {
+				return err
+			}
+			entrypointCount += len(subjectTypeEntrypoints.Entrypoints)
+		}
+
+		// Add subject relation entrypoints.
+		subjectRelationEntrypoints, ok := g.EntrypointsBySubjectRelation[relationKey(subjectType.Namespace, subjectType.Relation)]
+		if ok {
+			if err := ec.addEntrypoints(subjectRelationEntrypoints, resourceType, nil, len(path)); err != nil {
+				return err
+			}
+			entrypointCount += len(subjectRelationEntrypoints.Entrypoints)
+		}
 	}
 
-	// Add subject relation entrypoints.
-	subjectRelationEntrypoints, ok := g.EntrypointsBySubjectRelation[relationKey(subjectType.Namespace, subjectType.Relation)]
-	if ok {
-		addEntrypoints(subjectRelationEntrypoints, resourceType, collected)
+	if span.IsRecording() {
+		span.SetAttributes(
+			resourceNamespaceKey.String(resourceType.Namespace),
+			resourceRelationKey.String(resourceType.Relation),
+			entrypointCountKey.Int(entrypointCount),
+		)
+	}
+
+	// Relations at the maximum depth are not walked further; their boundary entrypoints are
+	// added once the walk completes.
+	if rg.isAtMaxDepth(len(path)) {
+		return nil
 	}
 
 	// Recursively collect over any reachability graphs for subjects with non-ellipsis relations.
+	childPath := append(path[:len(path):len(path)], resourceType)
+	if ec.workers == nil {
+		for _, entrypointSet := range g.EntrypointsBySubjectRelation {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if entrypointSet.SubjectRelation != nil && entrypointSet.SubjectRelation.Relation != tuple.Ellipsis {
+				if !rg.isNamespaceAllowed(entrypointSet.SubjectRelation.Namespace) {
+					continue
+				}
+
+				err := rg.collectEntrypoints(ctx, ec, entrypointSet.SubjectRelation, childPath)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	// Walk each branch in its own goroutine while workers are available, and walk it on the
+	// current goroutine otherwise. Never blocking on a worker ensures that nested branches
+	// cannot deadlock waiting on their parents.
+	branchCtx, cancelBranches := context.WithCancel(ctx)
+	defer cancelBranches()
+
+	eg, egCtx := errgroup.WithContext(branchCtx)
 	for _, entrypointSet := range g.EntrypointsBySubjectRelation {
-		if entrypointSet.SubjectRelation != nil && entrypointSet.SubjectRelation.Relation != tuple.Ellipsis {
-			err := rrg.collectEntrypoints(ctx, subjectType, entrypointSet.SubjectRelation, collected, encounteredRelations, reachabilityOption)
+		if entrypointSet.SubjectRelation == nil || entrypointSet.SubjectRelation.Relation == tuple.Ellipsis {
+			continue
+		}
+
+		if !rg.isNamespaceAllowed(entrypointSet.SubjectRelation.Namespace) {
+			continue
+		}
+
+		subjectRelation := entrypointSet.SubjectRelation
+		select {
+		case ec.workers <- struct{}{}:
+			eg.Go(func() error {
+				defer func() { <-ec.workers }()
+				return rg.collectEntrypoints(egCtx, ec, subjectRelation, childPath)
+			})
+
+		default:
+			err := rg.collectEntrypoints(egCtx, ec, subjectRelation, childPath)
 			if err != nil {
+				cancelBranches()
+				_ = eg.Wait()
+				return err
+			}
+		}
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// Since the errgroup's context is canceled after Wait, check the parent context directly.
+	return ctx.Err()
+}
+
+// beginRelation marks the relation as encountered and returns its reachability graph, or false
+// if the relation was already encountered in the walk. If the walk is limited in depth or tracks
+// the depths of entrypoints, a relation encountered again at a shallower depth is walked again so
+// that it can be walked deeper, and is returned as revisited.
+func (rg *ReachabilityGraph) beginRelation(
+	ctx context.Context,
+	ec *entrypointCollector,
+	key string,
+	resourceType *core.RelationReference,
+	path []*core.RelationReference,
+) (*core.ReachabilityGraph, bool, bool, error) {
+	depth := len(path)
+
+	ec.mu.Lock()
+	walkedDepth, revisited := ec.encounteredRelations[key]
+	rewalksShallower := rg.maxDepth != unlimitedDepth || ec.entrypointDepths != nil
+	if revisited && (!rewalksShallower || depth >= walkedDepth) {
+		ec.recordCycle(resourceType, path)
+		ec.mu.Unlock()
+		return nil, false, false, nil
+	}
+
+	ec.encounteredRelations[key] = depth
+	if !revisited {
+		ec.traversed = append(ec.traversed, resourceType)
+	}
+	g, ok := ec.computedGraphs[key]
+	ec.mu.Unlock()
+
+	if ok {
+		return g, true, revisited, nil
+	}
+
+	computed, err := rg.reachabilityGraphFor(ctx, resourceType, ec.reachabilityOption)
+	if err != nil {
+		if depth > 0 {
+			// The relation was reached from the last relation on the path, which references it.
+			return nil, false, false, wrapReferencedNamespaceNotFound(err, path[depth-1])
+		}
+		return nil, false, false, err
+	}
+
+	ec.mu.Lock()
+	ec.computedGraphs[key] = computed
+	ec.mu.Unlock()
+
+	return computed, true, revisited, nil
+}
+
+// isAtMaxDepth returns whether relations at the given depth must not be walked further.
+func (rg *ReachabilityGraph) isAtMaxDepth(depth int) bool {
+	return rg.maxDepth != unlimitedDepth && depth >= rg.maxDepth
+}
+
+// addBoundaryEntrypoints adds, once the walk has completed, a boundary entrypoint for each
+// entrypoint into a walked relation from a subject relation which was not walked: either because
+// its namespace is outside of the namespace allowlist, or because the walked relation is at the
+// maximum depth.
+func (rg *ReachabilityGraph) addBoundaryEntrypoints(ec *entrypointCollector) error {
+	for _, relation := range ec.traversed {
+		key := relationKey(relation.Namespace, relation.Relation)
+		g := ec.computedGraphs[key]
+		atMaxDepth := rg.isAtMaxDepth(ec.encounteredRelations[key])
+
+		subjectRelationKeys := make([]string, 0, len(g.EntrypointsBySubjectRelation))
+		for subjectRelationKey := range g.EntrypointsBySubjectRelation {
+			subjectRelationKeys = append(subjectRelationKeys, subjectRelationKey)
+		}
+		sort.Strings(subjectRelationKeys)
+
+		for _, subjectRelationKey := range subjectRelationKeys {
+			entrypointSet := g.EntrypointsBySubjectRelation[subjectRelationKey]
+			if entrypointSet.SubjectRelation == nil || entrypointSet.SubjectRelation.Relation == tuple.Ellipsis {
+				continue
+			}
+
+			if rg.isRelationExcluded(subjectRelationKey) {
+				continue
+			}
+
+			_, walked := ec.encounteredRelations[subjectRelationKey]
+			if rg.isNamespaceAllowed(entrypointSet.SubjectRelation.Namespace) && (!atMaxDepth || walked) {
+				continue
+			}
+
+			if err := ec.addEntrypoints(entrypointSet, relation, entrypointSet.SubjectRelation, ec.encounteredRelations[key]); err != nil {
 				return err
 			}
 		}
@@ -183,8 +1914,223 @@ func (rg *ReachabilityGraph) collectEntrypoints(
 	return nil
 }
 
-func addEntrypoints(entrypoints *core.ReachabilityEntrypoints, parentRelation *core.RelationReference, collected *[]ReachabilityEntrypoint) {
+// isNamespaceAllowed returns whether walks may enter relations of the given namespace.
+func (rg *ReachabilityGraph) isNamespaceAllowed(namespaceName string) bool {
+	if rg.namespaceAllowlist == nil {
+		return true
+	}
+
+	_, ok := rg.namespaceAllowlist[namespaceName]
+	return ok
+}
+
+// isRelationExcluded returns whether the relation with the given key is pruned from walks.
+func (rg *ReachabilityGraph) isRelationExcluded(key string) bool {
+	_, ok := rg.excludedRelations[key]
+	return ok
+}
+
+// addEntrypoints adds an entrypoint under the given parent relation for each of the given
+// entrypoints, either by collecting it or, if the collector has a callback, by invoking the
+// callback with it. If the boundary relation is non-nil, the entrypoints are reached from a
+// relation outside of the namespace allowlist. Entrypoints keyed by subject type are reached by
+// a wildcard. If deduplicating, entrypoints which were already found are skipped. If tracking
+// depths, the entrypoints are recorded as found at the given depth, unless found shallower.
+//
+// The callback is invoked with the mutex held, so it is never invoked concurrently.
+func (ec *entrypointCollector) addEntrypoints(entrypoints *core.ReachabilityEntrypoints, parentRelation *core.RelationReference, boundaryRelation *core.RelationReference, depth int) error {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if ec.onEntrypoint == nil {
+		ec.growCollected(ec.countCollectable(entrypoints.Entrypoints))
+	}
+
 	for _, entrypoint := range entrypoints.Entrypoints {
-		*collected = append(*collected, ReachabilityEntrypoint{entrypoint, parentRelation})
+		if !ec.isCollectable(entrypoint) {
+			continue
+		}
+
+		found := ReachabilityEntrypoint{
+			re:               entrypoint,
+			parentRelation:   parentRelation,
+			boundaryRelation: boundaryRelation,
+			wildcard:         entrypoints.SubjectType != "",
+		}
+
+		if ec.entrypointDepths != nil {
+			hashKey := found.HashKey()
+			if foundDepth, ok := ec.entrypointDepths[hashKey]; !ok || depth < foundDepth {
+				ec.entrypointDepths[hashKey] = depth
+			}
+		}
+
+		if ec.foundEntrypoints != nil {
+			hashKey := found.HashKey()
+			if _, ok := ec.foundEntrypoints[hashKey]; ok {
+				continue
+			}
+			ec.foundEntrypoints[hashKey] = struct{}{}
+		}
+
+		if ec.onEntrypoint != nil {
+			if err := ec.onEntrypoint(found); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ec.collected = append(ec.collected, found)
+	}
+
+	return nil
+}
+
+// isCollectable returns whether the entrypoint is kept by the collector rather than dropped.
+func (ec *entrypointCollector) isCollectable(entrypoint *core.ReachabilityEntrypoint) bool {
+	return !ec.directResultsOnly || entrypoint.ResultStatus == core.ReachabilityEntrypoint_DIRECT_OPERATION_RESULT
+}
+
+// countCollectable returns the number of the given entrypoints which are kept by the collector.
+func (ec *entrypointCollector) countCollectable(entrypoints []*core.ReachabilityEntrypoint) int {
+	if !ec.directResultsOnly {
+		return len(entrypoints)
+	}
+
+	count := 0
+	for _, entrypoint := range entrypoints {
+		if ec.isCollectable(entrypoint) {
+			count++
+		}
+	}
+	return count
+}
+
+// growCollected ensures that the given number of entrypoints can be collected without growing the
+// collected slice more than once. The capacity is at least doubled, such that repeated calls do not
+// copy the collected entrypoints on each call. Must be called with the mutex held.
+func (ec *entrypointCollector) growCollected(count int) {
+	if cap(ec.collected)-len(ec.collected) >= count {
+		return
+	}
+
+	capacity := 2 * cap(ec.collected)
+	if capacity < len(ec.collected)+count {
+		capacity = len(ec.collected) + count
+	}
+
+	grown := make([]ReachabilityEntrypoint, len(ec.collected), capacity)
+	copy(grown, ec.collected)
+	ec.collected = grown
+}
+
+// recordCycle records that the relation was skipped. Must be called with the mutex held.
+func (ec *entrypointCollector) recordCycle(relation *core.RelationReference, path []*core.RelationReference) {
+	isCycle := false
+	for _, walked := range path {
+		if walked.Namespace == relation.Namespace && walked.Relation == relation.Relation {
+			isCycle = true
+			break
+		}
+	}
+
+	ec.cycles = append(ec.cycles, CycleInfo{
+		Relation: relation,
+		Path:     path,
+		IsCycle:  isCycle,
+	})
+}
+
+// reachabilityGraphFor returns the computed reachability graph for the given resource relation,
+// consulting the cache (if any) before loading the namespace and computing the graph.
+func (rg *ReachabilityGraph) reachabilityGraphFor(
+	ctx context.Context,
+	resourceType *core.RelationReference,
+	reachabilityOption reachabilityOption,
+) (*core.ReachabilityGraph, error) {
+	if rg.cache != nil {
+		if cached, ok := rg.cache.get(resourceType, reachabilityOption, rg.revision); ok {
+			cacheHitsCounter.WithLabelValues(rg.ts.nsDef.Name).Inc()
+			return cached, nil
+		}
+		cacheMissesCounter.WithLabelValues(rg.ts.nsDef.Name).Inc()
+	}
+
+	rts, err := rg.walkedTypeSystem(ctx, resourceType.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := rts.relationMap[resourceType.Relation]; !ok {
+		return nil, fmt.Errorf("unknown relation `%s` under namespace `%s` for reachability", resourceType.Relation, resourceType.Namespace)
+	}
+
+	g, err := computeReachability(ctx, rts, resourceType.Relation, reachabilityOption)
+	if err != nil {
+		return nil, err
+	}
+
+	if rg.cache != nil {
+		rg.cache.set(resourceType, reachabilityOption, rg.revision, g)
+	}
+
+	return g, nil
+}
+
+// walkedNamespace holds the type system of a namespace walked into, once loaded.
+type walkedNamespace struct {
+	mu sync.Mutex
+	ts *TypeSystem
+}
+
+// walkedTypeSystem returns the type system for the given namespace, with all of its relations
+// decorated with operation paths. The type system of the graph is reused for its own namespace,
+// and other namespaces are loaded and decorated once, before being shared by all walks of the
+// graph, such that concurrent branches only ever read the relations of a namespace.
+func (rg *ReachabilityGraph) walkedTypeSystem(ctx context.Context, namespaceName string) (*TypeSystem, error) {
+	if rg.rootTypeSystem != nil && namespaceName == rg.rootTypeSystem.nsDef.Name {
+		return rg.rootTypeSystem, nil
 	}
+
+	rg.walkedMu.Lock()
+	if rg.walkedNamespaces == nil {
+		rg.walkedNamespaces = map[string]*walkedNamespace{}
+	}
+	walked, ok := rg.walkedNamespaces[namespaceName]
+	if !ok {
+		walked = &walkedNamespace{}
+		rg.walkedNamespaces[namespaceName] = walked
+	}
+	rg.walkedMu.Unlock()
+
+	// Errors are not kept, so that a walk whose context was canceled does not fail later walks.
+	walked.mu.Lock()
+	defer walked.mu.Unlock()
+	if walked.ts != nil {
+		return walked.ts, nil
+	}
+
+	ts, err := rg.loadWalkedTypeSystem(ctx, namespaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	walked.ts = ts
+	return ts, nil
+}
+
+// loadWalkedTypeSystem loads the type system for the given namespace, over a copy of its
+// definition with all of its relations decorated with operation paths.
+func (rg *ReachabilityGraph) loadWalkedTypeSystem(ctx context.Context, namespaceName string) (*TypeSystem, error) {
+	ctx, span := tracer.Start(ctx, "loadNamespace", trace.WithAttributes(namespaceNameKey.String(namespaceName)))
+	defer span.End()
+
+	namespace, err := rg.ts.lookupNamespaceDefinition(ctx, namespaceName)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	namespacesLoadedCounter.WithLabelValues(rg.ts.nsDef.Name).Inc()
+
+	return buildDecoratedTypeSystem(namespace, rg.ts.lookupNamespace)
 }
@@ -2,18 +2,29 @@ package namespace
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
 	ns "github.com/authzed/spicedb/pkg/namespace"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
 	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 func TestReachabilityGraph(t *testing.T) {
@@ -507,13 +518,17 @@ func TestReachabilityGraph(t *testing.T) {
 			}
 			require.NotNil(rts)
 
-			foundEntrypoints, err := ReachabilityGraphFor(rts).AllEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
+			foundEntrypoints, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
 			require.NoError(err)
 			verifyEntrypoints(require, foundEntrypoints, tc.expectedFullEntrypointRelations)
 
-			foundOptEntrypoints, err := ReachabilityGraphFor(rts).OptimizedEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
+			foundOptEntrypoints, err := reachabilityGraphForTest(t, rts).OptimizedEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
 			require.NoError(err)
 			verifyEntrypoints(require, foundOptEntrypoints, tc.expectedOptimizedEntrypointRelations)
+
+			foundConcurrentEntrypoints, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(4)).AllEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
+			require.NoError(err)
+			verifyEntrypoints(require, foundConcurrentEntrypoints, tc.expectedFullEntrypointRelations)
 		})
 	}
 }
@@ -555,3 +570,2617 @@ func rrt(namespace, relation string, isDirect bool) rrtStruct {
 func relationRefKey(ref *core.RelationReference) string {
 	return relationKey(ref.Namespace, ref.GetRelation())
 }
+
+func TestReachabilityGraphCache(t *testing.T) {
+	require := require.New(t)
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: `definition user {}
+
+		definition organization {
+			relation admin: user
+		}
+
+		definition document {
+			relation org: organization
+			relation viewer: user
+			permission view = viewer + org->admin
+		}`},
+	}, &empty)
+	require.NoError(err)
+
+	lookupCount := 0
+	lookup := func(ctx context.Context, name string) (*core.NamespaceDefinition, error) {
+		lookupCount++
+		for _, def := range defs {
+			if def.Name == name {
+				return def, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown definition %s", name)
+	}
+
+	docDef, err := lookup(context.Background(), "document")
+	require.NoError(err)
+
+	ts, err := BuildNamespaceTypeSystem(docDef, lookup)
+	require.NoError(err)
+
+	cache, err := NewReachabilityGraphCache(nil)
+	require.NoError(err)
+	defer cache.Close()
+
+	ctx := context.Background()
+	expected := []rrtStruct{
+		rrt("document", "viewer", true),
+		rrt("organization", "admin", true),
+	}
+
+	lookupCount = 0
+	found, err := reachabilityGraphForWithCacheTest(t, ts.AsValidated(), cache, decimal.NewFromInt(1)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	verifyEntrypoints(require, found, expected)
+	require.Greater(lookupCount, 0)
+	cache.c.Wait()
+
+	// A second walk at the same revision should be served from the cache, with only the
+	// subject namespace being looked up to validate it.
+	lookupCount = 0
+	found, err = reachabilityGraphForWithCacheTest(t, ts.AsValidated(), cache, decimal.NewFromInt(1)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	verifyEntrypoints(require, found, expected)
+	require.Equal(1, lookupCount)
+
+	// A walk at another revision must not reuse the cached graphs.
+	lookupCount = 0
+	found, err = reachabilityGraphForWithCacheTest(t, ts.AsValidated(), cache, decimal.NewFromInt(2)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	verifyEntrypoints(require, found, expected)
+	require.Greater(lookupCount, 1)
+
+	// A graph cannot be cached without a revision.
+	_, err = ReachabilityGraphForWithCache(ts.AsValidated(), cache, datastore.NoRevision)
+	require.ErrorContains(err, "a datastore revision is required to cache reachability graphs")
+}
+
+func TestReachabilityGraphCycleDiagnostics(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: group#member
+		permission view = viewer
+	}`, "document")
+
+	found, cycles, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResourceWithDiagnostics(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	verifyEntrypoints(require, found, []rrtStruct{rrt("group", "member", true)})
+
+	require.Len(cycles, 1)
+	require.Equal("group#member", relationRefKey(cycles[0].Relation))
+	require.True(cycles[0].IsCycle)
+
+	path := make([]string, 0, len(cycles[0].Path))
+	for _, walked := range cycles[0].Path {
+		path = append(path, relationRefKey(walked))
+	}
+	require.Equal([]string{"document#view", "document#viewer", "group#member"}, path)
+}
+
+// reachabilityGraphForTest returns the reachability graph for the given namespace, failing the
+// test if it cannot be built.
+func reachabilityGraphForTest(t testing.TB, ts *ValidatedNamespaceTypeSystem, options ...ReachabilityGraphOption) *ReachabilityGraph {
+	rg, err := ReachabilityGraphFor(ts, options...)
+	require.NoError(t, err)
+	return rg
+}
+
+// reachabilityGraphForWithCacheTest returns the reachability graph for the given namespace with
+// the given cache, failing the test if it cannot be built.
+func reachabilityGraphForWithCacheTest(t testing.TB, ts *ValidatedNamespaceTypeSystem, cache *ReachabilityGraphCache, revision datastore.Revision, options ...ReachabilityGraphOption) *ReachabilityGraph {
+	rg, err := ReachabilityGraphForWithCache(ts, cache, revision, options...)
+	require.NoError(t, err)
+	return rg
+}
+
+func buildReachabilityTypeSystem(t *testing.T, schema string, namespaceName string) (*ValidatedNamespaceTypeSystem, context.Context) {
+	require := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ctx := datastoremw.ContextWithDatastore(context.Background(), ds)
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: schema},
+	}, &empty)
+	require.NoError(err)
+
+	var rts *ValidatedNamespaceTypeSystem
+	for _, nsDef := range defs {
+		ts, err := BuildNamespaceTypeSystemWithFallback(nsDef, ds.SnapshotReader(decimal.Zero), defs)
+		require.NoError(err)
+
+		vts, terr := ts.Validate(ctx)
+		require.NoError(terr)
+
+		if nsDef.Name == namespaceName {
+			rts = vts
+		}
+	}
+	require.NotNil(rts)
+	return rts, ctx
+}
+
+func TestReachabilityEntrypointOperationPath(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition document {
+		relation viewer: user
+		relation editor: user
+		permission view = viewer + (editor & viewer)
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+
+	paths := make([][]uint32, 0, len(found))
+	rendered := make([]string, 0, len(found))
+	for _, entrypoint := range found {
+		paths = append(paths, entrypoint.OperationPath())
+		rendered = append(rendered, entrypoint.String())
+	}
+	sort.Strings(rendered)
+
+	require.ElementsMatch([][]uint32{{0}, {1, 1}}, paths)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#view[0]",
+		"COMPUTED_USERSET_ENTRYPOINT document#view[1.1]",
+	}, rendered)
+}
+
+func TestReachabilityEntrypointContainingOperationTypes(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition document {
+		relation viewer: user
+		relation editor: user
+		relation banned: user
+		permission edit = editor
+		permission view = viewer + ((editor & viewer) - banned)
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+
+	operationTypes := make(map[string][]SetOperationType, len(found))
+	for _, entrypoint := range found {
+		types, err := entrypoint.ContainingOperationTypes(rts.nsDef)
+		require.NoError(err)
+		operationTypes[entrypoint.String()] = types
+	}
+
+	require.Equal(map[string][]SetOperationType{
+		"COMPUTED_USERSET_ENTRYPOINT document#view[0]":     {SetOperationUnion},
+		"COMPUTED_USERSET_ENTRYPOINT document#view[1.0.1]": {SetOperationUnion, SetOperationExclusion, SetOperationIntersection},
+	}, operationTypes)
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "banned"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+	types, err := found[0].ContainingOperationTypes(rts.nsDef)
+	require.NoError(err)
+	require.Equal([]SetOperationType{SetOperationUnion, SetOperationExclusion}, types)
+	require.Equal("exclusion", types[1].String())
+
+	// An operation path which is not found in the rewrite is an error.
+	invalid := found[0]
+	invalid.re = &core.ReachabilityEntrypoint{Kind: found[0].re.Kind, OperationPath: []uint32{1, 5}}
+	_, err = invalid.ContainingOperationTypes(rts.nsDef)
+	require.ErrorContains(err, "operation path [1.5] not found")
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "editor"))
+	require.NoError(err)
+	require.Len(found, 1)
+	types, err = found[0].ContainingOperationTypes(rts.nsDef)
+	require.NoError(err)
+	require.Empty(types)
+
+	// The namespace definition must be that of the containing relation.
+	_, err = found[0].ContainingOperationTypes(&core.NamespaceDefinition{Name: "user"})
+	require.Error(err)
+
+	_, err = found[0].ContainingOperationTypes(&core.NamespaceDefinition{Name: "document"})
+	require.ErrorContains(err, "relation `editor` not found")
+}
+
+func TestReachabilityGraphTraversedRelations(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition bot {}
+
+	definition organization {
+		relation admin: user
+		relation member: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation blocked: bot
+		permission view = viewer + blocked + org->admin
+	}`, "document")
+
+	found, traversed, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResourceWithTraversedRelations(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	verifyEntrypoints(require, found, []rrtStruct{
+		rrt("document", "viewer", true),
+		rrt("organization", "admin", true),
+	})
+
+	traversedStrings := make([]string, 0, len(traversed))
+	for _, relation := range traversed {
+		traversedStrings = append(traversedStrings, tuple.StringRR(relation))
+	}
+
+	// The blocked relation contributes no entrypoints for users, but is still traversed.
+	require.Equal([]string{
+		"document#blocked",
+		"document#view",
+		"document#viewer",
+		"organization#admin",
+	}, traversedStrings)
+}
+
+func TestReachabilityGraphNamespaceAllowlist(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		permission view = viewer + org->admin
+	}`, "document")
+
+	for _, maxConcurrency := range []uint16{1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", maxConcurrency), func(t *testing.T) {
+			require := require.New(t)
+
+			found, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Equal([]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT organization#admin[]",
+			}, entrypointStrings(found))
+
+			confined := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency), WithNamespaceAllowlist("document"))
+			found, err = confined.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Equal([]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1] boundary organization#admin",
+				"RELATION_ENTRYPOINT document#viewer[]",
+			}, entrypointStrings(found))
+
+			require.True(found[0].IsBoundary())
+			require.Equal("organization#admin", tuple.StringRR(found[0].BoundaryRelation()))
+			require.False(found[1].IsBoundary())
+			require.Nil(found[1].BoundaryRelation())
+		})
+	}
+}
+
+func TestReachabilityGraphCanonicalEntrypoints(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation member: user
+		permission membership = member
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		permission can_view = viewer
+		permission alias_of_alias = can_view
+		permission view = alias_of_alias + editor + org->membership
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#can_view[0]",
+	}, entrypointStrings(found))
+
+	canonical, err := rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#view[0.0]",
+	}, entrypointStrings(canonical))
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("organization", "member"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT organization#membership[0]",
+	}, entrypointStrings(found))
+
+	canonical, err = rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("organization", "member"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+	}, entrypointStrings(canonical))
+
+	// Walking to the alias itself still reports its entrypoint.
+	canonical, err = rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "can_view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#can_view[0]",
+	}, entrypointStrings(canonical))
+
+	// When the resource type is an alias of an alias, the alias in between is collapsed but the
+	// resource type itself is not.
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "alias_of_alias"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#can_view[0]",
+	}, entrypointStrings(found))
+
+	canonical, err = rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "alias_of_alias"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#alias_of_alias[0]",
+	}, entrypointStrings(canonical))
+
+	// Subjects of the aliased relation reach an alias resource type through the aliased relation.
+	canonical, err = rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "alias_of_alias"))
+	require.NoError(err)
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#viewer[]",
+	}, entrypointStrings(canonical))
+
+	// Subjects reaching the source relation directly see the same entrypoints either way.
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	canonical, err = rg.CanonicalEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Equal(entrypointStrings(found), entrypointStrings(canonical))
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#editor[]",
+		"RELATION_ENTRYPOINT document#viewer[]",
+		"RELATION_ENTRYPOINT organization#member[]",
+	}, entrypointStrings(canonical))
+}
+
+func TestReachabilityGraphForEachEntrypoint(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+		relation member: user
+		permission membership = admin + member
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		permission view = viewer + editor + org->membership
+	}`, "document")
+
+	for _, maxConcurrency := range []uint16{1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", maxConcurrency), func(t *testing.T) {
+			require := require.New(t)
+
+			rg := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency))
+			expected, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Len(expected, 4)
+
+			var found []ReachabilityEntrypoint
+			err = rg.ForEachEntrypoint(ctx, rr("user", "..."), rr("document", "view"), func(entrypoint ReachabilityEntrypoint) error {
+				found = append(found, entrypoint)
+				return nil
+			})
+			require.NoError(err)
+
+			SortEntrypoints(found)
+			require.Equal(entrypointStrings(expected), entrypointStrings(found))
+
+			count, err := rg.CountEntrypoints(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Equal(len(expected), count)
+
+			errStop := errors.New("stop")
+			calls := 0
+			err = rg.ForEachEntrypoint(ctx, rr("user", "..."), rr("document", "view"), func(entrypoint ReachabilityEntrypoint) error {
+				calls++
+				return errStop
+			})
+			require.ErrorIs(err, errStop)
+			require.Equal(1, calls)
+		})
+	}
+}
+
+func TestReachabilityGraphWildcardSubject(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | user:*
+	}
+
+	definition document {
+		relation group: group
+		relation viewer: user | user:*
+		relation editor: user
+		permission view = viewer + editor + group->member
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#editor[]",
+		"RELATION_ENTRYPOINT document#viewer[]",
+		"RELATION_ENTRYPOINT document#viewer[] wildcard",
+		"RELATION_ENTRYPOINT group#member[]",
+		"RELATION_ENTRYPOINT group#member[] wildcard",
+	}, entrypointStrings(found))
+
+	// The direct and wildcard entrypoints for the viewer relation are distinct.
+	require.False(found[1].IsWildcard())
+	require.True(found[2].IsWildcard())
+	require.False(found[1].Equal(found[2]))
+	require.NotEqual(found[1].HashKey(), found[2].HashKey())
+
+	wildcards, err := rg.AllEntrypointsForWildcardSubject(ctx, "user", rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#viewer[] wildcard",
+		"RELATION_ENTRYPOINT group#member[] wildcard",
+	}, entrypointStrings(wildcards))
+
+	wildcards, err = rg.AllEntrypointsForWildcardSubject(ctx, "user", rr("document", "editor"))
+	require.NoError(err)
+	require.Empty(wildcards)
+
+	_, err = rg.AllEntrypointsForWildcardSubject(ctx, "unknown", rr("document", "view"))
+	require.Error(err)
+}
+
+func TestReachabilityEntrypointKind(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		permission view = viewer + editor + org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "viewer"))
+	require.NoError(err)
+	require.Len(found, 1)
+	require.Equal(RelationEntrypointKind, found[0].Kind())
+	require.Equal("relation", found[0].Kind().String())
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "editor"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+	require.Equal(SubjectRelationEntrypointKind, found[0].Kind())
+	require.Equal("subject-relation", found[0].Kind().String())
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+	require.Equal(ArrowEntrypointKind, found[0].Kind())
+	require.Equal("arrow", found[0].Kind().String())
+	require.Equal(core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT, found[0].EntrypointKind())
+
+	require.True(found[0].Kind().In(RelationEntrypointKind, ArrowEntrypointKind))
+	require.False(found[0].Kind().In(RelationEntrypointKind, SubjectRelationEntrypointKind))
+	require.False(found[0].Kind().In())
+	require.Equal("EntrypointKind(42)", EntrypointKind(42).String())
+}
+
+func TestReachabilityGraphGroupedEntrypoints(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+		permission manage = admin
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | organization#admin
+		relation editor: organization#admin
+		permission view = viewer + editor + org->admin + org->manage
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+	grouped, err := rg.GroupedEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+
+	entrypointStrings := func(entrypoints []ReachabilityEntrypoint) []string {
+		strs := make([]string, 0, len(entrypoints))
+		for _, entrypoint := range entrypoints {
+			strs = append(strs, entrypoint.String())
+		}
+		return strs
+	}
+
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#editor[]",
+		"RELATION_ENTRYPOINT document#viewer[]",
+	}, entrypointStrings(grouped.Relation))
+	require.Equal([]string{
+		"TUPLESET_TO_USERSET_ENTRYPOINT document#view[0.1]",
+	}, entrypointStrings(grouped.Arrow))
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT organization#manage[0]",
+	}, entrypointStrings(grouped.SubjectRelation))
+
+	// The groups hold exactly the entrypoints of the full walk.
+	all, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(all, len(grouped.Relation)+len(grouped.Arrow)+len(grouped.SubjectRelation))
+
+	// A subject without entrypoints results in empty groups.
+	grouped, err = rg.GroupedEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "org"))
+	require.NoError(err)
+	require.Empty(grouped.Relation)
+	require.Empty(grouped.Arrow)
+	require.Empty(grouped.SubjectRelation)
+}
+
+func TestReachabilityGraphContainingKindFilters(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | organization#admin
+		permission view = viewer + org->admin
+	}`, "document")
+
+	testCases := []struct {
+		name     string
+		options  []ReachabilityGraphOption
+		expected []string
+	}{
+		{
+			"no filter",
+			nil,
+			[]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+		{
+			"relations only",
+			[]ReachabilityGraphOption{WithRelationsOnly()},
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+		{
+			"permissions only",
+			[]ReachabilityGraphOption{WithPermissionsOnly()},
+			[]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+			},
+		},
+		{
+			"last filter applies",
+			[]ReachabilityGraphOption{WithPermissionsOnly(), WithRelationsOnly()},
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			rg := reachabilityGraphForTest(t, rts, tc.options...)
+
+			found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+			require.NoError(err)
+
+			foundStrings := make([]string, 0, len(found))
+			for _, entrypoint := range found {
+				foundStrings = append(foundStrings, entrypoint.String())
+			}
+			require.Equal(tc.expected, foundStrings)
+
+			streamed := []string{}
+			err = rg.ForEachEntrypoint(ctx, rr("organization", "admin"), rr("document", "view"), func(entrypoint ReachabilityEntrypoint) error {
+				streamed = append(streamed, entrypoint.String())
+				return nil
+			})
+			require.NoError(err)
+			require.ElementsMatch(tc.expected, streamed)
+		})
+	}
+}
+
+func TestReachabilityGraphDirectResultsOnly(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+		relation banned: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		relation reviewer: user
+		permission edit = editor & org->admin
+		permission review = reviewer - org->banned
+		permission view = viewer + edit + review
+	}`, "document")
+
+	for _, subjectType := range []*core.RelationReference{rr("document", "editor"), rr("document", "reviewer"), rr("organization", "admin")} {
+		subjectType := subjectType
+		t.Run(tuple.StringRR(subjectType), func(t *testing.T) {
+			require := require.New(t)
+
+			all, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, subjectType, rr("document", "view"))
+			require.NoError(err)
+
+			// Filtering during collection must return exactly the direct results of the full walk.
+			expected := []string{}
+			for _, entrypoint := range all {
+				if entrypoint.IsDirectResult() {
+					expected = append(expected, entrypoint.String())
+				}
+			}
+
+			rg := reachabilityGraphForTest(t, rts, WithDirectResultsOnly())
+			direct, err := rg.AllEntrypointsForSubjectToResource(ctx, subjectType, rr("document", "view"))
+			require.NoError(err)
+			require.Equal(expected, entrypointStrings(direct))
+			require.Less(len(direct), len(all))
+
+			streamed := []string{}
+			err = rg.ForEachEntrypoint(ctx, subjectType, rr("document", "view"), func(entrypoint ReachabilityEntrypoint) error {
+				streamed = append(streamed, entrypoint.String())
+				return nil
+			})
+			require.NoError(err)
+			require.ElementsMatch(expected, streamed)
+		})
+	}
+}
+
+func TestReachabilityGraphExcludedRelations(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation editor: user
+		permission view = viewer + editor
+	}`, "document")
+
+	testCases := []struct {
+		name     string
+		options  []ReachabilityGraphOption
+		expected []string
+	}{
+		{
+			"no exclusions",
+			nil,
+			[]string{
+				"RELATION_ENTRYPOINT document#editor[]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#member[]",
+			},
+		},
+		{
+			"excluded permission rewrite",
+			[]ReachabilityGraphOption{WithExcludedRelations(rr("document", "editor"))},
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#member[]",
+			},
+		},
+		{
+			"excluded subject relation",
+			[]ReachabilityGraphOption{WithExcludedRelations(rr("group", "member"))},
+			[]string{
+				"RELATION_ENTRYPOINT document#editor[]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+		{
+			"excluded subject relation outside of the allowlist",
+			[]ReachabilityGraphOption{WithNamespaceAllowlist("document"), WithExcludedRelations(rr("group", "member"))},
+			[]string{
+				"RELATION_ENTRYPOINT document#editor[]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+		{
+			"excluded resource relation",
+			[]ReachabilityGraphOption{WithExcludedRelations(rr("document", "view"))},
+			[]string{},
+		},
+		{
+			"several exclusions",
+			[]ReachabilityGraphOption{WithExcludedRelations(rr("document", "editor"), rr("group", "member"))},
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			found, err := reachabilityGraphForTest(t, rts, tc.options...).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+
+			strs := make([]string, 0, len(found))
+			for _, entrypoint := range found {
+				strs = append(strs, entrypoint.String())
+			}
+			require.Equal(tc.expected, strs)
+		})
+	}
+}
+
+func TestReachabilityGraphSubjectRelation(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+		relation manager: user
+	}
+
+	definition document {
+		relation team: group
+		relation viewer: group:* | group#member
+		relation owner: group#manager
+		permission view = viewer + owner + team->member
+	}`, "document")
+
+	testCases := []struct {
+		subjectType *core.RelationReference
+		expected    []string
+	}{
+		{
+			rr("group", "member"),
+			[]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#member[]",
+			},
+		},
+		{
+			// The wildcard on the viewer relation only matches groups, not their managers.
+			rr("group", "manager"),
+			[]string{
+				"RELATION_ENTRYPOINT document#owner[]",
+			},
+		},
+		{
+			rr("group", "..."),
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[] wildcard",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tuple.StringRR(tc.subjectType), func(t *testing.T) {
+			require := require.New(t)
+
+			found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, tc.subjectType, rr("document", "view"))
+			require.NoError(err)
+
+			strs := make([]string, 0, len(found))
+			for _, entrypoint := range found {
+				strs = append(strs, entrypoint.String())
+			}
+			require.Equal(tc.expected, strs)
+
+			// The paths are consistent with the entrypoints.
+			paths, err := reachabilityGraphForTest(t, rts).EntrypointPaths(ctx, tc.subjectType, rr("document", "view"))
+			require.NoError(err)
+
+			reached := map[string]struct{}{}
+			for _, path := range paths {
+				if !path.Truncated {
+					reached[tuple.StringRR(path.Hops[0].Relation)] = struct{}{}
+				}
+			}
+
+			expectedReached := map[string]struct{}{}
+			for _, entrypoint := range found {
+				expectedReached[tuple.StringRR(entrypoint.ContainingRelationOrPermission())] = struct{}{}
+			}
+			require.Equal(expectedReached, reached)
+		})
+	}
+}
+
+func TestReachabilityGraphIsReachable(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition team {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation editor: user
+		relation group_viewer: group#member
+		permission view = viewer + editor
+	}`, "document")
+
+	testCases := []struct {
+		name         string
+		options      []ReachabilityGraphOption
+		subjectType  *core.RelationReference
+		resourceType *core.RelationReference
+		expected     bool
+	}{
+		{"direct", nil, rr("user", "..."), rr("document", "editor"), true},
+		{"through permission", nil, rr("user", "..."), rr("document", "view"), true},
+		{"subject relation", nil, rr("group", "member"), rr("document", "view"), true},
+		{"unreachable type", nil, rr("team", "..."), rr("document", "view"), false},
+		{"unreachable relation", nil, rr("group", "member"), rr("document", "editor"), false},
+		{"concurrent", []ReachabilityGraphOption{WithMaxConcurrency(4)}, rr("user", "..."), rr("document", "view"), true},
+		{"through subject relation", nil, rr("user", "..."), rr("document", "group_viewer"), true},
+		{
+			"only through boundary",
+			[]ReachabilityGraphOption{WithNamespaceAllowlist("document")},
+			rr("user", "..."),
+			rr("document", "group_viewer"),
+			false,
+		},
+		{
+			"excluded",
+			[]ReachabilityGraphOption{WithExcludedRelations(rr("document", "editor"))},
+			rr("group", "member"),
+			rr("document", "editor"),
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rg := reachabilityGraphForTest(t, rts, tc.options...)
+			reachable, err := rg.IsReachable(ctx, tc.subjectType, tc.resourceType)
+			require.NoError(err)
+			require.Equal(tc.expected, reachable)
+		})
+	}
+
+	_, err := reachabilityGraphForTest(t, rts).IsReachable(ctx, rr("unknown", "..."), rr("document", "view"))
+	require.Error(t, err)
+}
+
+func TestReachabilityGraphAreMutuallyReachable(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition folder {
+		relation viewer: user
+	}
+
+	definition document {
+		relation parent: document
+		relation folder: folder
+		relation viewer: user
+		relation editor: user
+		permission edit = editor + parent->view
+		permission view = viewer + edit + parent->view
+		permission manage = editor + parent->manage
+		permission browse = folder->viewer
+	}`, "document")
+
+	testCases := []struct {
+		name     string
+		first    *core.RelationReference
+		second   *core.RelationReference
+		expected bool
+	}{
+		{"mutual", rr("document", "edit"), rr("document", "view"), true},
+		{"mutual reversed", rr("document", "view"), rr("document", "edit"), true},
+		{"recursive", rr("document", "manage"), rr("document", "manage"), true},
+		{"one way", rr("document", "editor"), rr("document", "edit"), false},
+		{"other way", rr("document", "edit"), rr("document", "editor"), false},
+		{"unrelated", rr("document", "manage"), rr("document", "view"), false},
+		{"not recursive", rr("document", "browse"), rr("document", "browse"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			mutual, err := reachabilityGraphForTest(t, rts).AreMutuallyReachable(ctx, tc.first, tc.second)
+			require.NoError(err)
+			require.Equal(tc.expected, mutual)
+		})
+	}
+
+	_, err := reachabilityGraphForTest(t, rts).AreMutuallyReachable(ctx, rr("document", "view"), rr("folder", "unknown"))
+	require.Error(t, err)
+
+	_, err = reachabilityGraphForTest(t, rts).AreMutuallyReachable(ctx, rr("unknown", "viewer"), rr("document", "view"))
+	require.Error(t, err)
+}
+
+func TestReachabilityGraphAreMutuallyReachableAcrossNamespaces(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition folder {
+		relation viewer: user | document#viewer
+		relation owner: user
+	}
+
+	definition document {
+		relation folder: folder
+		relation viewer: user | folder#viewer
+		permission view = viewer + folder->owner
+	}`, "document")
+
+	testCases := []struct {
+		name     string
+		first    *core.RelationReference
+		second   *core.RelationReference
+		expected bool
+	}{
+		{"cycle", rr("document", "viewer"), rr("folder", "viewer"), true},
+		{"cycle reversed", rr("folder", "viewer"), rr("document", "viewer"), true},
+		{"one way through permission", rr("folder", "viewer"), rr("document", "view"), false},
+		{"one way", rr("folder", "owner"), rr("document", "view"), false},
+		{"recursive outside the graph namespace", rr("folder", "viewer"), rr("folder", "viewer"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			mutual, err := reachabilityGraphForTest(t, rts).AreMutuallyReachable(ctx, tc.first, tc.second)
+			require.NoError(err)
+			require.Equal(tc.expected, mutual)
+		})
+	}
+}
+
+func TestReachabilityGraphHasDirectEntrypoint(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+		relation banned: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		relation reviewer: user
+		permission edit = editor & org->admin
+		permission review = reviewer - org->banned
+		permission view = viewer + edit + review
+	}`, "document")
+
+	testCases := []struct {
+		name         string
+		options      []ReachabilityGraphOption
+		subjectType  *core.RelationReference
+		resourceType *core.RelationReference
+		expected     bool
+	}{
+		{"union", nil, rr("document", "viewer"), rr("document", "view"), true},
+		{"intersection", nil, rr("document", "editor"), rr("document", "edit"), false},
+		{"exclusion", nil, rr("document", "reviewer"), rr("document", "review"), false},
+		{"arrow under intersection", nil, rr("organization", "admin"), rr("document", "edit"), false},
+		{"excluded arrow", nil, rr("organization", "banned"), rr("document", "review"), false},
+		{"through permission", nil, rr("document", "edit"), rr("document", "view"), true},
+		{"relation", nil, rr("user", "..."), rr("document", "editor"), true},
+		{"unreachable", nil, rr("document", "viewer"), rr("document", "edit"), false},
+		{"concurrent", []ReachabilityGraphOption{WithMaxConcurrency(4)}, rr("document", "viewer"), rr("document", "view"), true},
+
+		// The relation entrypoint is a direct result of the editor relation, even though the
+		// relation is under an intersection in the permission.
+		{"relation under intersection", nil, rr("user", "..."), rr("document", "edit"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rg := reachabilityGraphForTest(t, rts, tc.options...)
+			direct, err := rg.HasDirectEntrypoint(ctx, tc.subjectType, tc.resourceType)
+			require.NoError(err)
+			require.Equal(tc.expected, direct)
+
+			// The result must match filtering the full walk.
+			all, err := rg.AllEntrypointsForSubjectToResource(ctx, tc.subjectType, tc.resourceType)
+			require.NoError(err)
+
+			found := false
+			for _, entrypoint := range all {
+				found = found || (!entrypoint.IsBoundary() && entrypoint.IsDirectResult())
+			}
+			require.Equal(found, direct)
+		})
+	}
+
+	_, err := reachabilityGraphForTest(t, rts).HasDirectEntrypoint(ctx, rr("unknown", "..."), rr("document", "view"))
+	require.Error(t, err)
+}
+
+func TestReachabilityGraphEntrypointsWithDepth(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition team {
+		relation member: user | group#member
+	}
+
+	definition folder {
+		relation viewer: team#member
+	}
+
+	definition document {
+		relation viewer: user | group#member | folder#viewer
+	}`, "document")
+
+	// The group members are reached both directly from the viewer and through the folder viewer
+	// and the team members, and are reported at the shallowest of the two depths.
+	expected := []string{
+		"RELATION_ENTRYPOINT document#viewer[] @ 0",
+		"RELATION_ENTRYPOINT group#member[] @ 1",
+		"RELATION_ENTRYPOINT team#member[] @ 2",
+	}
+
+	testCases := []struct {
+		name    string
+		options []ReachabilityGraphOption
+	}{
+		{"default", nil},
+		{"deduplicated", []ReachabilityGraphOption{WithDeduplication(true)}},
+		{"concurrent", []ReachabilityGraphOption{WithMaxConcurrency(4)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			// The relations are walked in map order, so walk repeatedly to cover the orders.
+			for i := 0; i < 10; i++ {
+				withDepths, err := reachabilityGraphForTest(t, rts, tc.options...).EntrypointsWithDepth(ctx, rr("user", "..."), rr("document", "viewer"))
+				require.NoError(err)
+
+				found := make([]string, 0, len(withDepths))
+				for _, withDepth := range withDepths {
+					found = append(found, fmt.Sprintf("%s @ %d", withDepth.Entrypoint, withDepth.Depth))
+				}
+				require.Equal(expected, found)
+			}
+		})
+	}
+
+	t.Run("max depth", func(t *testing.T) {
+		require := require.New(t)
+
+		withDepths, err := reachabilityGraphForTest(t, rts, WithMaxDepth(1)).EntrypointsWithDepth(ctx, rr("user", "..."), rr("document", "viewer"))
+		require.NoError(err)
+
+		found := make([]string, 0, len(withDepths))
+		for _, withDepth := range withDepths {
+			found = append(found, fmt.Sprintf("%s @ %d", withDepth.Entrypoint, withDepth.Depth))
+		}
+		require.Equal([]string{
+			"RELATION_ENTRYPOINT document#viewer[] @ 0",
+			"RELATION_ENTRYPOINT folder#viewer[] boundary team#member @ 1",
+			"RELATION_ENTRYPOINT group#member[] @ 1",
+		}, found)
+	})
+
+	_, err := reachabilityGraphForTest(t, rts).EntrypointsWithDepth(ctx, rr("user", "..."), rr("folder", "viewer"))
+	require.Error(t, err)
+}
+
+func TestReachabilityGraphMaxDepth(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		relation editor: user
+		permission view = viewer + editor
+	}`, "document")
+
+	testCases := []struct {
+		maxDepth uint16
+		expected []string
+	}{
+		{
+			0,
+			[]string{
+				"COMPUTED_USERSET_ENTRYPOINT document#view[0] boundary document#viewer",
+				"COMPUTED_USERSET_ENTRYPOINT document#view[1] boundary document#editor",
+			},
+		},
+		{
+			1,
+			[]string{
+				"RELATION_ENTRYPOINT document#editor[]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT document#viewer[] boundary group#member",
+			},
+		},
+		{
+			2,
+			[]string{
+				"RELATION_ENTRYPOINT document#editor[]",
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#member[]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		for _, maxConcurrency := range []uint16{1, 4} {
+			t.Run(fmt.Sprintf("depth %d concurrency %d", tc.maxDepth, maxConcurrency), func(t *testing.T) {
+				require := require.New(t)
+
+				rg := reachabilityGraphForTest(t, rts, WithMaxDepth(tc.maxDepth), WithMaxConcurrency(maxConcurrency))
+				found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+				require.NoError(err)
+				require.Equal(tc.expected, entrypointStrings(found))
+			})
+		}
+	}
+}
+
+func TestReachabilityGraphMaxDepthShallowestWalk(t *testing.T) {
+	require := require.New(t)
+
+	// The editor relation is found both at depth 1 from view and at depth 2 through viewer, so
+	// it must always be walked from depth 1, regardless of the order in which it is found.
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user
+	}
+
+	definition document {
+		relation viewer: user | document#editor
+		relation editor: user | group#member
+		permission view = viewer + editor
+	}`, "document")
+
+	for i := 0; i < 25; i++ {
+		found, err := reachabilityGraphForTest(t, rts, WithMaxDepth(2)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+		require.NoError(err)
+		require.Equal([]string{
+			"RELATION_ENTRYPOINT document#editor[]",
+			"RELATION_ENTRYPOINT document#viewer[]",
+			"RELATION_ENTRYPOINT group#member[]",
+		}, entrypointStrings(found))
+	}
+}
+
+func TestReachabilityGraphReachableRelations(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+		relation unused: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | group#member
+		relation banned: user
+		relation unrelated: user
+		permission view = viewer + org->admin - banned
+	}`, "document")
+
+	relations, err := reachabilityGraphForTest(t, rts).ReachableRelations(ctx, rr("document", "view"))
+	require.NoError(err)
+
+	relationStrings := make([]string, 0, len(relations))
+	for _, relation := range relations {
+		relationStrings = append(relationStrings, tuple.StringRR(relation))
+	}
+
+	require.Equal([]string{
+		"document#banned",
+		"document#view",
+		"document#viewer",
+		"group#member",
+		"organization#admin",
+	}, relationStrings)
+}
+
+func TestReachabilityGraphReverseEntrypoints(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | user:* | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+	reverse, err := rg.ReverseEntrypoints(ctx, rr("document", "view"))
+	require.NoError(err)
+
+	found := map[string][]string{}
+	for subjectNamespace, entrypoints := range reverse {
+		for _, entrypoint := range entrypoints {
+			found[subjectNamespace] = append(found[subjectNamespace], entrypoint.String())
+		}
+	}
+
+	require.Equal(map[string][]string{
+		"document": {
+			"COMPUTED_USERSET_ENTRYPOINT document#view[0]",
+		},
+		"group": {
+			"RELATION_ENTRYPOINT document#viewer[]",
+			"RELATION_ENTRYPOINT group#member[]",
+		},
+		"organization": {
+			"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+		},
+		"user": {
+			"RELATION_ENTRYPOINT document#viewer[]",
+			"RELATION_ENTRYPOINT document#viewer[] wildcard",
+			"RELATION_ENTRYPOINT group#member[]",
+			"RELATION_ENTRYPOINT organization#admin[]",
+		},
+	}, found)
+
+	// The entrypoints for a type match those of a walk from that type.
+	walked, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	walkedStrings := make([]string, 0, len(walked))
+	for _, entrypoint := range walked {
+		walkedStrings = append(walkedStrings, entrypoint.String())
+	}
+	require.Equal(found["user"], walkedStrings)
+}
+
+func TestReachabilityGraphSubjectTypesReaching(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+	definition bot {}
+	definition unused {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | group#member
+		relation public: bot:*
+		relation banned: user
+		permission view = viewer + public + org->admin - banned
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+	subjectTypes, err := rg.SubjectTypesReaching(ctx, rr("document", "view"))
+	require.NoError(err)
+
+	subjectTypeStrings := make([]string, 0, len(subjectTypes))
+	for _, subjectType := range subjectTypes {
+		subjectTypeStrings = append(subjectTypeStrings, tuple.StringRR(subjectType))
+	}
+
+	require.Equal([]string{
+		"bot#...",
+		"document#banned",
+		"document#public",
+		"document#viewer",
+		"group#member",
+		"organization#admin",
+		"user#...",
+	}, subjectTypeStrings)
+
+	// Each subject type must have entrypoints into the resource.
+	for _, subjectType := range subjectTypes {
+		found, err := rg.AllEntrypointsForSubjectToResource(ctx, subjectType, rr("document", "view"))
+		require.NoError(err)
+		require.NotEmpty(found, "expected entrypoints for %s", tuple.StringRR(subjectType))
+	}
+
+	subjectTypes, err = rg.SubjectTypesReaching(ctx, rr("document", "org"))
+	require.NoError(err)
+	require.Len(subjectTypes, 1)
+	require.Equal("organization#...", tuple.StringRR(subjectTypes[0]))
+}
+
+func TestReachabilityGraphDeduplication(t *testing.T) {
+	// Both the `group` and `group#member` subject types of the tupleset relation lead to the
+	// member relation of group, so the arrow is an entrypoint for group#member twice over.
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation parent: group | group#member
+		permission view = parent->member
+	}`, "document")
+
+	for _, maxConcurrency := range []uint16{1, 4} {
+		t.Run(fmt.Sprintf("concurrency %d", maxConcurrency), func(t *testing.T) {
+			require := require.New(t)
+
+			found, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency)).AllEntrypointsForSubjectToResource(ctx, rr("group", "member"), rr("document", "view"))
+			require.NoError(err)
+			require.Equal([]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[0]",
+				"RELATION_ENTRYPOINT group#member[]",
+			}, entrypointStrings(found))
+
+			raw, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency), WithDeduplication(false)).AllEntrypointsForSubjectToResource(ctx, rr("group", "member"), rr("document", "view"))
+			require.NoError(err)
+			require.Equal([]string{
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[0]",
+				"TUPLESET_TO_USERSET_ENTRYPOINT document#view[0]",
+				"RELATION_ENTRYPOINT group#member[]",
+			}, entrypointStrings(raw))
+		})
+	}
+}
+
+func TestReachabilityEntrypointEquality(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition document {
+		relation viewer: user
+		relation editor: user
+		permission edit = editor
+		permission view = viewer + edit + editor
+	}`, "document")
+
+	first, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("document", "editor"), rr("document", "view"))
+	require.NoError(err)
+
+	// A second graph recomputes the underlying protos.
+	second, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("document", "editor"), rr("document", "view"))
+	require.NoError(err)
+
+	require.Len(first, 2)
+	require.Len(second, 2)
+
+	keys := map[string]struct{}{}
+	for index := range first {
+		require.NotSame(first[index].re, second[index].re)
+		require.True(first[index].Equal(second[index]))
+		require.Equal(first[index].HashKey(), second[index].HashKey())
+		keys[first[index].HashKey()] = struct{}{}
+	}
+
+	// The computed userset of editor in edit and in view are distinct entrypoints.
+	require.Equal("COMPUTED_USERSET_ENTRYPOINT document#edit[0]", first[0].String())
+	require.Equal("COMPUTED_USERSET_ENTRYPOINT document#view[1]", first[1].String())
+	require.False(first[0].Equal(first[1]))
+	require.Len(keys, 2)
+
+	// The same entrypoint under a different parent relation is distinct.
+	reparented := ReachabilityEntrypoint{re: first[1].re, parentRelation: rr("document", "edit")}
+	require.False(first[1].Equal(reparented))
+	require.NotEqual(first[1].HashKey(), reparented.HashKey())
+}
+
+func TestReachabilityEntrypointAccessorErrors(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		permission view = viewer + org->admin
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	ttuEntrypoint := found[0]
+	ttu, err := ttuEntrypoint.TupleToUsersetE(rts.nsDef)
+	require.NoError(err)
+	require.Equal("org", ttu.Tupleset.Relation)
+	require.Equal(ttu, ttuEntrypoint.TupleToUserset(rts.nsDef))
+
+	_, err = ttuEntrypoint.TupleToUsersetE(&core.NamespaceDefinition{Name: "organization"})
+	require.EqualError(err, "invalid namespace definition given to TupleToUserset")
+	require.PanicsWithValue("invalid namespace definition given to TupleToUserset", func() {
+		ttuEntrypoint.TupleToUserset(&core.NamespaceDefinition{Name: "organization"})
+	})
+
+	_, err = ttuEntrypoint.DirectRelationE()
+	require.EqualError(err, "cannot call DirectRelation for kind TUPLESET_TO_USERSET_ENTRYPOINT")
+	require.Panics(func() { ttuEntrypoint.DirectRelation() })
+
+	found, err = reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "viewer"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	relation, err := found[0].DirectRelationE()
+	require.NoError(err)
+	require.Equal("document#viewer", tuple.StringRR(relation))
+
+	_, err = found[0].TupleToUsersetE(rts.nsDef)
+	require.EqualError(err, "cannot call TupleToUserset for kind RELATION_ENTRYPOINT")
+}
+
+func TestReachabilityEntrypointTuplesetRelation(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation parent_org: organization
+		relation viewer: user
+		permission view = viewer + org->admin + parent_org->admin
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 2)
+
+	tuplesets := make([]string, 0, len(found))
+	for _, entrypoint := range found {
+		tupleset, ok := entrypoint.TuplesetRelation()
+		require.True(ok)
+		require.Equal(entrypoint.TupleToUserset(rts.nsDef).Tupleset.Relation, tupleset)
+		tuplesets = append(tuplesets, tupleset)
+	}
+	sort.Strings(tuplesets)
+	require.Equal([]string{"org", "parent_org"}, tuplesets)
+
+	// Entrypoints of other kinds have no tupleset relation.
+	found, err = reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.NotEmpty(found)
+	for _, entrypoint := range found {
+		require.NotEqual(ArrowEntrypointKind, entrypoint.Kind())
+
+		tupleset, ok := entrypoint.TuplesetRelation()
+		require.False(ok)
+		require.Empty(tupleset)
+	}
+
+	// Arrow entrypoints persisted before the tupleset relation was recorded have none.
+	persisted := ReachabilityEntrypoint{re: &core.ReachabilityEntrypoint{
+		Kind: core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT,
+	}}
+	tupleset, ok := persisted.TuplesetRelation()
+	require.False(ok)
+	require.Empty(tupleset)
+}
+
+func TestReachabilityEntrypointAllowedSubjectTypes(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | user:* | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "viewer"))
+	require.NoError(err)
+	require.Len(found, 3)
+
+	for _, entrypoint := range found {
+		if entrypoint.DirectRelation().Namespace != "document" {
+			continue
+		}
+
+		allowed, err := entrypoint.AllowedSubjectTypesE(rts.nsDef)
+		require.NoError(err)
+		require.Equal(allowed, entrypoint.AllowedSubjectTypes(rts.nsDef))
+
+		allowedStrings := make([]string, 0, len(allowed))
+		for _, allowedRelation := range allowed {
+			if allowedRelation.GetPublicWildcard() != nil {
+				allowedStrings = append(allowedStrings, allowedRelation.Namespace+":*")
+				continue
+			}
+			allowedStrings = append(allowedStrings, allowedRelation.Namespace+"#"+allowedRelation.GetRelation())
+		}
+		require.Equal([]string{"user#...", "user:*", "group#member"}, allowedStrings)
+	}
+
+	_, err = found[0].AllowedSubjectTypesE(&core.NamespaceDefinition{Name: "organization"})
+	require.EqualError(err, "invalid namespace definition given to AllowedSubjectTypes")
+	require.PanicsWithValue("invalid namespace definition given to AllowedSubjectTypes", func() {
+		found[0].AllowedSubjectTypes(&core.NamespaceDefinition{Name: "organization"})
+	})
+
+	found, err = reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	_, err = found[0].AllowedSubjectTypesE(rts.nsDef)
+	require.EqualError(err, "cannot call AllowedSubjectTypes for kind TUPLESET_TO_USERSET_ENTRYPOINT")
+}
+
+func TestReachabilityEntrypointContainingExpression(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation banned: user
+		permission view = (viewer - banned) + org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+
+	// An entrypoint found directly on a relation has no containing expression.
+	found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "viewer"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	expression, err := found[0].ContainingExpression(rts.nsDef)
+	require.NoError(err)
+	require.Nil(expression)
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	expression, err = found[0].ContainingExpression(rts.nsDef)
+	require.NoError(err)
+	require.Equal("viewer", expression.GetComputedUserset().Relation)
+
+	found, err = rg.AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Len(found, 1)
+
+	expression, err = found[0].ContainingExpression(rts.nsDef)
+	require.NoError(err)
+	require.Equal("org", expression.GetTupleToUserset().Tupleset.Relation)
+	require.Equal("admin", expression.GetTupleToUserset().ComputedUserset.Relation)
+
+	_, err = found[0].ContainingExpression(&core.NamespaceDefinition{Name: "organization"})
+	require.EqualError(err, "invalid namespace definition given to ContainingExpression")
+}
+
+func TestReachabilityGraphMultipleResources(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		permission edit = editor + org->admin
+		permission view = viewer + edit
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResources(ctx, rr("user", "..."), []*core.RelationReference{
+		rr("document", "view"),
+		rr("document", "edit"),
+		rr("organization", "admin"),
+	})
+	require.NoError(err)
+	require.Len(found, 3)
+
+	verifyEntrypoints(require, found["document#view"], []rrtStruct{
+		rrt("document", "viewer", true),
+		rrt("document", "editor", true),
+		rrt("organization", "admin", true),
+	})
+	verifyEntrypoints(require, found["document#edit"], []rrtStruct{
+		rrt("document", "editor", true),
+		rrt("organization", "admin", true),
+	})
+	verifyEntrypoints(require, found["organization#admin"], []rrtStruct{
+		rrt("organization", "admin", true),
+	})
+}
+
+func TestReachabilityGraphAllResourcesReachableFrom(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition team {}
+
+	definition folder {
+		relation owner: team
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		permission view = viewer + org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+	found, err := rg.AllResourcesReachableFrom(ctx, rr("user", "..."), []string{"document", "organization", "folder"})
+	require.NoError(err)
+	require.Len(found, 3)
+
+	verifyEntrypoints(require, found["document#view"], []rrtStruct{
+		rrt("document", "viewer", true),
+		rrt("organization", "admin", true),
+	})
+	verifyEntrypoints(require, found["document#viewer"], []rrtStruct{
+		rrt("document", "viewer", true),
+	})
+	verifyEntrypoints(require, found["organization#admin"], []rrtStruct{
+		rrt("organization", "admin", true),
+	})
+
+	_, err = rg.AllResourcesReachableFrom(ctx, rr("user", "..."), []string{"unknown"})
+	require.ErrorAs(err, &ErrNamespaceNotFound{})
+}
+
+func TestReachabilityGraphCancellation(t *testing.T) {
+	require := require.New(t)
+
+	const groupCount = 50
+
+	schema := "definition user {}\n"
+	relations := ""
+	permission := make([]string, 0, groupCount)
+	for i := 0; i < groupCount; i++ {
+		schema += fmt.Sprintf("definition group%d {\n relation member: user | group%d#member\n}\n", i, i)
+		relations += fmt.Sprintf("relation rel%d: group%d#member\n", i, i)
+		permission = append(permission, fmt.Sprintf("rel%d", i))
+	}
+	schema += fmt.Sprintf("definition document {\n%s permission view = %s\n}", relations, strings.Join(permission, " + "))
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: schema},
+	}, &empty)
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel the context after a handful of namespaces have been loaded.
+	lookupCount := 0
+	lookup := func(ctx context.Context, name string) (*core.NamespaceDefinition, error) {
+		lookupCount++
+		if lookupCount == 5 {
+			cancel()
+		}
+
+		for _, def := range defs {
+			if def.Name == name {
+				return def, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown definition %s", name)
+	}
+
+	docDef := defs[len(defs)-1]
+	require.Equal("document", docDef.Name)
+
+	ts, err := BuildNamespaceTypeSystem(docDef, lookup)
+	require.NoError(err)
+
+	_, err = reachabilityGraphForTest(t, ts.AsValidated()).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.ErrorIs(err, context.Canceled)
+	require.Equal(5, lookupCount)
+}
+
+func TestReachabilityGraphReusesRootTypeSystem(t *testing.T) {
+	require := require.New(t)
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: `definition user {}
+
+		definition organization {
+			relation admin: user
+		}
+
+		definition document {
+			relation org: organization
+			relation viewer: user
+			relation editor: user
+			permission edit = editor + org->admin
+			permission view = viewer + edit
+		}`},
+	}, &empty)
+	require.NoError(err)
+
+	var lookedUp []string
+	lookup := func(ctx context.Context, name string) (*core.NamespaceDefinition, error) {
+		lookedUp = append(lookedUp, name)
+		for _, def := range defs {
+			if def.Name == name {
+				return def, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown definition %s", name)
+	}
+
+	docDef, err := lookup(context.Background(), "document")
+	require.NoError(err)
+
+	ts, err := BuildNamespaceTypeSystem(docDef, lookup)
+	require.NoError(err)
+
+	ctx := context.Background()
+
+	// Walking the relations of the document namespace never reloads it.
+	lookedUp = nil
+	found, err := reachabilityGraphForTest(t, ts.AsValidated()).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.NotContains(lookedUp, "document")
+
+	// The entrypoints are the same as when the document namespace is reloaded.
+	reloading := reachabilityGraphForTest(t, ts.AsValidated())
+	reloading.rootTypeSystem = nil
+
+	lookedUp = nil
+	reloaded, err := reloading.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Contains(lookedUp, "document")
+	require.Equal(entrypointStrings(reloaded), entrypointStrings(found))
+}
+
+func TestReachabilityGraphConcurrentWideSchema(t *testing.T) {
+	require := require.New(t)
+
+	const groupCount = 50
+
+	schema := "definition user {}\n"
+	relations := ""
+	permission := make([]string, 0, groupCount)
+	expected := make([]rrtStruct, 0, groupCount)
+	for i := 0; i < groupCount; i++ {
+		schema += fmt.Sprintf("definition group%d {\n relation member: user | group%d#member\n}\n", i, i)
+		relations += fmt.Sprintf("relation rel%d: group%d#member\n", i, i)
+		permission = append(permission, fmt.Sprintf("rel%d", i))
+		expected = append(expected, rrt(fmt.Sprintf("group%d", i), "member", true))
+	}
+	schema += fmt.Sprintf("definition document {\n%s permission view = %s\n}", relations, strings.Join(permission, " + "))
+
+	rts, ctx := buildReachabilityTypeSystem(t, schema, "document")
+
+	found, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(8)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	verifyEntrypoints(require, found, expected)
+}
+
+func TestReachabilityGraphConcurrentAcrossNamespaces(t *testing.T) {
+	require := require.New(t)
+
+	// The relations of the group namespace have rewrites, and so are decorated with operation
+	// paths when first loaded by each graph, while other graphs are walking them concurrently.
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation direct_member: user | group#member
+		relation manager: user
+		relation banned: user
+		permission member = direct_member + manager
+		permission admin = manager & direct_member
+		permission active = member - banned
+		permission everyone = member + admin + active
+	}
+
+	definition document {
+		relation members: group#member
+		relation admins: group#admin
+		relation actives: group#active
+		relation everyone: group#everyone
+		permission view = members + admins + actives + everyone
+	}`, "document")
+
+	graphs := make([]*ReachabilityGraph, 8)
+	for i := range graphs {
+		graphs[i] = reachabilityGraphForTest(t, rts, WithMaxConcurrency(8))
+	}
+
+	// Walk concurrently both within each walk and across graphs, before any walk has loaded the
+	// group namespace.
+	var wg sync.WaitGroup
+	found := make([][]ReachabilityEntrypoint, len(graphs))
+	errs := make([]error, len(graphs))
+	for i, rg := range graphs {
+		i, rg := i, rg
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found[i], errs[i] = rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+		}()
+	}
+	wg.Wait()
+
+	expected, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.NotEmpty(expected)
+
+	for i := range found {
+		require.NoError(errs[i])
+		require.Equal(entrypointStrings(expected), entrypointStrings(found[i]))
+	}
+}
+
+func TestReachabilityGraphDoesNotDecorateDefinitions(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation direct_member: user
+		relation manager: user
+		permission member = direct_member + manager
+	}
+
+	definition document {
+		relation members: group#member
+		relation viewer: user
+		permission view = viewer + members
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.NotEmpty(found)
+
+	// The walk decorates copies of the definitions, which may be shared with other type systems.
+	groupDef, err := rts.lookupNamespaceDefinition(ctx, "group")
+	require.NoError(err)
+
+	for _, def := range []*core.NamespaceDefinition{rts.nsDef, groupDef} {
+		for _, relation := range def.Relation {
+			for _, child := range relation.GetUsersetRewrite().GetUnion().GetChild() {
+				require.Empty(child.OperationPath, "expected %s#%s not to be decorated", def.Name, relation.Name)
+			}
+		}
+	}
+}
+
+func TestReachabilityGraphUndecoratableNamespace(t *testing.T) {
+	require := require.New(t)
+
+	nsDef := ns.Namespace("document",
+		ns.Relation("viewer", nil, ns.AllowedRelation("user", "...")),
+		ns.Relation("view", &core.UsersetRewrite{
+			RewriteOperation: &core.UsersetRewrite_Union{
+				Union: &core.SetOperation{Child: []*core.SetOperation_Child{{}}},
+			},
+		}),
+	)
+
+	ts, err := BuildNamespaceTypeSystem(nsDef, nil)
+	require.NoError(err)
+
+	_, err = ReachabilityGraphFor(ts.AsValidated())
+	require.ErrorContains(err, "unable to compute reachability under namespace `document`")
+	require.ErrorContains(err, "missing child for operation [0]")
+}
+
+func TestReachabilityGraphEntrypointOrder(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+		relation member: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		relation editor: user
+		permission view = org->member + (editor & viewer) + viewer + org->admin
+	}`, "document")
+
+	expected := []string{
+		"RELATION_ENTRYPOINT document#editor[]",
+		"RELATION_ENTRYPOINT document#viewer[]",
+		"RELATION_ENTRYPOINT organization#admin[]",
+		"RELATION_ENTRYPOINT organization#member[]",
+	}
+
+	for _, maxConcurrency := range []uint16{1, 4} {
+		for i := 0; i < 10; i++ {
+			found, err := reachabilityGraphForTest(t, rts, WithMaxConcurrency(maxConcurrency)).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+			require.Equal(expected, entrypointStrings(found))
+		}
+	}
+
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("document", "viewer"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"COMPUTED_USERSET_ENTRYPOINT document#view[0.0.1.1]",
+		"COMPUTED_USERSET_ENTRYPOINT document#view[0.1]",
+	}, entrypointStrings(found))
+
+	found, err = reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("organization", "admin"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal([]string{
+		"TUPLESET_TO_USERSET_ENTRYPOINT document#view[1]",
+	}, entrypointStrings(found))
+}
+
+func entrypointStrings(entrypoints []ReachabilityEntrypoint) []string {
+	rendered := make([]string, 0, len(entrypoints))
+	for _, entrypoint := range entrypoints {
+		rendered = append(rendered, entrypoint.String())
+	}
+	return rendered
+}
+
+func TestReachabilityGraphHasAnyEntrypoint(t *testing.T) {
+	schema := `definition user {}
+
+	definition team {}
+
+	definition organization {
+		relation admin: user
+		permission nobody = nil
+	}
+
+	definition document {
+		relation org: organization
+		relation parent: team
+		relation viewer: user
+		permission view = viewer
+		permission orgadmin = org->admin
+		permission parentview = parent->viewer
+		permission orgnobody = org->nobody
+		permission empty = nil
+		permission either = empty + orgadmin
+	}`
+
+	testCases := []struct {
+		relation string
+		expected bool
+	}{
+		{"viewer", true},
+		{"view", true},
+		{"orgadmin", true},
+		{"parentview", false},
+		{"orgnobody", false},
+		{"empty", false},
+		{"either", true},
+	}
+
+	rts, ctx := buildReachabilityTypeSystem(t, schema, "document")
+	for _, tc := range testCases {
+		t.Run(tc.relation, func(t *testing.T) {
+			found, err := reachabilityGraphForTest(t, rts).HasAnyEntrypoint(ctx, rr("document", tc.relation))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, found)
+		})
+	}
+}
+
+func TestReachabilityGraphEnumerationWithExcludedRelations(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: group#member
+		relation editor: user
+		permission view = viewer + editor
+		permission orgadmin = org->admin
+	}`, "document")
+
+	relationStrings := func(refs []*core.RelationReference) []string {
+		strs := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			strs = append(strs, tuple.StringRR(ref))
+		}
+		return strs
+	}
+
+	t.Run("has any entrypoint", func(t *testing.T) {
+		require := require.New(t)
+
+		found, err := reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "editor"))).HasAnyEntrypoint(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.True(found)
+
+		found, err = reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "editor"), rr("document", "viewer"))).HasAnyEntrypoint(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.False(found)
+
+		found, err = reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("organization", "admin"))).HasAnyEntrypoint(ctx, rr("document", "orgadmin"))
+		require.NoError(err)
+		require.False(found)
+
+		found, err = reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "view"))).HasAnyEntrypoint(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.False(found)
+	})
+
+	t.Run("reachable relations", func(t *testing.T) {
+		require := require.New(t)
+
+		relations, err := reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("group", "member"))).ReachableRelations(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.Equal([]string{
+			"document#editor",
+			"document#view",
+			"document#viewer",
+		}, relationStrings(relations))
+
+		relations, err = reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "view"))).ReachableRelations(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.Empty(relations)
+	})
+
+	t.Run("subject types reaching", func(t *testing.T) {
+		require := require.New(t)
+
+		rg := reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "editor"), rr("group", "member")))
+		subjectTypes, err := rg.SubjectTypesReaching(ctx, rr("document", "view"))
+		require.NoError(err)
+
+		// The excluded relations still have entrypoints into the walked relations, but the user
+		// type is only found by walking them.
+		require.Equal([]string{
+			"document#editor",
+			"document#viewer",
+			"group#member",
+		}, relationStrings(subjectTypes))
+
+		for _, subjectType := range subjectTypes {
+			found, err := rg.AllEntrypointsForSubjectToResource(ctx, subjectType, rr("document", "view"))
+			require.NoError(err)
+			require.NotEmpty(found, "expected entrypoints for %s", tuple.StringRR(subjectType))
+		}
+	})
+
+	t.Run("reverse entrypoints", func(t *testing.T) {
+		require := require.New(t)
+
+		found, err := reachabilityGraphForTest(t, rts, WithExcludedRelations(rr("document", "editor"), rr("group", "member"))).ReverseEntrypoints(ctx, rr("document", "view"))
+		require.NoError(err)
+		require.Contains(found, "document")
+		require.Contains(found, "group")
+		require.NotContains(found, "user")
+	})
+}
+
+func TestReachabilityGraphUnknownSubjectNamespace(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition team {}
+
+	definition document {
+		relation viewer: user
+		permission view = viewer
+	}`, "document")
+
+	_, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("unknown", "..."), rr("document", "view"))
+	require.Error(err)
+	require.Contains(err.Error(), "unknown subject namespace `unknown`")
+
+	// A subject namespace that exists but cannot reach the resource has no entrypoints.
+	found, err := reachabilityGraphForTest(t, rts).AllEntrypointsForSubjectToResource(ctx, rr("team", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Empty(found)
+}
+
+func TestReachabilityGraphDeletedNamespace(t *testing.T) {
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: `definition user {}
+
+		definition team {
+			relation member: user
+		}
+
+		definition organization {
+			relation admin: user | team#member
+		}
+
+		definition document {
+			relation org: organization
+			relation viewer: user
+			permission view = viewer + org->admin
+		}`},
+	}, &empty)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name                string
+		deletedNamespace    string
+		referencingRelation *core.RelationReference
+		expectedError       string
+	}{
+		{
+			"subject relation",
+			"team",
+			rr("organization", "admin"),
+			"definition `team` referenced by relation/permission `organization#admin` no longer exists",
+		},
+		{
+			"arrow",
+			"organization",
+			rr("document", "view"),
+			"definition `organization` referenced by relation/permission `document#view` no longer exists",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			lookup := func(ctx context.Context, name string) (*core.NamespaceDefinition, error) {
+				for _, def := range defs {
+					if def.Name == name && name != tc.deletedNamespace {
+						return def, nil
+					}
+				}
+				return nil, datastore.NewNamespaceNotFoundErr(name)
+			}
+
+			ts, err := BuildNamespaceTypeSystem(defs[len(defs)-1], lookup)
+			require.NoError(err)
+
+			_, err = reachabilityGraphForTest(t, ts.AsValidated()).AllEntrypointsForSubjectToResource(context.Background(), rr("user", "..."), rr("document", "view"))
+			require.Error(err)
+			require.Equal(tc.expectedError, err.Error())
+
+			var referencedErr ErrReferencedNamespaceNotFound
+			require.True(errors.As(err, &referencedErr))
+			require.Equal(tc.deletedNamespace, referencedErr.NotFoundNamespaceName())
+			require.Equal(tuple.StringRR(tc.referencingRelation), tuple.StringRR(referencedErr.ReferencingRelation()))
+
+			var notFoundErr ErrNamespaceNotFound
+			require.True(errors.As(err, &notFoundErr))
+			require.Equal(tc.deletedNamespace, notFoundErr.NotFoundNamespaceName())
+
+			// The error returned by the lookup remains available.
+			require.True(errors.As(err, &datastore.ErrNamespaceNotFound{}))
+		})
+	}
+}
+
+func TestReachabilityGraphFromDefinitions(t *testing.T) {
+	require := require.New(t)
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: `definition user {}
+
+		definition organization {
+			relation admin: user
+		}
+
+		definition document {
+			relation org: organization
+			relation viewer: user
+			permission view = viewer + org->admin
+		}`},
+	}, &empty)
+	require.NoError(err)
+
+	rg, err := ReachabilityGraphFromDefinitions(defs, "document", WithMaxConcurrency(2))
+	require.NoError(err)
+	require.Equal(uint16(2), rg.maxConcurrency)
+
+	found, err := rg.AllEntrypointsForSubjectToResource(context.Background(), rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	strs := make([]string, 0, len(found))
+	for _, entrypoint := range found {
+		strs = append(strs, entrypoint.String())
+	}
+	require.Equal([]string{
+		"RELATION_ENTRYPOINT document#viewer[]",
+		"RELATION_ENTRYPOINT organization#admin[]",
+	}, strs)
+
+	// A subject namespace missing from the definitions is unknown.
+	_, err = rg.AllEntrypointsForSubjectToResource(context.Background(), rr("team", "..."), rr("document", "view"))
+	require.True(errors.As(err, &ErrNamespaceNotFound{}))
+
+	// The target namespace must be found in the definitions.
+	_, err = ReachabilityGraphFromDefinitions(defs, "folder")
+	require.True(errors.As(err, &ErrNamespaceNotFound{}))
+	require.Equal("object definition `folder` not found", err.Error())
+
+	// The target namespace must be valid against the definitions.
+	_, err = ReachabilityGraphFromDefinitions(defs[1:], "document")
+	require.Error(err)
+}
+
+func TestReachabilityGraphAtRevision(t *testing.T) {
+	req := require.New(t)
+
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	req.NoError(err)
+
+	ctx := context.Background()
+	writeSchema := func(schema string) datastore.Revision {
+		empty := ""
+		defs, err := compiler.Compile([]compiler.InputSchema{
+			{Source: input.Source("schema"), SchemaString: schema},
+		}, &empty)
+		req.NoError(err)
+
+		revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteNamespaces(defs...)
+		})
+		req.NoError(err)
+		return revision
+	}
+
+	initial := writeSchema(`definition user {}
+
+	definition group {
+		relation member: user
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		permission view = viewer
+	}`)
+
+	// Only the group namespace changes, so the difference is only seen by walking into it.
+	updated := writeSchema(`definition user {}
+
+	definition group {
+		relation admin: user
+		relation direct_member: user
+		permission member = direct_member + admin
+	}
+
+	definition document {
+		relation viewer: user | group#member
+		permission view = viewer
+	}`)
+
+	testCases := []struct {
+		name     string
+		revision datastore.Revision
+		expected []string
+	}{
+		{
+			"initial",
+			initial,
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#member[]",
+			},
+		},
+		{
+			"updated",
+			updated,
+			[]string{
+				"RELATION_ENTRYPOINT document#viewer[]",
+				"RELATION_ENTRYPOINT group#admin[]",
+				"RELATION_ENTRYPOINT group#direct_member[]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rg, err := ReachabilityGraphAtRevision(ctx, ds, tc.revision, "document")
+			require.NoError(err)
+
+			found, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+			require.NoError(err)
+
+			strs := entrypointStrings(found)
+			sort.Strings(strs)
+			require.Equal(tc.expected, strs)
+		})
+	}
+
+	// The target namespace must exist at the revision.
+	_, err = ReachabilityGraphAtRevision(ctx, ds, updated, "folder")
+	req.True(errors.As(err, &ErrNamespaceNotFound{}))
+}
+
+func TestReachabilityGraphRecursiveMembership(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation direct_member: user | group#member
+		relation admin: user
+		permission member = direct_member + admin
+	}
+
+	definition document {
+		relation viewer: group#member
+		permission view = viewer
+	}`, "document")
+
+	// Nested groups reach group#member again, which is skipped rather than walked for each
+	// level of nesting, without pruning any of its entrypoints.
+	found, diagnostics, err := reachabilityGraphForTest(t, rts).WalkDiagnosticsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+	require.Equal(3, diagnostics.MaxObservedDepth)
+	verifyEntrypoints(require, found, []rrtStruct{
+		rrt("group", "admin", true),
+		rrt("group", "direct_member", true),
+	})
+
+	require.Len(diagnostics.Cycles, 1)
+	require.Equal("group#member", relationRefKey(diagnostics.Cycles[0].Relation))
+	require.True(diagnostics.Cycles[0].IsCycle)
+}
+
+func TestReachabilityGraphRecursiveMembershipFromSubjectRelation(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition document {
+		relation viewer: group#member
+		permission view = viewer
+	}`, "document")
+
+	// The recursive relation is walked once, is skipped when reached again from itself, and
+	// still returns the entrypoint by which nested groups are reached.
+	found, diagnostics, err := reachabilityGraphForTest(t, rts).WalkDiagnosticsForSubjectToResource(ctx, rr("group", "member"), rr("document", "view"))
+	require.NoError(err)
+	require.Equal(2, diagnostics.MaxObservedDepth)
+	require.Len(diagnostics.Cycles, 1)
+	require.True(diagnostics.Cycles[0].IsCycle)
+	verifyEntrypoints(require, found, []rrtStruct{
+		rrt("document", "viewer", true),
+		rrt("group", "member", true),
+	})
+}
+
+func TestEntrypointCollectorGrowCollected(t *testing.T) {
+	require := require.New(t)
+
+	ec := &entrypointCollector{collected: []ReachabilityEntrypoint{}}
+	ec.growCollected(3)
+	require.Equal(0, len(ec.collected))
+	require.Equal(3, cap(ec.collected))
+
+	ec.collected = append(ec.collected, ReachabilityEntrypoint{}, ReachabilityEntrypoint{})
+	ec.growCollected(1)
+	require.Equal(3, cap(ec.collected), "the capacity suffices")
+
+	ec.growCollected(2)
+	require.Equal(2, len(ec.collected))
+	require.Equal(6, cap(ec.collected), "the capacity is doubled")
+
+	ec.growCollected(10)
+	require.Equal(2, len(ec.collected))
+	require.Equal(12, cap(ec.collected))
+}
+
+func BenchmarkReachabilityGraphWideSchema(b *testing.B) {
+	const arrowCount = 500
+
+	relations := make([]string, 0, arrowCount)
+	arrows := make([]string, 0, arrowCount)
+	for i := 0; i < arrowCount; i++ {
+		relations = append(relations, fmt.Sprintf("relation parent%d: folder", i))
+		arrows = append(arrows, fmt.Sprintf("parent%d->viewer", i))
+	}
+
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: fmt.Sprintf(`definition user {}
+
+		definition folder {
+			relation viewer: user
+		}
+
+		definition document {
+			%s
+			permission view = %s
+		}`, strings.Join(relations, "\n"), strings.Join(arrows, " + "))},
+	}, &empty)
+	require.NoError(b, err)
+
+	rg, err := ReachabilityGraphFromDefinitions(defs, "document")
+	require.NoError(b, err)
+
+	// Walking from `folder#viewer` adds the entrypoints of all of the arrows at once.
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entrypoints, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("folder", "viewer"), rr("document", "view"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(entrypoints) != arrowCount {
+			b.Fatalf("expected %d entrypoints, found %d", arrowCount, len(entrypoints))
+		}
+	}
+}
+
+func TestReachabilityGraphTracing(t *testing.T) {
+	require := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user
+		permission view = viewer + org->admin
+	}`, "document")
+
+	rg := reachabilityGraphForTest(t, rts)
+	entrypoints, err := rg.AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	spanAttributes := map[string][]map[attribute.Key]attribute.Value{}
+	for _, span := range recorder.Ended() {
+		attributes := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			attributes[kv.Key] = kv.Value
+		}
+		spanAttributes[span.Name()] = append(spanAttributes[span.Name()], attributes)
+	}
+
+	require.Len(spanAttributes["EntrypointsForSubjectToResource"], 1)
+	walk := spanAttributes["EntrypointsForSubjectToResource"][0]
+	require.Equal("document", walk[resourceNamespaceKey].AsString())
+	require.Equal("view", walk[resourceRelationKey].AsString())
+	require.Equal("user#...", walk[subjectTypeKey].AsString())
+	require.Equal(int64(len(entrypoints)), walk[entrypointCountKey].AsInt64())
+
+	// The permission, the relation and the arrowed relation are each walked.
+	require.Len(spanAttributes["collectEntrypoints"], 3)
+
+	require.Len(spanAttributes["loadNamespace"], 1)
+	require.Equal("organization", spanAttributes["loadNamespace"][0][namespaceNameKey].AsString())
+}
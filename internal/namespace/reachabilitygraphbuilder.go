@@ -12,6 +12,12 @@ type reachabilityOption int
 const (
 	reachabilityFull reachabilityOption = iota
 	reachabilityOptimized
+
+	// reachabilityCanonical computes the full graph, but keys entrypoints reached through an
+	// alias-only permission (e.g. `permission view = viewer`) by the aliased relation instead.
+	// Only the relations referenced by a rewrite are resolved: the relation whose graph is being
+	// computed is never replaced, so an alias still has its own entrypoints.
+	reachabilityCanonical
 )
 
 func computeReachability(ctx context.Context, ts *TypeSystem, relationName string, option reachabilityOption) (*core.ReachabilityGraph, error) {
@@ -87,7 +93,12 @@ func computeRewriteOpReachability(ctx context.Context, children []*core.SetOpera
 
 		case *core.SetOperation_Child_ComputedUserset:
 			// A computed userset adds an entrypoint indicating that the relation is rewritten.
-			addSubjectEntrypoint(graph, ts.nsDef.Name, child.ComputedUserset.Relation, &core.ReachabilityEntrypoint{
+			computedRelation := child.ComputedUserset.Relation
+			if option == reachabilityCanonical {
+				computedRelation = resolveAliasedRelation(ts, computedRelation)
+			}
+
+			addSubjectEntrypoint(graph, ts.nsDef.Name, computedRelation, &core.ReachabilityEntrypoint{
 				Kind:           core.ReachabilityEntrypoint_COMPUTED_USERSET_ENTRYPOINT,
 				TargetRelation: rr,
 				OperationPath:  childOneof.OperationPath,
@@ -141,15 +152,21 @@ func computeRewriteOpReachability(ctx context.Context, children []*core.SetOpera
 				// Check if the relation does exist on the allowed type, and only add the entrypoint if present.
 				relTypeSystem, err := ts.typeSystemForNamespace(ctx, allowedRelationType.Namespace)
 				if err != nil {
-					return err
+					return wrapReferencedNamespaceNotFound(err, rr)
 				}
 
 				if relTypeSystem.HasRelation(computedUsersetRelation) {
-					addSubjectEntrypoint(graph, allowedRelationType.Namespace, computedUsersetRelation, &core.ReachabilityEntrypoint{
-						Kind:           core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT,
-						TargetRelation: rr,
-						OperationPath:  childOneof.OperationPath,
-						ResultStatus:   operationResultState,
+					entrypointRelation := computedUsersetRelation
+					if option == reachabilityCanonical {
+						entrypointRelation = resolveAliasedRelation(relTypeSystem, entrypointRelation)
+					}
+
+					addSubjectEntrypoint(graph, allowedRelationType.Namespace, entrypointRelation, &core.ReachabilityEntrypoint{
+						Kind:             core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT,
+						TargetRelation:   rr,
+						OperationPath:    childOneof.OperationPath,
+						ResultStatus:     operationResultState,
+						TuplesetRelation: tuplesetRelation,
 					})
 				}
 			}
@@ -166,6 +183,37 @@ func computeRewriteOpReachability(ctx context.Context, children []*core.SetOpera
 	return nil
 }
 
+// resolveAliasedRelation follows the chain of alias-only permissions starting at the given
+// relation, returning the name of the first relation or permission which is not an alias. A
+// permission is an alias if its rewrite is a union of a single computed userset. It is applied to
+// the relations referenced by a rewrite, and never to the relation which holds the rewrite.
+func resolveAliasedRelation(ts *TypeSystem, relationName string) string {
+	seen := map[string]struct{}{}
+	for {
+		if _, ok := seen[relationName]; ok {
+			return relationName
+		}
+		seen[relationName] = struct{}{}
+
+		relation, ok := ts.relationMap[relationName]
+		if !ok {
+			return relationName
+		}
+
+		union := relation.GetUsersetRewrite().GetUnion()
+		if union == nil || len(union.Child) != 1 {
+			return relationName
+		}
+
+		computed := union.Child[0].GetComputedUserset()
+		if computed == nil {
+			return relationName
+		}
+
+		relationName = computed.Relation
+	}
+}
+
 func addSubjectEntrypoint(graph *core.ReachabilityGraph, namespaceName string, relationName string, entrypoint *core.ReachabilityEntrypoint) {
 	key := relationKey(namespaceName, relationName)
 	if relationName == "" {
@@ -0,0 +1,65 @@
+package namespace
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prometheusNamespace = "spicedb"
+	prometheusSubsystem = "reachability"
+
+	resourceNamespaceLabel = "resource_namespace"
+)
+
+var (
+	namespacesLoadedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "namespaces_loaded_total",
+		Help:      "number of namespaces loaded to compute reachability graphs.",
+	}, []string{resourceNamespaceLabel})
+
+	walkDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "walk_duration_seconds",
+		Help:      "distribution in seconds of time spent walking the reachability graph to collect entrypoints.",
+		Buckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1},
+	}, []string{resourceNamespaceLabel})
+
+	cacheHitsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "cache_hits_total",
+		Help:      "number of reachability graphs found in the reachability graph cache.",
+	}, []string{resourceNamespaceLabel})
+
+	cacheMissesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "cache_misses_total",
+		Help:      "number of reachability graphs not found in the reachability graph cache.",
+	}, []string{resourceNamespaceLabel})
+)
+
+// RegisterReachabilityMetrics registers the metrics collected when walking reachability graphs
+// with the given registerer. The metrics are labeled by the namespace of the resource for which
+// the ReachabilityGraph was created.
+//
+// Metrics are not registered by default.
+func RegisterReachabilityMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		namespacesLoadedCounter,
+		walkDurationHistogram,
+		cacheHitsCounter,
+		cacheMissesCounter,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return fmt.Errorf("unable to register reachability metric: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReachabilityMetrics(t *testing.T) {
+	require := require.New(t)
+
+	registry := prometheus.NewRegistry()
+	require.NoError(RegisterReachabilityMetrics(registry))
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition metricsdoc {
+		relation org: organization
+		relation viewer: user
+		permission view = viewer + org->admin
+	}`, "metricsdoc")
+
+	cache, err := NewReachabilityGraphCache(nil)
+	require.NoError(err)
+	defer cache.Close()
+
+	loadedBefore := testutil.ToFloat64(namespacesLoadedCounter.WithLabelValues("metricsdoc"))
+	missesBefore := testutil.ToFloat64(cacheMissesCounter.WithLabelValues("metricsdoc"))
+	hitsBefore := testutil.ToFloat64(cacheHitsCounter.WithLabelValues("metricsdoc"))
+	walksBefore := walkSampleCount(t, registry, "metricsdoc")
+
+	for i := 0; i < 2; i++ {
+		_, err := reachabilityGraphForWithCacheTest(t, rts, cache, decimal.Zero).AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("metricsdoc", "view"))
+		require.NoError(err)
+		cache.c.Wait()
+	}
+
+	// The first walk computes each of the three relations walked, loading only the organization
+	// namespace as the graph reuses its own type system, and the second is entirely served from
+	// the cache.
+	require.Equal(float64(1), testutil.ToFloat64(namespacesLoadedCounter.WithLabelValues("metricsdoc"))-loadedBefore)
+	require.Equal(float64(3), testutil.ToFloat64(cacheMissesCounter.WithLabelValues("metricsdoc"))-missesBefore)
+	require.Equal(float64(3), testutil.ToFloat64(cacheHitsCounter.WithLabelValues("metricsdoc"))-hitsBefore)
+	require.Equal(uint64(2), walkSampleCount(t, registry, "metricsdoc")-walksBefore)
+}
+
+func walkSampleCount(t *testing.T, registry *prometheus.Registry, resourceNamespace string) uint64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "spicedb_reachability_walk_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.Metric {
+			if metric.Label[0].GetValue() == resourceNamespace {
+				return metric.Histogram.GetSampleCount()
+			}
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,215 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ReachabilityHop is a single hop in a ReachabilityPath.
+type ReachabilityHop struct {
+	// Relation is the relation or permission reached by this hop.
+	Relation *core.RelationReference
+
+	// Kind is the kind of the entrypoint by which the relation is reached, either from the
+	// subject (for the first hop of a path) or from the relation of the previous hop.
+	Kind core.ReachabilityEntrypoint_ReachabilityEntrypointKind
+
+	// IsDirectResult is true if the entrypoint by which the relation is reached is not contained
+	// under an intersection or exclusion; see ReachabilityEntrypoint.IsDirectResult.
+	IsDirectResult bool
+}
+
+// ReachabilityPath is a chain of relations walked from a subject type to a resource relation.
+type ReachabilityPath struct {
+	// Hops are the hops of the path, ordered from the subject to the resource relation.
+	Hops []ReachabilityHop
+
+	// Truncated is true if the path could not be extended further towards the subject because
+	// the next relation was already found on the path. If true, Revisited holds that relation.
+	Truncated bool
+
+	// Revisited is the relation which was already found on the path, if Truncated.
+	Revisited *core.RelationReference
+
+	// Boundary is the relation from which the path would continue towards the subject, if the
+	// walk did not enter it because its namespace is outside of the namespace allowlist or because
+	// the path reached the maximum depth; see WithNamespaceAllowlist and WithMaxDepth. A boundary
+	// path is not reached by the subject itself.
+	Boundary *core.RelationReference
+}
+
+// String returns a human-readable form of the path, e.g.
+// `organization#admin (RELATION_ENTRYPOINT) -> document#view (TUPLESET_TO_USERSET_ENTRYPOINT)`.
+func (rp ReachabilityPath) String() string {
+	parts := make([]string, 0, len(rp.Hops)+1)
+	if rp.Truncated {
+		parts = append(parts, fmt.Sprintf("[revisited %s]", tuple.StringRR(rp.Revisited)))
+	}
+	if rp.Boundary != nil {
+		parts = append(parts, fmt.Sprintf("[boundary %s]", tuple.StringRR(rp.Boundary)))
+	}
+
+	for _, hop := range rp.Hops {
+		parts = append(parts, fmt.Sprintf("%s (%s)", tuple.StringRR(hop.Relation), hop.Kind))
+	}
+
+	return strings.Join(parts, " -> ")
+}
+
+// EntrypointPaths returns all the paths of relations walked from the given subject type to the
+// given resource type. Paths that revisit a relation are truncated at the revisit.
+//
+// The walk is shaped by WithMaxDepth, WithNamespaceAllowlist and WithExcludedRelations as walks
+// collecting entrypoints are: excluded relations are pruned, and a path stopping at a relation
+// which is not walked is returned with that relation as its Boundary. The options filtering the
+// returned entrypoints (WithRelationsOnly, WithPermissionsOnly and WithDirectResultsOnly) do not
+// apply to paths.
+func (rg *ReachabilityGraph) EntrypointPaths(
+	ctx context.Context,
+	subjectType *core.RelationReference,
+	resourceType *core.RelationReference,
+) ([]ReachabilityPath, error) {
+	if resourceType.Namespace != rg.ts.nsDef.Name {
+		return nil, fmt.Errorf("gave mismatching namespace name for resource type to reachability graph")
+	}
+
+	if err := rg.validateSubjectType(ctx, subjectType); err != nil {
+		return nil, err
+	}
+
+	if rg.isRelationExcluded(relationKey(resourceType.Namespace, resourceType.Relation)) {
+		return nil, nil
+	}
+
+	pc := &pathCollector{
+		subjectType: subjectType,
+		graphs:      map[string]*core.ReachabilityGraph{},
+		onPath:      map[string]struct{}{},
+	}
+
+	if err := rg.collectPaths(ctx, pc, resourceType, nil); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(pc.paths, func(i, j int) bool {
+		return pc.paths[i].String() < pc.paths[j].String()
+	})
+	return pc.paths, nil
+}
+
+// pathCollector holds the state of a single walk enumerating paths.
+type pathCollector struct {
+	subjectType *core.RelationReference
+	graphs      map[string]*core.ReachabilityGraph
+	onPath      map[string]struct{}
+	paths       []ReachabilityPath
+}
+
+// collectPaths collects the paths reaching the given relation. The suffix holds the hops from
+// the relation to the resource relation at which the walk started, so its length is the depth of
+// the relation.
+func (rg *ReachabilityGraph) collectPaths(
+	ctx context.Context,
+	pc *pathCollector,
+	relation *core.RelationReference,
+	suffix []ReachabilityHop,
+) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key := relationKey(relation.Namespace, relation.Relation)
+	g, ok := pc.graphs[key]
+	if !ok {
+		computed, err := rg.reachabilityGraphFor(ctx, relation, reachabilityFull)
+		if err != nil {
+			return err
+		}
+
+		g = computed
+		pc.graphs[key] = g
+	}
+
+	pc.onPath[key] = struct{}{}
+	defer delete(pc.onPath, key)
+
+	addPaths := func(entrypoints *core.ReachabilityEntrypoints) {
+		for _, entrypoint := range entrypoints.Entrypoints {
+			hops := make([]ReachabilityHop, 0, len(suffix)+1)
+			hops = append(hops, newReachabilityHop(relation, entrypoint))
+			hops = append(hops, suffix...)
+			pc.paths = append(pc.paths, ReachabilityPath{Hops: hops})
+		}
+	}
+
+	// Add paths for the subject reaching this relation directly. Wildcards only match types.
+	if entrypoints, ok := g.EntrypointsBySubjectType[pc.subjectType.Namespace]; ok && pc.subjectType.Relation == tuple.Ellipsis {
+		addPaths(entrypoints)
+	}
+
+	if entrypoints, ok := g.EntrypointsBySubjectRelation[relationKey(pc.subjectType.Namespace, pc.subjectType.Relation)]; ok {
+		addPaths(entrypoints)
+	}
+
+	// Walk the subject relations in a stable order.
+	subjectRelationKeys := make([]string, 0, len(g.EntrypointsBySubjectRelation))
+	for subjectRelationKey := range g.EntrypointsBySubjectRelation {
+		subjectRelationKeys = append(subjectRelationKeys, subjectRelationKey)
+	}
+	sort.Strings(subjectRelationKeys)
+
+	atMaxDepth := rg.isAtMaxDepth(len(suffix))
+	for _, subjectRelationKey := range subjectRelationKeys {
+		entrypointSet := g.EntrypointsBySubjectRelation[subjectRelationKey]
+		if entrypointSet.SubjectRelation == nil || entrypointSet.SubjectRelation.Relation == tuple.Ellipsis {
+			continue
+		}
+
+		if rg.isRelationExcluded(subjectRelationKey) {
+			continue
+		}
+
+		isBoundary := atMaxDepth || !rg.isNamespaceAllowed(entrypointSet.SubjectRelation.Namespace)
+		for _, entrypoint := range entrypointSet.Entrypoints {
+			childSuffix := make([]ReachabilityHop, 0, len(suffix)+1)
+			childSuffix = append(childSuffix, newReachabilityHop(relation, entrypoint))
+			childSuffix = append(childSuffix, suffix...)
+
+			if isBoundary {
+				pc.paths = append(pc.paths, ReachabilityPath{
+					Hops:     childSuffix,
+					Boundary: entrypointSet.SubjectRelation,
+				})
+				continue
+			}
+
+			if _, ok := pc.onPath[subjectRelationKey]; ok {
+				pc.paths = append(pc.paths, ReachabilityPath{
+					Hops:      childSuffix,
+					Truncated: true,
+					Revisited: entrypointSet.SubjectRelation,
+				})
+				continue
+			}
+
+			if err := rg.collectPaths(ctx, pc, entrypointSet.SubjectRelation, childSuffix); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func newReachabilityHop(relation *core.RelationReference, entrypoint *core.ReachabilityEntrypoint) ReachabilityHop {
+	return ReachabilityHop{
+		Relation:       relation,
+		Kind:           entrypoint.Kind,
+		IsDirectResult: entrypoint.ResultStatus == core.ReachabilityEntrypoint_DIRECT_OPERATION_RESULT,
+	}
+}
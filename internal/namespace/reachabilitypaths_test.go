@@ -0,0 +1,138 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func TestReachabilityGraphEntrypointPaths(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	paths, err := reachabilityGraphForTest(t, rts).EntrypointPaths(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	rendered := make([]string, 0, len(paths))
+	for _, path := range paths {
+		rendered = append(rendered, path.String())
+	}
+
+	require.Equal([]string{
+		"[revisited group#member] -> group#member (RELATION_ENTRYPOINT) -> document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+		"document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+		"group#member (RELATION_ENTRYPOINT) -> document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+		"organization#admin (RELATION_ENTRYPOINT) -> document#view (TUPLESET_TO_USERSET_ENTRYPOINT)",
+	}, rendered)
+
+	require.True(paths[0].Truncated)
+	require.Equal("group#member", relationRefKey(paths[0].Revisited))
+	for _, path := range paths[1:] {
+		require.False(path.Truncated)
+		require.Equal("document#view", relationRefKey(path.Hops[len(path.Hops)-1].Relation))
+	}
+}
+
+func TestReachabilityGraphEntrypointPathsWithWalkOptions(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user | group#member
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	tcs := []struct {
+		name          string
+		resourceType  *core.RelationReference
+		option        ReachabilityGraphOption
+		expectedPaths []string
+	}{
+		{
+			"excluded relations",
+			rr("document", "view"),
+			WithExcludedRelations(rr("group", "member")),
+			[]string{
+				"document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+				"organization#admin (RELATION_ENTRYPOINT) -> document#view (TUPLESET_TO_USERSET_ENTRYPOINT)",
+			},
+		},
+		{
+			"excluded resource type",
+			rr("document", "view"),
+			WithExcludedRelations(rr("document", "view")),
+			nil,
+		},
+		{
+			"namespace allowlist",
+			rr("document", "view"),
+			WithNamespaceAllowlist("document"),
+			[]string{
+				"[boundary group#member] -> document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+				"[boundary organization#admin] -> document#view (TUPLESET_TO_USERSET_ENTRYPOINT)",
+				"document#viewer (RELATION_ENTRYPOINT) -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+			},
+		},
+		{
+			"max depth",
+			rr("document", "view"),
+			WithMaxDepth(0),
+			[]string{
+				"[boundary document#viewer] -> document#view (COMPUTED_USERSET_ENTRYPOINT)",
+				"[boundary organization#admin] -> document#view (TUPLESET_TO_USERSET_ENTRYPOINT)",
+			},
+		},
+		{
+			"max depth within recursion",
+			rr("document", "viewer"),
+			WithMaxDepth(1),
+			[]string{
+				"[boundary group#member] -> group#member (RELATION_ENTRYPOINT) -> document#viewer (RELATION_ENTRYPOINT)",
+				"document#viewer (RELATION_ENTRYPOINT)",
+				"group#member (RELATION_ENTRYPOINT) -> document#viewer (RELATION_ENTRYPOINT)",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			paths, err := reachabilityGraphForTest(t, rts, tc.option).EntrypointPaths(ctx, rr("user", "..."), tc.resourceType)
+			require.NoError(err)
+
+			var rendered []string
+			for _, path := range paths {
+				rendered = append(rendered, path.String())
+				require.False(path.Truncated)
+			}
+			require.Equal(tc.expectedPaths, rendered)
+		})
+	}
+}
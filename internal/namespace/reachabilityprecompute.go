@@ -0,0 +1,37 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// PrecomputeAllReachability computes the full reachability graph of every relation and permission
+// in the namespace of the given type system, keyed by relation name. Each graph only describes
+// how its own relation is reached, so every relation is computed exactly once, regardless of how
+// many other relations reference it.
+//
+// The graphs are protos, and so can be serialized and stored alongside the namespace definition.
+func PrecomputeAllReachability(ctx context.Context, ts *ValidatedNamespaceTypeSystem) (map[string]*core.ReachabilityGraph, error) {
+	decorated, err := buildDecoratedTypeSystem(ts.nsDef, ts.lookupNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	graphs := make(map[string]*core.ReachabilityGraph, len(decorated.nsDef.Relation))
+	for _, relation := range decorated.nsDef.Relation {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		g, err := computeReachability(ctx, decorated, relation.Name, reachabilityFull)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute reachability for `%s#%s`: %w", ts.nsDef.Name, relation.Name, err)
+		}
+
+		graphs[relation.Name] = g
+	}
+
+	return graphs, nil
+}
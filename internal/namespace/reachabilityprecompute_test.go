@@ -0,0 +1,59 @@
+package namespace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPrecomputeAllReachability(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | user:*
+		relation editor: user
+		relation banned: user
+		permission edit = editor + org->admin
+		permission view = (viewer + edit) - banned
+	}`, "document")
+
+	precomputed, err := PrecomputeAllReachability(ctx, rts)
+	require.NoError(err)
+	require.Len(precomputed, 6)
+
+	rg := reachabilityGraphForTest(t, rts)
+	for relationName, graph := range precomputed {
+		onDemand, err := rg.reachabilityGraphFor(ctx, rr("document", relationName), reachabilityFull)
+		require.NoError(err)
+		require.True(proto.Equal(onDemand, graph), "mismatched reachability for %s", relationName)
+	}
+
+	// Ensure the precomputed graphs serialize.
+	for _, graph := range precomputed {
+		_, err := proto.Marshal(graph)
+		require.NoError(err)
+	}
+}
+
+func TestPrecomputeAllReachabilityCanceled(t *testing.T) {
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition document {
+		relation viewer: user
+	}`, "document")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := PrecomputeAllReachability(canceled, rts)
+	require.ErrorIs(t, err, context.Canceled)
+}
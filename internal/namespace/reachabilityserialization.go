@@ -0,0 +1,62 @@
+package namespace
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+var deterministicMarshal = proto.MarshalOptions{Deterministic: true}
+
+// MarshalEntrypoints serializes the given entrypoints, including the relation or permission
+// containing each entrypoint and whether it is a boundary or wildcard entrypoint, such that they
+// can be restored with UnmarshalEntrypoints. The serialization is deterministic: identical
+// entrypoints in the same order always serialize to identical bytes, which can therefore be used
+// as a cache key. Sort the entrypoints with SortEntrypoints first if their order may vary.
+func MarshalEntrypoints(entrypoints []ReachabilityEntrypoint) ([]byte, error) {
+	serialized := &core.SerializedReachabilityEntrypoints{
+		Entrypoints: make([]*core.SerializedReachabilityEntrypoint, 0, len(entrypoints)),
+	}
+	for _, entrypoint := range entrypoints {
+		serialized.Entrypoints = append(serialized.Entrypoints, &core.SerializedReachabilityEntrypoint{
+			Entrypoint:       entrypoint.re,
+			ParentRelation:   entrypoint.parentRelation,
+			BoundaryRelation: entrypoint.boundaryRelation,
+			Wildcard:         entrypoint.wildcard,
+		})
+	}
+
+	marshaled, err := deterministicMarshal.Marshal(serialized)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal entrypoints: %w", err)
+	}
+
+	return marshaled, nil
+}
+
+// UnmarshalEntrypoints restores the entrypoints serialized by MarshalEntrypoints, in the same
+// order.
+func UnmarshalEntrypoints(serialized []byte) ([]ReachabilityEntrypoint, error) {
+	unmarshaled := &core.SerializedReachabilityEntrypoints{}
+	if err := proto.Unmarshal(serialized, unmarshaled); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal entrypoints: %w", err)
+	}
+
+	entrypoints := make([]ReachabilityEntrypoint, 0, len(unmarshaled.Entrypoints))
+	for _, entrypoint := range unmarshaled.Entrypoints {
+		if entrypoint.Entrypoint == nil || entrypoint.ParentRelation == nil {
+			return nil, fmt.Errorf("unable to unmarshal entrypoint: missing entrypoint or containing relation")
+		}
+
+		entrypoints = append(entrypoints, ReachabilityEntrypoint{
+			re:               entrypoint.Entrypoint,
+			parentRelation:   entrypoint.ParentRelation,
+			boundaryRelation: entrypoint.BoundaryRelation,
+			wildcard:         entrypoint.Wildcard,
+		})
+	}
+
+	return entrypoints, nil
+}
@@ -0,0 +1,81 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalEntrypointsRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	rts, ctx := buildReachabilityTypeSystem(t, `definition user {}
+
+	definition group {
+		relation member: user
+	}
+
+	definition organization {
+		relation admin: user
+	}
+
+	definition document {
+		relation org: organization
+		relation viewer: user | user:* | group#member
+		permission view = viewer + org->admin
+	}`, "document")
+
+	found, err := reachabilityGraphForTest(t, rts, WithNamespaceAllowlist("document", "organization")).
+		AllEntrypointsForSubjectToResource(ctx, rr("user", "..."), rr("document", "view"))
+	require.NoError(err)
+
+	var hasWildcard, hasBoundary bool
+	for _, entrypoint := range found {
+		hasWildcard = hasWildcard || entrypoint.IsWildcard()
+		hasBoundary = hasBoundary || entrypoint.IsBoundary()
+	}
+	require.True(hasWildcard, "expected a wildcard entrypoint")
+	require.True(hasBoundary, "expected a boundary entrypoint")
+
+	serialized, err := MarshalEntrypoints(found)
+	require.NoError(err)
+
+	serializedAgain, err := MarshalEntrypoints(found)
+	require.NoError(err)
+	require.Equal(serialized, serializedAgain)
+
+	restored, err := UnmarshalEntrypoints(serialized)
+	require.NoError(err)
+	require.Len(restored, len(found))
+	for index, entrypoint := range restored {
+		require.Equal(found[index].HashKey(), entrypoint.HashKey())
+		require.True(found[index].Equal(entrypoint))
+		require.Equal(found[index].IsDirectResult(), entrypoint.IsDirectResult())
+	}
+
+	empty, err := MarshalEntrypoints(nil)
+	require.NoError(err)
+
+	restored, err = UnmarshalEntrypoints(empty)
+	require.NoError(err)
+	require.Empty(restored)
+}
+
+func TestUnmarshalEntrypointsErrors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		serialized []byte
+	}{
+		{"truncated", []byte{0x0a, 0x05, 0x01}},
+		{"missing entrypoint", []byte{0x0a, 0x02, 0x12, 0x00}},
+		{"missing containing relation", []byte{0x0a, 0x02, 0x0a, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := UnmarshalEntrypoints(tc.serialized)
+			require.Error(t, err)
+		})
+	}
+}
@@ -90,7 +90,7 @@ func BuildNamespaceTypeSystemForDefs(nsDef *core.NamespaceDefinition, allDefs []
 			}
 		}
 
-		return nil, fmt.Errorf("unknown definition %s", nsName)
+		return nil, NewNamespaceNotFoundErr(nsName, nil)
 	})
 }
 
@@ -461,7 +461,7 @@ func (nts *TypeSystem) typeSystemForNamespace(ctx context.Context, namespaceName
 		return nts, nil
 	}
 
-	nsDef, err := nts.lookupNamespace(ctx, namespaceName)
+	nsDef, err := nts.lookupNamespaceDefinition(ctx, namespaceName)
 	if err != nil {
 		return nil, err
 	}
@@ -469,6 +469,17 @@ func (nts *TypeSystem) typeSystemForNamespace(ctx context.Context, namespaceName
 	return BuildNamespaceTypeSystem(nsDef, nts.lookupNamespace)
 }
 
+// lookupNamespaceDefinition looks up the namespace with the given name. Returns an
+// ErrNamespaceNotFound if the namespace does not exist.
+func (nts *TypeSystem) lookupNamespaceDefinition(ctx context.Context, namespaceName string) (*core.NamespaceDefinition, error) {
+	nsDef, err := nts.lookupNamespace(ctx, namespaceName)
+	if err != nil {
+		return nil, asNamespaceNotFound(err, namespaceName)
+	}
+
+	return nsDef, nil
+}
+
 // ValidatedNamespaceTypeSystem is validated type system for a namespace.
 type ValidatedNamespaceTypeSystem struct {
 	*TypeSystem
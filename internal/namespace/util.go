@@ -2,9 +2,12 @@ package namespace
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 // ReadNamespaceAndRelation checks that the specified namespace and relation exist in the
@@ -78,3 +81,39 @@ func ReadNamespaceAndTypes(
 	ts, terr := BuildNamespaceTypeSystemForDatastore(nsDef, ds)
 	return nsDef, ts, terr
 }
+
+// ParseRelationReference parses a relation reference of the form `namespace#relation`, such as
+// `document#view`. A bare `namespace` references the namespace itself, with the ellipsis relation,
+// as does a public wildcard of the form `namespace:*`, which is the subject type walked for a
+// wildcard by the reachability graph; see AllEntrypointsForWildcardSubject.
+//
+// Returns an error if the reference is malformed or if its namespace or relation name is invalid.
+// The namespace and relation are not checked to exist.
+func ParseRelationReference(s string) (*core.RelationReference, error) {
+	namespaceName, relationName := s, tuple.Ellipsis
+	switch {
+	case strings.Contains(s, "#"):
+		namespaceName, relationName, _ = strings.Cut(s, "#")
+		if relationName == "" {
+			return nil, fmt.Errorf("invalid relation reference `%s`: missing relation after `#`", s)
+		}
+
+	case strings.Contains(s, ":"):
+		var objectID string
+		namespaceName, objectID, _ = strings.Cut(s, ":")
+		if objectID != tuple.PublicWildcard {
+			return nil, fmt.Errorf("invalid relation reference `%s`: only the public wildcard `%s:%s` may follow `:`", s, namespaceName, tuple.PublicWildcard)
+		}
+	}
+
+	if namespaceName == "" {
+		return nil, fmt.Errorf("invalid relation reference `%s`: missing namespace", s)
+	}
+
+	ref := &core.RelationReference{Namespace: namespaceName, Relation: relationName}
+	if err := ref.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid relation reference `%s`: %w", s, err)
+	}
+
+	return ref, nil
+}
@@ -0,0 +1,49 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func TestParseRelationReference(t *testing.T) {
+	testCases := []struct {
+		input         string
+		expected      *core.RelationReference
+		expectedError string
+	}{
+		{"document#view", rr("document", "view"), ""},
+		{"tenant/document#view", rr("tenant/document", "view"), ""},
+		{"user", rr("user", "..."), ""},
+		{"user#...", rr("user", "..."), ""},
+		{"user:*", rr("user", "..."), ""},
+		{"", nil, "invalid relation reference ``: missing namespace"},
+		{"#view", nil, "invalid relation reference `#view`: missing namespace"},
+		{"document#", nil, "invalid relation reference `document#`: missing relation after `#`"},
+		{"user:tom", nil, "invalid relation reference `user:tom`: only the public wildcard `user:*` may follow `:`"},
+		{"user:", nil, "invalid relation reference `user:`: only the public wildcard `user:*` may follow `:`"},
+		{"Document#view", nil, "invalid relation reference `Document#view`: invalid RelationReference.Namespace"},
+		{"document#view#edit", nil, "invalid relation reference `document#view#edit`: invalid RelationReference.Relation"},
+		{"user:*#member", nil, "invalid relation reference `user:*#member`: invalid RelationReference.Namespace"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			require := require.New(t)
+
+			ref, err := ParseRelationReference(tc.input)
+			if tc.expectedError != "" {
+				require.ErrorContains(err, tc.expectedError)
+				require.Nil(ref)
+				return
+			}
+
+			require.NoError(err)
+			require.Equal(tc.expected.Namespace, ref.Namespace)
+			require.Equal(tc.expected.Relation, ref.Relation)
+		})
+	}
+}
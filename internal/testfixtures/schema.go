@@ -0,0 +1,117 @@
+package testfixtures
+
+import (
+	"fmt"
+	"math/rand"
+
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	maxGeneratedNamespaces   = 4
+	maxGeneratedRelations    = 3
+	maxGeneratedPermissions  = 4
+	maxGeneratedRewriteSize  = 3
+	maxGeneratedRewriteDepth = 3
+)
+
+// RandomNamespaceDefinitions builds randomized but structurally valid namespace definitions from
+// the given source of randomness, for use by fuzz tests. Each namespace holds relations with
+// allowed types, which may be types, subject relations or wildcards of any of the namespaces, and
+// permissions whose rewrites are trees of unions, intersections and exclusions of computed
+// usersets and arrows.
+//
+// Every computed userset and tupleset relation exists under its own namespace, but the relations
+// on the right hand side of arrows may be missing under the types allowed on their tupleset, and
+// relations and permissions may reference each other in cycles. The definitions are therefore not
+// guaranteed to pass validation.
+func RandomNamespaceDefinitions(rnd *rand.Rand) []*core.NamespaceDefinition {
+	namespaceNames := make([]string, 1+rnd.Intn(maxGeneratedNamespaces))
+	for i := range namespaceNames {
+		namespaceNames[i] = fmt.Sprintf("ns%d", i)
+	}
+
+	relationNames := make([][]string, len(namespaceNames))
+	permissionNames := make([][]string, len(namespaceNames))
+	computableNames := make([][]string, len(namespaceNames))
+	for i := range namespaceNames {
+		relationNames[i] = generatedNames("rel", 1+rnd.Intn(maxGeneratedRelations))
+		permissionNames[i] = generatedNames("perm", rnd.Intn(maxGeneratedPermissions+1))
+		computableNames[i] = append(append([]string{}, relationNames[i]...), permissionNames[i]...)
+	}
+
+	defs := make([]*core.NamespaceDefinition, 0, len(namespaceNames))
+	for i, namespaceName := range namespaceNames {
+		relations := make([]*core.Relation, 0, len(relationNames[i])+len(permissionNames[i]))
+		for _, relationName := range relationNames[i] {
+			allowed := make([]*core.AllowedRelation, 1+rnd.Intn(maxGeneratedRelations))
+			for j := range allowed {
+				target := rnd.Intn(len(namespaceNames))
+				switch rnd.Intn(4) {
+				case 0:
+					allowed[j] = ns.AllowedPublicNamespace(namespaceNames[target])
+				case 1:
+					targetRelation := relationNames[target][rnd.Intn(len(relationNames[target]))]
+					allowed[j] = ns.AllowedRelation(namespaceNames[target], targetRelation)
+				default:
+					allowed[j] = ns.AllowedRelation(namespaceNames[target], tuple.Ellipsis)
+				}
+			}
+
+			relations = append(relations, ns.Relation(relationName, nil, allowed...))
+		}
+
+		for _, permissionName := range permissionNames[i] {
+			rewrite := randomRewrite(rnd, 0, relationNames[i], computableNames[i], computableNames)
+			relations = append(relations, ns.Relation(permissionName, rewrite))
+		}
+
+		defs = append(defs, ns.Namespace(namespaceName, relations...))
+	}
+
+	return defs
+}
+
+// randomRewrite returns a random rewrite of computed usersets over the computable relations and
+// permissions, and of arrows from the tupleset relations to the relations and permissions of any
+// namespace.
+func randomRewrite(rnd *rand.Rand, depth int, tuplesets []string, computable []string, computableNames [][]string) *core.UsersetRewrite {
+	children := make([]*core.SetOperation_Child, 1+rnd.Intn(maxGeneratedRewriteSize))
+	for i := range children {
+		switch rnd.Intn(4) {
+		case 0:
+			if depth < maxGeneratedRewriteDepth {
+				children[i] = ns.Rewrite(randomRewrite(rnd, depth+1, tuplesets, computable, computableNames))
+				continue
+			}
+			fallthrough
+
+		case 1:
+			tupleset := tuplesets[rnd.Intn(len(tuplesets))]
+			targetNames := computableNames[rnd.Intn(len(computableNames))]
+			children[i] = ns.TupleToUserset(tupleset, targetNames[rnd.Intn(len(targetNames))])
+
+		default:
+			children[i] = ns.ComputedUserset(computable[rnd.Intn(len(computable))])
+		}
+	}
+
+	switch rnd.Intn(3) {
+	case 0:
+		return ns.Intersection(children[0], children[1:]...)
+	case 1:
+		return ns.Exclusion(children[0], children[1:]...)
+	default:
+		return ns.Union(children[0], children[1:]...)
+	}
+}
+
+func generatedNames(prefix string, count int) []string {
+	names := make([]string, count)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return names
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/shopspring/decimal"
@@ -145,6 +146,16 @@ type Stats struct {
 	// ObjectTypeStatistics returns a slice element for each object type (namespace)
 	// stored in the datastore.
 	ObjectTypeStatistics []ObjectTypeStat
+
+	// EstimatedRelationshipCountByNamespace is a best-guess estimate of the number of
+	// relationships in the datastore, keyed by the namespace of the resource. Datastores
+	// which do not compute a per-namespace breakdown leave it nil.
+	EstimatedRelationshipCountByNamespace map[string]uint64
+
+	// EstimatedRelationshipCountUpdatedAt is the time at which the table statistics from which
+	// EstimatedRelationshipCount was read were last refreshed, indicating how stale the estimate
+	// may be. Datastores which do not report it, or which cannot determine it, leave it nil.
+	EstimatedRelationshipCountUpdatedAt *time.Time
 }
 
 // RelationshipIterator is an iterator over matched tuples.
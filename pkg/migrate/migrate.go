@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -41,6 +42,7 @@ type migration struct {
 	version  string
 	replaces string
 	up       interface{}
+	down     interface{}
 }
 
 // Manager is used to manage a self-contained set of migrations. Standard usage
@@ -63,6 +65,14 @@ func NewManager() *Manager {
 // method into the upgrade function. If not extra fields or data are required
 // the function can alternatively take a Driver interface param.
 func (m *Manager) Register(version, replaces string, up interface{}) error {
+	return m.RegisterWithDown(version, replaces, up, nil)
+}
+
+// RegisterWithDown is used to associate a single migration with the migration
+// engine, along with a down function that reverts the migration when passed to
+// Rollback. The down parameter must have the same signature as the up parameter,
+// or be nil if the migration cannot be reverted.
+func (m *Manager) RegisterWithDown(version, replaces string, up, down interface{}) error {
 	if strings.ToLower(version) == Head {
 		return fmt.Errorf("unable to register version called head")
 	}
@@ -75,6 +85,7 @@ func (m *Manager) Register(version, replaces string, up interface{}) error {
 		version:  version,
 		replaces: replaces,
 		up:       up,
+		down:     down,
 	}
 
 	return nil
@@ -83,6 +94,10 @@ func (m *Manager) Register(version, replaces string, up interface{}) error {
 // Run will actually perform the necessary migrations to bring the backing datastore
 // from its current revision to the specified revision.
 func (m *Manager) Run(ctx context.Context, driver Driver, throughRevision string, dryRun RunType) error {
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("invalid migrations: %w", err)
+	}
+
 	starting, err := driver.Version(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to compute target revision: %w", err)
@@ -129,7 +144,7 @@ func (m *Manager) Run(ctx context.Context, driver Driver, throughRevision string
 
 			errArg := upFunction.Call(in)[0]
 			if !errArg.IsNil() {
-				return fmt.Errorf("error running migration up function: %v", errArg)
+				return fmt.Errorf("error running migration up function: %w", errArg.Interface().(error))
 			}
 
 			if err := driver.WriteVersion(ctx, migrationToRun.version, migrationToRun.replaces); err != nil {
@@ -141,6 +156,123 @@ func (m *Manager) Run(ctx context.Context, driver Driver, throughRevision string
 	return nil
 }
 
+// Rollback will revert the migrations applied to the backing datastore, in reverse
+// order, until it is at the specified revision. Every migration being reverted must
+// have been registered with a down function. When reverting the first migration, the
+// down function is expected to remove all of the migration state, and so no version
+// is written to the driver.
+func (m *Manager) Rollback(ctx context.Context, driver Driver, toRevision string, dryRun RunType) error {
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("invalid migrations: %w", err)
+	}
+
+	starting, err := driver.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to compute current revision: %w", err)
+	}
+
+	toRevert, err := collectMigrationsInRange(toRevision, starting, m.migrations)
+	if err != nil {
+		return fmt.Errorf("unable to compute migration list: %w", err)
+	}
+
+	for i := len(toRevert) - 1; i >= 0; i-- {
+		oneMigration := toRevert[i]
+		if oneMigration.down == nil {
+			return fmt.Errorf("migration %s cannot be rolled back: no down migration registered", oneMigration.version)
+		}
+
+		if err := checkTypes(driver, oneMigration.down); err != nil {
+			return fmt.Errorf("unable to validate down migration: %w", err)
+		}
+
+		log.Info().Str("from", oneMigration.version).Str("to", oneMigration.replaces).Msg("planned rollback")
+	}
+
+	if !dryRun {
+		for i := len(toRevert) - 1; i >= 0; i-- {
+			migrationToRevert := toRevert[i]
+
+			// Double check that the current version reported is the one we expect
+			currentVersion, err := driver.Version(ctx)
+			if err != nil {
+				return fmt.Errorf("unable to load version from driver: %w", err)
+			}
+
+			if migrationToRevert.version != currentVersion {
+				return fmt.Errorf("rollback attempting to run out of order: %s != %s", currentVersion, migrationToRevert.version)
+			}
+
+			log.Info().Str("from", migrationToRevert.version).Str("to", migrationToRevert.replaces).Msg("rolling back")
+
+			in := []reflect.Value{reflect.ValueOf(driver)}
+			downFunction := reflect.ValueOf(migrationToRevert.down)
+
+			errArg := downFunction.Call(in)[0]
+			if !errArg.IsNil() {
+				return fmt.Errorf("error running migration down function: %w", errArg.Interface().(error))
+			}
+
+			if migrationToRevert.replaces == None {
+				continue
+			}
+
+			if err := driver.WriteVersion(ctx, migrationToRevert.replaces, migrationToRevert.version); err != nil {
+				return fmt.Errorf("error writing migration version to driver: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that the registered migrations form a single chain from the first migration,
+// such that they can only be run in one order: every replaced revision must be registered, no two
+// migrations may replace the same revision, and migrations may not replace each other in a cycle.
+// Run and Rollback validate the migrations before running any of them.
+func (m *Manager) Validate() error {
+	versions := make([]string, 0, len(m.migrations))
+	for version := range m.migrations {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	replacedBy := make(map[string]string, len(versions))
+	for _, version := range versions {
+		replaces := m.migrations[version].replaces
+		if _, ok := m.migrations[replaces]; !ok && replaces != None {
+			return fmt.Errorf("migration %s replaces revision %s, which is not registered", version, replaces)
+		}
+
+		if other, ok := replacedBy[replaces]; ok {
+			if replaces == None {
+				return fmt.Errorf("migrations %s and %s are both initial migrations, replacing no revision", other, version)
+			}
+			return fmt.Errorf("migrations %s and %s both replace revision %s, so they cannot be ordered", other, version, replaces)
+		}
+		replacedBy[replaces] = version
+	}
+
+	// Every replaced revision being registered and replaced at most once, the migrations not
+	// reached from the initial migration replace each other in a cycle.
+	reached := map[string]struct{}{}
+	for version, ok := replacedBy[None]; ok; version, ok = replacedBy[version] {
+		reached[version] = struct{}{}
+	}
+
+	var cycle []string
+	for _, version := range versions {
+		if _, ok := reached[version]; !ok {
+			cycle = append(cycle, version)
+		}
+	}
+	if len(cycle) > 0 {
+		return fmt.Errorf("migrations %s replace each other in a cycle", strings.Join(cycle, ", "))
+	}
+
+	return nil
+}
+
 func (m *Manager) HeadRevision() (string, error) {
 	candidates := make(map[string]struct{}, len(m.migrations))
 	for candidate := range m.migrations {
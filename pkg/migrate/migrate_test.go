@@ -2,6 +2,7 @@ package migrate
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -158,27 +159,179 @@ func TestIsHeadCompatible(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		migrations    map[string]migration
+		expectedError string
+	}{
+		{"no migrations", noMigrations, ""},
+		{"simple", simpleMigrations, ""},
+		{"single headed chain", singleHeadedChain, ""},
+		{
+			"multi headed chain",
+			multiHeadedChain,
+			"migrations 789a and 789b both replace revision 456, so they cannot be ordered",
+		},
+		{
+			"missing early migrations",
+			missingEarlyMigrations,
+			"migration 456 replaces revision 123, which is not registered",
+		},
+		{
+			"multiple initial migrations",
+			map[string]migration{
+				"123": {"123", "", nil, nil},
+				"456": {"456", "", nil, nil},
+			},
+			"migrations 123 and 456 are both initial migrations, replacing no revision",
+		},
+		{
+			"cycle",
+			map[string]migration{
+				"123": {"123", "", nil, nil},
+				"456": {"456", "789", nil, nil},
+				"789": {"789", "456", nil, nil},
+			},
+			"migrations 456, 789 replace each other in a cycle",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := Manager{migrations: tc.migrations}
+			err := m.Validate()
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestRunValidatesMigrations(t *testing.T) {
+	req := require.New(t)
+
+	var ran []string
+	up := func(version string) func(*fakeDriver) error {
+		return func(*fakeDriver) error {
+			ran = append(ran, version)
+			return nil
+		}
+	}
+
+	m := NewManager()
+	req.NoError(m.Register("123", "", up("123")))
+	req.NoError(m.Register("456", "123", up("456")))
+	req.NoError(m.Register("789", "missing", up("789")))
+
+	err := m.Run(context.Background(), &fakeDriver{}, "456", LiveRun)
+	req.EqualError(err, "invalid migrations: migration 789 replaces revision missing, which is not registered")
+	req.Empty(ran)
+
+	err = m.Rollback(context.Background(), &fakeDriver{}, None, LiveRun)
+	req.ErrorContains(err, "invalid migrations")
+}
+
+type versionTrackingDriver struct {
+	version  string
+	reverted []string
+}
+
+func (vtd *versionTrackingDriver) Version(ctx context.Context) (string, error) {
+	return vtd.version, nil
+}
+
+func (vtd *versionTrackingDriver) WriteVersion(ctx context.Context, version, replaced string) error {
+	if vtd.version != replaced {
+		return fmt.Errorf("expected version %s, found %s", replaced, vtd.version)
+	}
+	vtd.version = version
+	return nil
+}
+
+func (*versionTrackingDriver) Close() error {
+	return nil
+}
+
+func TestRollback(t *testing.T) {
+	down := func(version string) func(*versionTrackingDriver) error {
+		return func(vtd *versionTrackingDriver) error {
+			vtd.reverted = append(vtd.reverted, version)
+			return nil
+		}
+	}
+
+	newManager := func() *Manager {
+		m := NewManager()
+		require.NoError(t, m.RegisterWithDown("123", "", func(*versionTrackingDriver) error { return nil }, down("123")))
+		require.NoError(t, m.RegisterWithDown("456", "123", func(*versionTrackingDriver) error { return nil }, down("456")))
+		require.NoError(t, m.RegisterWithDown("789", "456", func(*versionTrackingDriver) error { return nil }, down("789")))
+		require.NoError(t, m.Register("10", "789", func(*versionTrackingDriver) error { return nil }))
+		return m
+	}
+
+	testCases := []struct {
+		name             string
+		starting         string
+		toRevision       string
+		expectError      bool
+		expectedVersion  string
+		expectedReverted []string
+	}{
+		{"single", "789", "456", false, "456", []string{"789"}},
+		{"multiple", "789", "123", false, "123", []string{"789", "456"}},
+		{"to none", "789", None, false, "123", []string{"789", "456", "123"}},
+		{"no-op", "456", "456", false, "456", nil},
+		{"missing down", "10", "789", true, "10", nil},
+		{"unknown revision", "789", "abc", true, "789", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			driver := &versionTrackingDriver{version: tc.starting}
+			err := newManager().Rollback(context.Background(), driver, tc.toRevision, LiveRun)
+			require.Equal(tc.expectError, err != nil, err)
+			require.Equal(tc.expectedVersion, driver.version)
+			require.Equal(tc.expectedReverted, driver.reverted)
+		})
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		require := require.New(t)
+
+		driver := &versionTrackingDriver{version: "789"}
+		require.NoError(newManager().Rollback(context.Background(), driver, "123", DryRun))
+		require.Equal("789", driver.version)
+		require.Nil(driver.reverted)
+	})
+}
+
 var noMigrations = map[string]migration{}
 
 var simpleMigrations = map[string]migration{
-	"123": {"123", "", nil},
+	"123": {"123", "", nil, nil},
 }
 
 var singleHeadedChain = map[string]migration{
-	"123": {"123", "", nil},
-	"456": {"456", "123", nil},
-	"789": {"789", "456", nil},
+	"123": {"123", "", nil, nil},
+	"456": {"456", "123", nil, nil},
+	"789": {"789", "456", nil, nil},
 }
 
 var multiHeadedChain = map[string]migration{
-	"123":  {"123", "", nil},
-	"456":  {"456", "123", nil},
-	"789a": {"789a", "456", nil},
-	"789b": {"789b", "456", nil},
+	"123":  {"123", "", nil, nil},
+	"456":  {"456", "123", nil, nil},
+	"789a": {"789a", "456", nil, nil},
+	"789b": {"789b", "456", nil, nil},
 }
 
 var missingEarlyMigrations = map[string]migration{
-	"456": {"456", "123", nil},
-	"789": {"789", "456", nil},
-	"10":  {"10", "789", nil},
+	"456": {"456", "123", nil, nil},
+	"789": {"789", "456", nil, nil},
+	"10":  {"10", "789", nil, nil},
 }